@@ -8,6 +8,8 @@ package sarama
 import (
 	"math"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
 )
@@ -20,6 +22,7 @@ type config struct {
 	consumerSpanName    string
 	producerSpanName    string
 	analyticsRate       float64
+	propagator          tracer.Propagator
 }
 
 func defaults(cfg *config) {
@@ -73,3 +76,30 @@ func WithAnalyticsRate(rate float64) Option {
 		}
 	}
 }
+
+// WithPropagator sets the propagator used to inject the span context into, and extract
+// it from, the message headers. It defaults to the propagator configured on the global
+// tracer.
+func WithPropagator(p tracer.Propagator) Option {
+	return func(cfg *config) {
+		cfg.propagator = p
+	}
+}
+
+// inject injects spanctx into carrier using the configured propagator, if any, or the
+// global one.
+func (cfg *config) inject(spanctx ddtrace.SpanContext, carrier interface{}) error {
+	if cfg.propagator != nil {
+		return cfg.propagator.Inject(spanctx, carrier)
+	}
+	return tracer.Inject(spanctx, carrier)
+}
+
+// extract extracts a span context from carrier using the configured propagator, if any,
+// or the global one.
+func (cfg *config) extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	if cfg.propagator != nil {
+		return cfg.propagator.Extract(carrier)
+	}
+	return tracer.Extract(carrier)
+}
@@ -19,12 +19,20 @@ type ProducerMessageCarrier struct {
 var _ interface {
 	tracer.TextMapReader
 	tracer.TextMapWriter
+	tracer.HeadersCarrier
 } = (*ProducerMessageCarrier)(nil)
 
 // ForeachKey iterates over every header.
 func (c ProducerMessageCarrier) ForeachKey(handler func(key, val string) error) error {
+	return c.ForeachHeader(func(key string, val []byte) error {
+		return handler(key, string(val))
+	})
+}
+
+// ForeachHeader iterates over every header.
+func (c ProducerMessageCarrier) ForeachHeader(handler func(key string, val []byte) error) error {
 	for _, h := range c.msg.Headers {
-		err := handler(string(h.Key), string(h.Value))
+		err := handler(string(h.Key), h.Value)
 		if err != nil {
 			return err
 		}
@@ -34,6 +42,11 @@ func (c ProducerMessageCarrier) ForeachKey(handler func(key, val string) error)
 
 // Set sets a header.
 func (c ProducerMessageCarrier) Set(key, val string) {
+	c.SetHeader(key, []byte(val))
+}
+
+// SetHeader sets a header.
+func (c ProducerMessageCarrier) SetHeader(key string, val []byte) {
 	// ensure uniqueness of keys
 	for i := 0; i < len(c.msg.Headers); i++ {
 		if string(c.msg.Headers[i].Key) == key {
@@ -43,7 +56,7 @@ func (c ProducerMessageCarrier) Set(key, val string) {
 	}
 	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{
 		Key:   []byte(key),
-		Value: []byte(val),
+		Value: val,
 	})
 }
 
@@ -60,6 +73,7 @@ type ConsumerMessageCarrier struct {
 var _ interface {
 	tracer.TextMapReader
 	tracer.TextMapWriter
+	tracer.HeadersCarrier
 } = (*ConsumerMessageCarrier)(nil)
 
 // NewConsumerMessageCarrier creates a new ConsumerMessageCarrier.
@@ -69,9 +83,16 @@ func NewConsumerMessageCarrier(msg *sarama.ConsumerMessage) ConsumerMessageCarri
 
 // ForeachKey iterates over every header.
 func (c ConsumerMessageCarrier) ForeachKey(handler func(key, val string) error) error {
+	return c.ForeachHeader(func(key string, val []byte) error {
+		return handler(key, string(val))
+	})
+}
+
+// ForeachHeader iterates over every header.
+func (c ConsumerMessageCarrier) ForeachHeader(handler func(key string, val []byte) error) error {
 	for _, h := range c.msg.Headers {
 		if h != nil {
-			err := handler(string(h.Key), string(h.Value))
+			err := handler(string(h.Key), h.Value)
 			if err != nil {
 				return err
 			}
@@ -82,6 +103,11 @@ func (c ConsumerMessageCarrier) ForeachKey(handler func(key, val string) error)
 
 // Set sets a header.
 func (c ConsumerMessageCarrier) Set(key, val string) {
+	c.SetHeader(key, []byte(val))
+}
+
+// SetHeader sets a header.
+func (c ConsumerMessageCarrier) SetHeader(key string, val []byte) {
 	// ensure uniqueness of keys
 	for i := 0; i < len(c.msg.Headers); i++ {
 		if c.msg.Headers[i] != nil && string(c.msg.Headers[i].Key) == key {
@@ -91,6 +117,6 @@ func (c ConsumerMessageCarrier) Set(key, val string) {
 	}
 	c.msg.Headers = append(c.msg.Headers, &sarama.RecordHeader{
 		Key:   []byte(key),
-		Value: []byte(val),
+		Value: val,
 	})
 }
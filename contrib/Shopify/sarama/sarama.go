@@ -73,12 +73,12 @@ func WrapPartitionConsumer(pc sarama.PartitionConsumer, opts ...Option) sarama.P
 			}
 			// kafka supports headers, so try to extract a span context
 			carrier := NewConsumerMessageCarrier(msg)
-			if spanctx, err := tracer.Extract(carrier); err == nil {
+			if spanctx, err := cfg.extract(carrier); err == nil {
 				opts = append(opts, tracer.ChildOf(spanctx))
 			}
 			next := tracer.StartSpan(cfg.consumerSpanName, opts...)
 			// reinject the span context so consumers can pick it up
-			tracer.Inject(next.Context(), carrier)
+			cfg.inject(next.Context(), carrier)
 			setConsumeCheckpoint(cfg.dataStreamsEnabled, cfg.groupID, msg)
 
 			wrapped.messages <- msg
@@ -256,7 +256,7 @@ func WrapAsyncProducer(saramaConfig *sarama.Config, p sarama.AsyncProducer, opts
 					// we only track Kafka lag if returning successes is enabled. Otherwise, we have no way to know to which partition data was sent to.
 					tracer.TrackKafkaProduceOffset(msg.Topic, msg.Partition, msg.Offset)
 				}
-				if spanctx, spanFound := getSpanContext(msg); spanFound {
+				if spanctx, spanFound := getSpanContext(cfg, msg); spanFound {
 					spanID := spanctx.SpanID()
 					if span, ok := spans[spanID]; ok {
 						delete(spans, spanID)
@@ -269,7 +269,7 @@ func WrapAsyncProducer(saramaConfig *sarama.Config, p sarama.AsyncProducer, opts
 					// producer was closed
 					return
 				}
-				if spanctx, spanFound := getSpanContext(err.Msg); spanFound {
+				if spanctx, spanFound := getSpanContext(cfg, err.Msg); spanFound {
 					spanID := spanctx.SpanID()
 					if span, ok := spans[spanID]; ok {
 						delete(spans, spanID)
@@ -297,13 +297,13 @@ func startProducerSpan(cfg *config, version sarama.KafkaVersion, msg *sarama.Pro
 		opts = append(opts, tracer.Tag(ext.EventSampleRate, cfg.analyticsRate))
 	}
 	// if there's a span context in the headers, use that as the parent
-	if spanctx, err := tracer.Extract(carrier); err == nil {
+	if spanctx, err := cfg.extract(carrier); err == nil {
 		opts = append(opts, tracer.ChildOf(spanctx))
 	}
 	span := tracer.StartSpan(cfg.producerSpanName, opts...)
 	if version.IsAtLeast(sarama.V0_11_0_0) {
 		// re-inject the span context so consumers can pick it up
-		tracer.Inject(span.Context(), carrier)
+		cfg.inject(span.Context(), carrier)
 	}
 	return span
 }
@@ -314,9 +314,9 @@ func finishProducerSpan(span ddtrace.Span, partition int32, offset int64, err er
 	span.Finish(tracer.WithError(err))
 }
 
-func getSpanContext(msg *sarama.ProducerMessage) (ddtrace.SpanContext, bool) {
+func getSpanContext(cfg *config, msg *sarama.ProducerMessage) (ddtrace.SpanContext, bool) {
 	carrier := NewProducerMessageCarrier(msg)
-	spanctx, err := tracer.Extract(carrier)
+	spanctx, err := cfg.extract(carrier)
 	if err != nil {
 		return nil, false
 	}
@@ -7,11 +7,13 @@ package sarama
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/namingschematest"
 	"gopkg.in/DataDog/dd-trace-go.v1/datastreams"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -238,6 +240,64 @@ func TestSyncProducer(t *testing.T) {
 	}
 }
 
+// headerPropagator is a tracer.Propagator injecting the span ID into a single header.
+type headerPropagator struct{}
+
+func (headerPropagator) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	carrier.(tracer.TextMapWriter).Set("x-custom-span-id", strconv.FormatUint(ctx.SpanID(), 10))
+	return nil
+}
+
+func (headerPropagator) Extract(_ interface{}) (ddtrace.SpanContext, error) {
+	return nil, tracer.ErrSpanContextNotFound
+}
+
+func TestSyncProducerPropagator(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	seedBroker := sarama.NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	leader := sarama.NewMockBroker(t, 2)
+	defer leader.Close()
+
+	metadataResponse := new(sarama.MetadataResponse)
+	metadataResponse.Version = 1
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, sarama.ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(sarama.ProduceResponse)
+	prodSuccess.Version = 2
+	prodSuccess.AddTopicPartition("my_topic", 0, sarama.ErrNoError)
+	leader.Returns(prodSuccess)
+
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V0_11_0_0 // first version that supports headers
+	cfg.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer([]string{seedBroker.Addr()}, cfg)
+	require.NoError(t, err)
+	producer = WrapSyncProducer(cfg, producer, WithPropagator(headerPropagator{}))
+
+	msg := &sarama.ProducerMessage{
+		Topic: "my_topic",
+		Value: sarama.StringEncoder("test"),
+	}
+	_, _, err = producer.SendMessage(msg)
+	require.NoError(t, err)
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	assert.Equal(t, strconv.FormatUint(spans[0].SpanID(), 10), headers["x-custom-span-id"])
+	assert.NotContains(t, headers, tracer.DefaultTraceIDHeader)
+}
+
 func TestSyncProducerSendMessages(t *testing.T) {
 	mt := mocktracer.Start()
 	defer mt.Stop()
@@ -122,7 +122,7 @@ func WrapReceiveHandler(s *pubsub.Subscription, f func(context.Context, *pubsub.
 		if msg.DeliveryAttempt != nil {
 			span.SetTag("delivery_attempt", *msg.DeliveryAttempt)
 		}
-		defer span.Finish()
+		defer tracer.RecoverAndFinish(span)
 		f(ctx, msg)
 	}
 }
@@ -238,6 +238,29 @@ func TestPropagationNoPublisherSpan(t *testing.T) {
 	}, spans[0].Tags())
 }
 
+func TestReceivePanic(t *testing.T) {
+	assert := assert.New(t)
+	_, _, mt, _, sub := setup(t)
+
+	handler := WrapReceiveHandler(sub, func(ctx context.Context, msg *pubsub.Message) {
+		panic("boom")
+	})
+	msg := &pubsub.Message{ID: "1", Data: []byte("hello")}
+	// the panic is raised again once the span has been finished
+	assert.PanicsWithValue("boom", func() {
+		handler(context.Background(), msg)
+	})
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal("pubsub.receive", span.OperationName())
+	assert.Equal("panic", span.Tag(ext.ErrorType))
+	assert.Equal("boom", span.Tag(ext.ErrorMsg))
+	assert.Contains(span.Tag(ext.ErrorStack), "TestReceivePanic")
+	assert.IsType(&tracer.PanicError{}, span.Tag(ext.Error))
+}
+
 func TestNamingSchema(t *testing.T) {
 	genSpans := namingschematest.GenSpansFn(func(t *testing.T, serviceOverride string) []mocktracer.Span {
 		var opts []Option
@@ -19,12 +19,20 @@ type MessageCarrier struct {
 var _ interface {
 	tracer.TextMapReader
 	tracer.TextMapWriter
+	tracer.HeadersCarrier
 } = (*MessageCarrier)(nil)
 
 // ForeachKey iterates over every header.
 func (c MessageCarrier) ForeachKey(handler func(key, val string) error) error {
+	return c.ForeachHeader(func(key string, val []byte) error {
+		return handler(key, string(val))
+	})
+}
+
+// ForeachHeader iterates over every header.
+func (c MessageCarrier) ForeachHeader(handler func(key string, val []byte) error) error {
 	for _, h := range c.msg.Headers {
-		err := handler(string(h.Key), string(h.Value))
+		err := handler(string(h.Key), h.Value)
 		if err != nil {
 			return err
 		}
@@ -34,6 +42,11 @@ func (c MessageCarrier) ForeachKey(handler func(key, val string) error) error {
 
 // Set sets a header.
 func (c MessageCarrier) Set(key, val string) {
+	c.SetHeader(key, []byte(val))
+}
+
+// SetHeader sets a header.
+func (c MessageCarrier) SetHeader(key string, val []byte) {
 	// ensure uniqueness of keys
 	for i := 0; i < len(c.msg.Headers); i++ {
 		if string(c.msg.Headers[i].Key) == key {
@@ -43,7 +56,7 @@ func (c MessageCarrier) Set(key, val string) {
 	}
 	c.msg.Headers = append(c.msg.Headers, kafka.Header{
 		Key:   key,
-		Value: []byte(val),
+		Value: val,
 	})
 }
 
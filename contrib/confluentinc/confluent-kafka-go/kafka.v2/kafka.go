@@ -134,12 +134,12 @@ func (c *Consumer) startSpan(msg *kafka.Message) ddtrace.Span {
 	}
 	// kafka supports headers, so try to extract a span context
 	carrier := NewMessageCarrier(msg)
-	if spanctx, err := tracer.Extract(carrier); err == nil {
+	if spanctx, err := c.cfg.extract(carrier); err == nil {
 		opts = append(opts, tracer.ChildOf(spanctx))
 	}
 	span, _ := tracer.StartSpanFromContext(c.cfg.ctx, c.cfg.consumerSpanName, opts...)
 	// reinject the span context so consumers can pick it up
-	tracer.Inject(span.Context(), carrier)
+	c.cfg.inject(span.Context(), carrier)
 	return span
 }
 
@@ -298,12 +298,12 @@ func (p *Producer) startSpan(msg *kafka.Message) ddtrace.Span {
 	}
 	//if there's a span context in the headers, use that as the parent
 	carrier := NewMessageCarrier(msg)
-	if spanctx, err := tracer.Extract(carrier); err == nil {
+	if spanctx, err := p.cfg.extract(carrier); err == nil {
 		opts = append(opts, tracer.ChildOf(spanctx))
 	}
 	span, _ := tracer.StartSpanFromContext(p.cfg.ctx, p.cfg.producerSpanName, opts...)
 	// inject the span context so consumers can pick it up
-	tracer.Inject(span.Context(), carrier)
+	p.cfg.inject(span.Context(), carrier)
 	return span
 }
 
@@ -11,6 +11,8 @@ import (
 	"net"
 	"strings"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/namingschema"
 
@@ -30,6 +32,7 @@ type config struct {
 	groupID             string
 	tagFns              map[string]func(msg *kafka.Message) interface{}
 	dataStreamsEnabled  bool
+	propagator          tracer.Propagator
 }
 
 // An Option customizes the config.
@@ -135,3 +138,30 @@ func WithDataStreams() Option {
 		cfg.dataStreamsEnabled = true
 	}
 }
+
+// WithPropagator sets the propagator used to inject the span context into, and extract
+// it from, the message headers. It defaults to the propagator configured on the global
+// tracer.
+func WithPropagator(p tracer.Propagator) Option {
+	return func(cfg *config) {
+		cfg.propagator = p
+	}
+}
+
+// inject injects spanctx into carrier using the configured propagator, if any, or the
+// global one.
+func (cfg *config) inject(spanctx ddtrace.SpanContext, carrier interface{}) error {
+	if cfg.propagator != nil {
+		return cfg.propagator.Inject(spanctx, carrier)
+	}
+	return tracer.Inject(spanctx, carrier)
+}
+
+// extract extracts a span context from carrier using the configured propagator, if any,
+// or the global one.
+func (cfg *config) extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	if cfg.propagator != nil {
+		return cfg.propagator.Extract(carrier)
+	}
+	return tracer.Extract(carrier)
+}
@@ -9,11 +9,13 @@ import (
 	"context"
 	"errors"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/namingschematest"
 	"gopkg.in/DataDog/dd-trace-go.v1/datastreams"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -361,6 +363,49 @@ func TestCustomTags(t *testing.T) {
 	assert.Equal(t, []byte("key1"), s.Tag("key"))
 }
 
+// headerPropagator is a tracer.Propagator injecting the span ID into a single header.
+type headerPropagator struct{}
+
+func (headerPropagator) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	carrier.(tracer.TextMapWriter).Set("x-custom-span-id", strconv.FormatUint(ctx.SpanID(), 10))
+	return nil
+}
+
+func (headerPropagator) Extract(_ interface{}) (ddtrace.SpanContext, error) {
+	return nil, tracer.ErrSpanContextNotFound
+}
+
+func TestProducerPropagator(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	p, err := NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":   "127.0.0.1:9092",
+		"go.delivery.reports": true,
+	}, WithPropagator(headerPropagator{}))
+	require.NoError(t, err)
+	defer p.Close()
+
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &testTopic,
+			Partition: 0,
+		},
+		Key:   []byte("key2"),
+		Value: []byte("value2"),
+	}
+	p.startSpan(msg).Finish()
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	assert.Equal(t, strconv.FormatUint(spans[0].SpanID(), 10), headers["x-custom-span-id"])
+	assert.NotContains(t, headers, tracer.DefaultTraceIDHeader)
+}
+
 func TestNamingSchema(t *testing.T) {
 	genSpans := func(t *testing.T, serviceOverride string) []mocktracer.Span {
 		var opts []Option
@@ -124,7 +124,7 @@ func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
 
 			// it's possible there's already a span on the context even though
 			// we're not tracing calls, so inject it if it's there
-			ctx = injectSpanIntoContext(ctx)
+			ctx = injectSpanIntoContext(ctx, cfg)
 
 			var err error
 			stream, err = streamer(ctx, desc, cc, method, opts...)
@@ -191,7 +191,7 @@ func doClientRequest(
 	var p peer.Peer
 	opts = append(opts, grpc.Peer(&p))
 
-	handlerCtx := injectSpanIntoContext(ctx)
+	handlerCtx := injectSpanIntoContext(ctx, cfg)
 	err := handler(handlerCtx, opts)
 
 	setSpanTargetFromPeer(span, p)
@@ -214,8 +214,9 @@ func setSpanTargetFromPeer(span ddtrace.Span, p peer.Peer) {
 }
 
 // injectSpanIntoContext injects the span associated with a context as gRPC metadata
+// using the propagator of cfg, if any, or the global one.
 // if no span is associated with the context, just return the original context.
-func injectSpanIntoContext(ctx context.Context) context.Context {
+func injectSpanIntoContext(ctx context.Context, cfg *config) context.Context {
 	span, ok := tracer.SpanFromContext(ctx)
 	if !ok {
 		return ctx
@@ -227,7 +228,11 @@ func injectSpanIntoContext(ctx context.Context) context.Context {
 	} else {
 		md = metadata.MD{}
 	}
-	if err := tracer.Inject(span.Context(), grpcutil.MDCarrier(md)); err != nil {
+	inject := tracer.Inject
+	if cfg.propagator != nil {
+		inject = cfg.propagator.Inject
+	}
+	if err := inject(span.Context(), grpcutil.MDCarrier(md)); err != nil {
 		// in practice this error should never really happen
 		grpclog.Warningf("ddtrace: failed to inject the span context into the gRPC metadata: %v", err)
 	}
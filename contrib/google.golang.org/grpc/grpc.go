@@ -104,3 +104,13 @@ func finishWithError(span ddtrace.Span, err error, cfg *config) {
 	}
 	span.Finish(finishOptions...)
 }
+
+// finishWithPanic finishes the given span with the value r recovered from a panicking handler.
+func finishWithPanic(span ddtrace.Span, r interface{}, cfg *config) {
+	span.SetTag(tagCode, codes.Unknown.String())
+	finishOptions := []tracer.FinishOption{tracer.WithPanicCapture(r)}
+	if cfg.noDebugStack {
+		finishOptions = append(finishOptions, tracer.NoDebugStack())
+	}
+	span.Finish(finishOptions...)
+}
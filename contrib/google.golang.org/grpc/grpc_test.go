@@ -13,6 +13,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -20,6 +22,7 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/lists"
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/namingschematest"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
@@ -492,6 +495,149 @@ func TestPreservesMetadata(t *testing.T) {
 	assert.Equal(t, s.Tag(tagMetadataPrefix+"test-key"), []string{"test-value"})
 }
 
+// mdPropagator is a tracer.Propagator injecting the span ID into a single metadata key.
+type mdPropagator struct{}
+
+func (mdPropagator) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	carrier.(tracer.TextMapWriter).Set("x-custom-span-id", strconv.FormatUint(ctx.SpanID(), 10))
+	return nil
+}
+
+func (mdPropagator) Extract(_ interface{}) (ddtrace.SpanContext, error) {
+	return nil, tracer.ErrSpanContextNotFound
+}
+
+func TestPropagator(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	rig, err := newRig(true, WithPropagator(mdPropagator{}))
+	if err != nil {
+		t.Fatalf("error setting up rig: %s", err)
+	}
+	defer rig.Close()
+
+	_, err = rig.client.Ping(context.Background(), &FixtureRequest{Name: "pass"})
+	require.NoError(t, err)
+
+	md := rig.fixtureServer.lastRequestMetadata.Load().(metadata.MD)
+	assert.Empty(t, md.Get("x-datadog-trace-id"))
+	var client mocktracer.Span
+	for _, s := range mt.FinishedSpans() {
+		if s.Tag(ext.SpanKind) == ext.SpanKindClient {
+			client = s
+		}
+	}
+	require.NotNil(t, client)
+	assert.Equal(t, []string{strconv.FormatUint(client.SpanID(), 10)}, md.Get("x-custom-span-id"))
+}
+
+// TestServerPropagationBehavior tests the server spans started from incoming trace
+// contexts based on the DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT environment variable.
+func TestServerPropagationBehavior(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer agent.Close()
+	for _, tc := range []struct {
+		behavior string
+		traceID  bool // whether the incoming trace is continued
+		link     bool // whether the span links to the incoming trace
+	}{
+		{behavior: "continue", traceID: true},
+		{behavior: "restart", link: true},
+		{behavior: "ignore"},
+	} {
+		t.Run(tc.behavior, func(t *testing.T) {
+			t.Setenv("DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT", tc.behavior)
+			tracer.Start(tracer.WithAgentAddr(agent.Listener.Addr().String()), tracer.WithLogger(log.DiscardLogger{}))
+			defer tracer.Stop()
+			rig, err := newRig(false)
+			require.NoError(t, err)
+			defer rig.Close()
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-datadog-trace-id", "1", "x-datadog-parent-id", "2")
+			_, err = rig.client.Ping(ctx, &FixtureRequest{Name: "pass"})
+			require.NoError(t, err)
+
+			span, ok := rig.fixtureServer.lastSpan.Load().(ddtrace.Span)
+			require.True(t, ok)
+			assert.Equal(t, tc.traceID, span.Context().TraceID() == 1)
+			assert.Equal(t, tc.link, len(span.(ddtrace.SpanWithLinks).Links()) == 1)
+		})
+	}
+}
+
+func TestServerBinaryPropagation(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer agent.Close()
+	tracer.Start(tracer.WithAgentAddr(agent.Listener.Addr().String()), tracer.WithLogger(log.DiscardLogger{}),
+		tracer.WithBinaryPropagation(true))
+	defer tracer.Stop()
+	rig, err := newRig(true)
+	require.NoError(t, err)
+	defer rig.Close()
+
+	parent := tracer.StartSpan("parent")
+	defer parent.Finish()
+	_, err = rig.client.Ping(tracer.ContextWithSpan(context.Background(), parent), &FixtureRequest{Name: "pass"})
+	require.NoError(t, err)
+
+	md := rig.fixtureServer.lastRequestMetadata.Load().(metadata.MD)
+	assert.Len(t, md.Get("x-datadog-context-bin"), 1)
+	assert.Empty(t, md.Get("x-datadog-trace-id"))
+	span, ok := rig.fixtureServer.lastSpan.Load().(ddtrace.Span)
+	require.True(t, ok)
+	assert.Equal(t, parent.Context().TraceID(), span.Context().TraceID())
+}
+
+// contextServerStream is a grpc.ServerStream which only provides a context.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss contextServerStream) Context() context.Context { return ss.ctx }
+
+func TestServerPanic(t *testing.T) {
+	assertPanicSpan := func(t *testing.T, mt mocktracer.Tracer) {
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 1)
+		span := spans[0]
+		assert.Equal(t, "grpc.server", span.OperationName())
+		assert.Equal(t, codes.Unknown.String(), span.Tag(tagCode))
+		assert.Equal(t, "panic", span.Tag(ext.ErrorType))
+		assert.Equal(t, "boom", span.Tag(ext.ErrorMsg))
+		assert.NotEmpty(t, span.Tag(ext.ErrorStack))
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		interceptor := UnaryServerInterceptor()
+		info := &grpc.UnaryServerInfo{FullMethod: "/package.MyService/ExampleMethod"}
+		assert.PanicsWithValue(t, "boom", func() {
+			interceptor(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				panic("boom")
+			})
+		})
+		assertPanicSpan(t, mt)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+
+		interceptor := StreamServerInterceptor(WithStreamMessages(false))
+		info := &grpc.StreamServerInfo{FullMethod: "/package.MyService/ExampleMethod"}
+		assert.PanicsWithValue(t, "boom", func() {
+			interceptor(nil, contextServerStream{ctx: context.Background()}, info, func(srv interface{}, stream grpc.ServerStream) error {
+				panic("boom")
+			})
+		})
+		assertPanicSpan(t, mt)
+	})
+}
+
 func TestStreamSendsErrorCode(t *testing.T) {
 	wantCode := codes.InvalidArgument.String()
 
@@ -540,6 +686,7 @@ func TestStreamSendsErrorCode(t *testing.T) {
 type fixtureServer struct {
 	UnimplementedFixtureServer
 	lastRequestMetadata atomic.Value
+	lastSpan            atomic.Value
 }
 
 func (s *fixtureServer) StreamPing(stream Fixture_StreamPingServer) (err error) {
@@ -569,6 +716,9 @@ func (s *fixtureServer) Ping(ctx context.Context, in *FixtureRequest) (*FixtureR
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
 		s.lastRequestMetadata.Store(md)
 	}
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		s.lastSpan.Store(span)
+	}
 	switch {
 	case in.Name == "child":
 		span, _ := tracer.StartSpanFromContext(ctx, "child")
@@ -40,6 +40,7 @@ type config struct {
 	withErrorDetailTags bool
 	spanOpts            []ddtrace.StartSpanOption
 	tags                map[string]interface{}
+	propagator          tracer.Propagator
 }
 
 // InterceptorOption represents an option that can be passed to the grpc unary
@@ -224,3 +225,12 @@ func WithSpanOptions(opts ...ddtrace.StartSpanOption) Option {
 		cfg.spanOpts = append(cfg.spanOpts, opts...)
 	}
 }
+
+// WithPropagator sets the propagator used by client interceptors and stats handlers to
+// inject the span context into the outgoing metadata. It defaults to the propagator
+// configured on the global tracer.
+func WithPropagator(p tracer.Propagator) Option {
+	return func(cfg *config) {
+		cfg.propagator = p
+	}
+}
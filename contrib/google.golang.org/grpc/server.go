@@ -111,7 +111,13 @@ func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
 			case info.IsClientStream:
 				span.SetTag(tagMethodKind, methodKindClientStream)
 			}
-			defer func() { finishWithError(span, err, cfg) }()
+			defer func() {
+				if r := recover(); r != nil {
+					finishWithPanic(span, r, cfg)
+					panic(r)
+				}
+				finishWithError(span, err, cfg)
+			}()
 			if appsec.Enabled() {
 				handler = appsecStreamHandlerMiddleware(span, handler)
 			}
@@ -157,6 +163,12 @@ func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
 		if appsec.Enabled() {
 			handler = appsecUnaryHandlerMiddleware(span, handler)
 		}
+		defer func() {
+			if r := recover(); r != nil {
+				finishWithPanic(span, r, cfg)
+				panic(r)
+			}
+		}()
 		resp, err := handler(ctx, req)
 		finishWithError(span, err, cfg)
 		return resp, err
@@ -13,13 +13,14 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
-// MDCarrier implements tracer.TextMapWriter and tracer.TextMapReader on top
-// of gRPC's metadata, allowing it to be used as a span context carrier for
-// distributed tracing.
+// MDCarrier implements tracer.TextMapWriter, tracer.TextMapReader and
+// tracer.HeadersCarrier on top of gRPC's metadata, allowing it to be used as
+// a span context carrier for distributed tracing.
 type MDCarrier metadata.MD
 
 var _ tracer.TextMapWriter = (*MDCarrier)(nil)
 var _ tracer.TextMapReader = (*MDCarrier)(nil)
+var _ tracer.HeadersCarrier = (*MDCarrier)(nil)
 
 // Get will return the first entry in the metadata at the given key.
 func (mdc MDCarrier) Get(key string) string {
@@ -47,3 +48,16 @@ func (mdc MDCarrier) ForeachKey(handler func(key, val string) error) error {
 	}
 	return nil
 }
+
+// SetHeader will add the given value to the values found at key. Binary values
+// must use keys ending in "-bin", as per gRPC's metadata conventions.
+func (mdc MDCarrier) SetHeader(key string, val []byte) {
+	mdc.Set(key, string(val))
+}
+
+// ForeachHeader will iterate over all key/value pairs in the metadata.
+func (mdc MDCarrier) ForeachHeader(handler func(key string, val []byte) error) error {
+	return mdc.ForeachKey(func(key, val string) error {
+		return handler(key, []byte(val))
+	})
+}
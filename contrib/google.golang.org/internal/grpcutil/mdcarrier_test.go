@@ -60,3 +60,20 @@ func TestMDCarrierForeachKey(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, want, got)
 }
+
+func TestMDCarrierHeaders(t *testing.T) {
+	assert := assert.New(t)
+	md := metadata.MD{}
+	mdc := MDCarrier(md)
+
+	mdc.SetHeader("X-Datadog-Context-Bin", []byte{1, 0, 255})
+	assert.Equal([]string{"\x01\x00\xff"}, md["x-datadog-context-bin"])
+
+	var got []byte
+	err := mdc.ForeachHeader(func(k string, v []byte) error {
+		got = v
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]byte{1, 0, 255}, got)
+}
@@ -63,6 +63,35 @@ func TestStartRequestSpan(t *testing.T) {
 	assert.Equal(t, "example.com", spans[0].Tag("http.host"))
 }
 
+// TestStartRequestSpanPropagationBehavior tests behavior of StartRequestSpan based on
+// the DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT environment variable
+func TestStartRequestSpanPropagationBehavior(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer agent.Close()
+	for _, tc := range []struct {
+		behavior string
+		traceID  bool // whether the incoming trace is continued
+		link     bool // whether the span links to the incoming trace
+	}{
+		{behavior: "continue", traceID: true},
+		{behavior: "restart", link: true},
+		{behavior: "ignore"},
+	} {
+		t.Run(tc.behavior, func(t *testing.T) {
+			t.Setenv("DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT", tc.behavior)
+			tracer.Start(tracer.WithAgentAddr(agent.Listener.Addr().String()), tracer.WithLogger(log.DiscardLogger{}))
+			defer tracer.Stop()
+			r := httptest.NewRequest(http.MethodGet, "/somePath", nil)
+			r.Header.Set("x-datadog-trace-id", "1")
+			r.Header.Set("x-datadog-parent-id", "2")
+			s, _ := StartRequestSpan(r)
+			defer s.Finish()
+			assert.Equal(t, tc.traceID, s.Context().TraceID() == 1)
+			assert.Equal(t, tc.link, len(s.(ddtrace.SpanWithLinks).Links()) == 1)
+		})
+	}
+}
+
 // TestClientIP tests behavior of StartRequestSpan based on
 // the DD_TRACE_CLIENT_IP_ENABLED environment variable
 func TestTraceClientIPFlag(t *testing.T) {
@@ -145,6 +145,7 @@ type roundTripperConfig struct {
 	ignoreRequest func(*http.Request) bool
 	spanOpts      []ddtrace.StartSpanOption
 	propagation   bool
+	propagator    tracer.Propagator
 	errCheck      func(err error) bool
 }
 
@@ -251,6 +252,15 @@ func RTWithPropagation(propagation bool) RoundTripperOption {
 	}
 }
 
+// RTWithPropagator sets the propagator used to inject the span context into outgoing
+// requests, for instance to use B3 headers when calling a Zipkin-instrumented service.
+// It defaults to the propagator configured on the global tracer.
+func RTWithPropagator(p tracer.Propagator) RoundTripperOption {
+	return func(cfg *roundTripperConfig) {
+		cfg.propagator = p
+	}
+}
+
 // RTWithIgnoreRequest holds the function to use for determining if the
 // outgoing HTTP request should not be traced.
 func RTWithIgnoreRequest(f func(*http.Request) bool) RoundTripperOption {
@@ -69,7 +69,11 @@ func (rt *roundTripper) RoundTrip(req *http.Request) (res *http.Response, err er
 	r2 := req.Clone(ctx)
 	if rt.cfg.propagation {
 		// inject the span context into the http request copy
-		err = tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(r2.Header))
+		if p := rt.cfg.propagator; p != nil {
+			err = p.Inject(span.Context(), tracer.HTTPHeadersCarrier(r2.Header))
+		} else {
+			err = tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(r2.Header))
+		}
 		if err != nil {
 			// this should never happen
 			fmt.Fprintf(os.Stderr, "contrib/net/http.Roundtrip: failed to inject http headers: %v\n", err)
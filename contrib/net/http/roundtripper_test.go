@@ -582,6 +582,42 @@ func TestRoundTripperPropagation(t *testing.T) {
 	defer resp.Body.Close()
 }
 
+// headerPropagator is a tracer.Propagator injecting the span ID into a single header.
+type headerPropagator struct{}
+
+func (headerPropagator) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	carrier.(tracer.TextMapWriter).Set("x-custom-span-id", strconv.FormatUint(ctx.SpanID(), 10))
+	return nil
+}
+
+func (headerPropagator) Extract(_ interface{}) (ddtrace.SpanContext, error) {
+	return nil, tracer.ErrSpanContextNotFound
+}
+
+func TestRoundTripperPropagator(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	var header http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte("Hello World"))
+	}))
+	defer s.Close()
+
+	client := &http.Client{
+		Transport: WrapRoundTripper(http.DefaultTransport, RTWithPropagator(headerPropagator{})),
+	}
+	resp, err := client.Get(s.URL + "/hello/world")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, strconv.FormatUint(spans[0].SpanID(), 10), header.Get("x-custom-span-id"))
+	assert.Empty(t, header.Get(tracer.DefaultTraceIDHeader))
+}
+
 func TestClientNamingSchema(t *testing.T) {
 	genSpans := namingschematest.GenSpansFn(func(t *testing.T, serviceOverride string) []mocktracer.Span {
 		var opts []RoundTripperOption
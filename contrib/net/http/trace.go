@@ -67,6 +67,20 @@ func TraceAndServe(h http.Handler, w http.ResponseWriter, r *http.Request, cfg *
 	span, ctx := httptrace.StartRequestSpan(r, opts...)
 	rw, ddrw := wrapResponseWriter(w)
 	defer func() {
+		if rec := recover(); rec != nil {
+			if rec == http.ErrAbortHandler {
+				// the handler aborted the response on purpose, which is not an error
+				httptrace.FinishRequestSpan(span, ddrw.status, cfg.FinishOpts...)
+				panic(rec)
+			}
+			status := ddrw.status
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			opts := append(cfg.FinishOpts[:len(cfg.FinishOpts):len(cfg.FinishOpts)], tracer.WithPanicCapture(rec))
+			httptrace.FinishRequestSpan(span, status, opts...)
+			panic(rec)
+		}
 		httptrace.FinishRequestSpan(span, ddrw.status, cfg.FinishOpts...)
 	}()
 
@@ -54,6 +54,55 @@ func TestTraceAndServe(t *testing.T) {
 		assert.Equal("503: Service Unavailable", span.Tag(ext.Error).(error).Error())
 	})
 
+	t.Run("panic", func(t *testing.T) {
+		mt := mocktracer.Start()
+		assert := assert.New(t)
+		defer mt.Stop()
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/path", nil)
+		assert.NoError(err)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}
+		assert.PanicsWithValue("boom", func() {
+			TraceAndServe(http.HandlerFunc(handler), w, r, &ServeConfig{})
+		})
+		spans := mt.FinishedSpans()
+		assert.Len(spans, 1)
+		span := spans[0]
+
+		assert.Equal("500", span.Tag(ext.HTTPCode))
+		assert.Equal("panic", span.Tag(ext.ErrorType))
+		assert.Equal("boom", span.Tag(ext.ErrorMsg))
+		assert.Contains(span.Tag(ext.ErrorStack), "TestTraceAndServe")
+		assert.IsType(&tracer.PanicError{}, span.Tag(ext.Error))
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		mt := mocktracer.Start()
+		assert := assert.New(t)
+		defer mt.Stop()
+
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("GET", "/path", nil)
+		assert.NoError(err)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			panic(http.ErrAbortHandler)
+		}
+		assert.PanicsWithValue(http.ErrAbortHandler, func() {
+			TraceAndServe(http.HandlerFunc(handler), w, r, &ServeConfig{})
+		})
+		spans := mt.FinishedSpans()
+		assert.Len(spans, 1)
+		span := spans[0]
+
+		assert.Equal("200", span.Tag(ext.HTTPCode))
+		assert.Nil(span.Tag(ext.ErrorType))
+		assert.Nil(span.Tag(ext.Error))
+	})
+
 	t.Run("custom", func(t *testing.T) {
 		mt := mocktracer.Start()
 		assert := assert.New(t)
@@ -12,7 +12,7 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
-// A messageCarrier implements TextMapReader/TextMapWriter for extracting/injecting traces on a kafka.Message
+// A messageCarrier implements TextMapReader/TextMapWriter and HeadersCarrier for extracting/injecting traces on a kafka.Message
 type messageCarrier struct {
 	msg *kafka.Message
 }
@@ -20,12 +20,20 @@ type messageCarrier struct {
 var _ interface {
 	tracer.TextMapReader
 	tracer.TextMapWriter
+	tracer.HeadersCarrier
 } = (*messageCarrier)(nil)
 
 // ForeachKey conforms to the TextMapReader interface.
 func (c messageCarrier) ForeachKey(handler func(key, val string) error) error {
+	return c.ForeachHeader(func(key string, val []byte) error {
+		return handler(key, string(val))
+	})
+}
+
+// ForeachHeader implements HeadersCarrier
+func (c messageCarrier) ForeachHeader(handler func(key string, val []byte) error) error {
 	for _, h := range c.msg.Headers {
-		err := handler(h.Key, string(h.Value))
+		err := handler(h.Key, h.Value)
 		if err != nil {
 			return err
 		}
@@ -35,6 +43,11 @@ func (c messageCarrier) ForeachKey(handler func(key, val string) error) error {
 
 // Set implements TextMapWriter
 func (c messageCarrier) Set(key, val string) {
+	c.SetHeader(key, []byte(val))
+}
+
+// SetHeader implements HeadersCarrier
+func (c messageCarrier) SetHeader(key string, val []byte) {
 	// ensure uniqueness of keys
 	for i := 0; i < len(c.msg.Headers); i++ {
 		if string(c.msg.Headers[i].Key) == key {
@@ -44,7 +57,7 @@ func (c messageCarrier) Set(key, val string) {
 	}
 	c.msg.Headers = append(c.msg.Headers, kafka.Header{
 		Key:   key,
-		Value: []byte(val),
+		Value: val,
 	})
 }
 
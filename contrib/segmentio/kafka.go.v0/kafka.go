@@ -82,12 +82,12 @@ func (r *Reader) startSpan(ctx context.Context, msg *kafka.Message) ddtrace.Span
 	}
 	// kafka supports headers, so try to extract a span context
 	carrier := messageCarrier{msg}
-	if spanctx, err := tracer.Extract(carrier); err == nil {
+	if spanctx, err := r.cfg.extract(carrier); err == nil {
 		opts = append(opts, tracer.ChildOf(spanctx))
 	}
 	span, _ := tracer.StartSpanFromContext(ctx, r.cfg.consumerSpanName, opts...)
 	// reinject the span context so consumers can pick it up
-	if err := tracer.Inject(span.Context(), carrier); err != nil {
+	if err := r.cfg.inject(span.Context(), carrier); err != nil {
 		log.Debug("contrib/segmentio/kafka.go.v0: Failed to inject span context into carrier in reader, %v", err)
 	}
 	return span
@@ -172,7 +172,7 @@ func (w *Writer) startSpan(ctx context.Context, msg *kafka.Message) ddtrace.Span
 	}
 	carrier := messageCarrier{msg}
 	span, _ := tracer.StartSpanFromContext(ctx, w.cfg.producerSpanName, opts...)
-	if err := tracer.Inject(span.Context(), carrier); err != nil {
+	if err := w.cfg.inject(span.Context(), carrier); err != nil {
 		log.Debug("contrib/segmentio/kafka.go.v0: Failed to inject span context into carrier in writer, %v", err)
 	}
 	return span
@@ -8,12 +8,15 @@ package kafka
 import (
 	"context"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/contrib/internal/namingschematest"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	kafka "github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
@@ -177,6 +180,44 @@ func TestFetchMessageFunctional(t *testing.T) {
 	assert.Equal(t, "localhost:9092,localhost:9093,localhost:9094", s1.Tag(ext.KafkaBootstrapServers))
 }
 
+// headerPropagator is a tracer.Propagator injecting the span ID into a single header.
+type headerPropagator struct{}
+
+func (headerPropagator) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	carrier.(tracer.TextMapWriter).Set("x-custom-span-id", strconv.FormatUint(ctx.SpanID(), 10))
+	return nil
+}
+
+func (headerPropagator) Extract(_ interface{}) (ddtrace.SpanContext, error) {
+	return nil, tracer.ErrSpanContextNotFound
+}
+
+func TestWriterPropagator(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	kw := &kafka.Writer{
+		Addr:  kafka.TCP("localhost:9092"),
+		Topic: testTopic,
+	}
+	w := WrapWriter(kw, WithPropagator(headerPropagator{}))
+
+	msg := kafka.Message{
+		Key:   []byte("key1"),
+		Value: []byte("value1"),
+	}
+	w.startSpan(context.Background(), &msg).Finish()
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	assert.Equal(t, strconv.FormatUint(spans[0].SpanID(), 10), headers["x-custom-span-id"])
+	assert.NotContains(t, headers, tracer.DefaultTraceIDHeader)
+}
+
 func TestNamingSchema(t *testing.T) {
 	genSpans := func(t *testing.T, serviceOverride string) []mocktracer.Span {
 		var opts []Option
@@ -49,9 +49,40 @@ type TextMapReader interface {
 	ForeachKey(handler func(key, val string) error) error
 }
 
-// ExtractFromBase64Carrier extracts the pathway context from a carrier to a context object
+// binaryHeadersCarrier is implemented by carriers which store headers having binary
+// values, such as Kafka record headers. It has the same methods as tracer.HeadersCarrier.
+type binaryHeadersCarrier interface {
+	// SetHeader sets the header key to val, replacing any existing header with the same key.
+	SetHeader(key string, val []byte)
+
+	// ForeachHeader iterates over all headers that exist in the underlying carrier,
+	// returning the first error returned by the handler.
+	ForeachHeader(handler func(key string, val []byte) error) error
+}
+
+// ExtractFromBase64Carrier extracts the pathway context from a carrier to a context object.
+// Carriers having binary headers are read from the raw pathway header, or from the base64
+// one if it is missing.
 func ExtractFromBase64Carrier(ctx context.Context, carrier TextMapReader) (outCtx context.Context) {
 	outCtx = ctx
+	if hc, ok := carrier.(binaryHeadersCarrier); ok {
+		var raw, encoded []byte
+		hc.ForeachHeader(func(key string, val []byte) error {
+			switch key {
+			case datastreams.PropagationKey:
+				raw = val
+			case datastreams.PropagationKeyBase64:
+				encoded = val
+			}
+			return nil
+		})
+		if raw != nil {
+			_, outCtx, _ = datastreams.Decode(ctx, raw)
+		} else if encoded != nil {
+			_, outCtx, _ = datastreams.DecodeBase64(ctx, string(encoded))
+		}
+		return outCtx
+	}
 	carrier.ForeachKey(func(key, val string) error {
 		if key == datastreams.PropagationKeyBase64 {
 			_, outCtx, _ = datastreams.DecodeBase64(ctx, val)
@@ -61,11 +92,16 @@ func ExtractFromBase64Carrier(ctx context.Context, carrier TextMapReader) (outCt
 	return outCtx
 }
 
-// InjectToBase64Carrier injects a pathway context from a context object inta a carrier
+// InjectToBase64Carrier injects a pathway context from a context object inta a carrier.
+// Carriers having binary headers are given the raw pathway, without base64 encoding.
 func InjectToBase64Carrier(ctx context.Context, carrier TextMapWriter) {
 	p, ok := datastreams.PathwayFromContext(ctx)
 	if !ok {
 		return
 	}
+	if hc, ok := carrier.(binaryHeadersCarrier); ok {
+		hc.SetHeader(datastreams.PropagationKey, p.Encode())
+		return
+	}
 	carrier.Set(datastreams.PropagationKeyBase64, p.EncodeBase64())
 }
@@ -43,3 +43,50 @@ func TestBase64Propagation(t *testing.T) {
 	assert.Equal(t, expected.GetHash(), got.GetHash())
 	assert.NotEqual(t, 0, expected.GetHash())
 }
+
+type headersCarrier map[string][]byte
+
+func (c headersCarrier) Set(key, val string) {
+	c[key] = []byte(val)
+}
+
+func (c headersCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, v := range c {
+		if err := handler(k, string(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c headersCarrier) SetHeader(key string, val []byte) {
+	c[key] = val
+}
+
+func (c headersCarrier) ForeachHeader(handler func(key string, val []byte) error) error {
+	for k, v := range c {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestBase64PropagationHeaders(t *testing.T) {
+	c := make(headersCarrier)
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	ctx := context.Background()
+	ctx, _ = tracer.SetDataStreamsCheckpoint(ctx, "direction:out", "type:kafka", "topic:topic1")
+	InjectToBase64Carrier(ctx, c)
+	assert.Contains(t, c, datastreams.PropagationKey)
+	assert.NotContains(t, c, datastreams.PropagationKeyBase64)
+	got, _ := datastreams.PathwayFromContext(ExtractFromBase64Carrier(context.Background(), c))
+	expected, _ := datastreams.PathwayFromContext(ctx)
+	assert.Equal(t, expected.GetHash(), got.GetHash())
+
+	// pathways sent by producers which only set the base64 header are still extracted
+	legacy := headersCarrier{datastreams.PropagationKeyBase64: []byte(expected.EncodeBase64())}
+	got, _ = datastreams.PathwayFromContext(ExtractFromBase64Carrier(context.Background(), legacy))
+	assert.Equal(t, expected.GetHash(), got.GetHash())
+}
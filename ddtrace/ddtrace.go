@@ -144,6 +144,10 @@ type StartSpanConfig struct {
 
 	// Context is the parent context where the span should be stored.
 	Context context.Context
+
+	// SpanLinks represents a collection of links to spans in other traces
+	// which are causally related to the new span.
+	SpanLinks []SpanLink
 }
 
 // Logger implementations are able to log given messages that the tracer or profiler might output.
@@ -90,6 +90,13 @@ const (
 	// ErrorDetails holds details about an error which implements a formatter.
 	ErrorDetails = "error.details"
 
+	// ErrorCauseType specifies the type of the innermost error wrapped by an error.
+	ErrorCauseType = "error.cause.type"
+
+	// ErrorFingerprint specifies a stable identifier for grouping errors, derived from
+	// the types of the wrapped errors and from the top of the error's stack trace.
+	ErrorFingerprint = "error.fingerprint"
+
 	// Environment specifies the environment to use with a trace.
 	Environment = "env"
 
@@ -16,6 +16,8 @@ import (
 )
 
 var _ ddtrace.Span = (*mockspan)(nil)
+var _ ddtrace.SpanWithLinks = (*mockspan)(nil)
+var _ ddtrace.SpanWithEvents = (*mockspan)(nil)
 var _ Span = (*mockspan)(nil)
 
 // Span is an interface that allows querying a span returned by the mock tracer.
@@ -47,6 +49,12 @@ type Span interface {
 	// Context returns the span's SpanContext.
 	Context() ddtrace.SpanContext
 
+	// Links returns a copy of the span links attached to this span.
+	Links() []ddtrace.SpanLink
+
+	// Events returns a copy of the events recorded on this span.
+	Events() []ddtrace.SpanEvent
+
 	// Stringer allows pretty-printing the span's fields for debugging.
 	fmt.Stringer
 }
@@ -62,6 +70,9 @@ func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig)
 		name:   operationName,
 		tracer: t,
 	}
+	if len(cfg.SpanLinks) > 0 {
+		s.links = append([]ddtrace.SpanLink(nil), cfg.SpanLinks...)
+	}
 	if cfg.StartTime.IsZero() {
 		s.startTime = time.Now()
 	} else {
@@ -102,6 +113,8 @@ type mockspan struct {
 	tags         map[string]interface{}
 	finishTime   time.Time
 	finished     bool
+	links        []ddtrace.SpanLink
+	events       []ddtrace.SpanEvent
 
 	startTime time.Time
 	parentID  uint64
@@ -155,6 +168,50 @@ func (s *mockspan) Tags() map[string]interface{} {
 	return cp
 }
 
+// AddLink appends the given link to the span.
+func (s *mockspan) AddLink(link ddtrace.SpanLink) {
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	s.links = append(s.links, link)
+}
+
+func (s *mockspan) Links() []ddtrace.SpanLink {
+	s.RLock()
+	defer s.RUnlock()
+	if len(s.links) == 0 {
+		return nil
+	}
+	// copy
+	cp := make([]ddtrace.SpanLink, len(s.links))
+	copy(cp, s.links)
+	return cp
+}
+
+// AddEvent records an event on the span.
+func (s *mockspan) AddEvent(name string, ts time.Time, attrs map[string]interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	if s.finished || len(s.events) >= ddtrace.MaxSpanEvents {
+		return
+	}
+	s.events = append(s.events, ddtrace.NewSpanEvent(name, ts, attrs))
+}
+
+func (s *mockspan) Events() []ddtrace.SpanEvent {
+	s.RLock()
+	defer s.RUnlock()
+	if len(s.events) == 0 {
+		return nil
+	}
+	// copy
+	cp := make([]ddtrace.SpanEvent, len(s.events))
+	copy(cp, s.events)
+	return cp
+}
+
 func (s *mockspan) TraceID() uint64 { return s.context.traceID }
 
 func (s *mockspan) SpanID() uint64 { return s.context.spanID }
@@ -202,6 +259,13 @@ func (s *mockspan) Finish(opts ...ddtrace.FinishOption) {
 	if cfg.Error != nil {
 		s.SetTag(ext.Error, cfg.Error)
 	}
+	if p, ok := cfg.Error.(*tracer.PanicError); ok {
+		s.SetTag(ext.ErrorType, "panic")
+		s.SetTag(ext.ErrorMsg, p.Error())
+		if !cfg.NoDebugStack {
+			s.SetTag(ext.ErrorStack, string(p.Stack))
+		}
+	}
 	if cfg.NoDebugStack {
 		s.SetTag(ext.ErrorStack, "<debug stack disabled>")
 	}
@@ -208,6 +208,32 @@ func TestSpanWithID(t *testing.T) {
 	assert.Equal(spanID, span.Context().SpanID())
 }
 
+func TestSpanLinks(t *testing.T) {
+	assert := assert.New(t)
+	link := ddtrace.SpanLink{TraceID: 1, TraceIDHigh: 2, SpanID: 3, Attributes: map[string]string{"k": "v"}}
+	span := newMockTracer().StartSpan("", tracer.WithSpanLinks([]ddtrace.SpanLink{link})).(*mockspan)
+	assert.Equal([]ddtrace.SpanLink{link}, span.Links())
+
+	span.AddLink(ddtrace.SpanLink{TraceID: 4, SpanID: 5})
+	span.Finish()
+	span.AddLink(ddtrace.SpanLink{TraceID: 6, SpanID: 7})
+	assert.Equal([]ddtrace.SpanLink{link, {TraceID: 4, SpanID: 5}}, span.Links())
+}
+
+func TestSpanEvents(t *testing.T) {
+	assert := assert.New(t)
+	span := newMockTracer().StartSpan("").(*mockspan)
+	ts := time.Unix(1700000000, 0)
+	span.AddEvent("cache.miss", ts, map[string]interface{}{"k": "v"})
+	span.Finish()
+	span.AddEvent("late", ts, nil)
+	assert.Equal([]ddtrace.SpanEvent{{
+		Name:         "cache.miss",
+		TimeUnixNano: ts.UnixNano(),
+		Attributes:   map[string]interface{}{"k": "v"},
+	}}, span.Events())
+}
+
 func TestSetUser(t *testing.T) {
 	const (
 		id        = "john.doe#12345"
@@ -8,8 +8,11 @@ package opentelemetry
 import (
 	"encoding/binary"
 	"errors"
+	"reflect"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -18,6 +21,7 @@ import (
 
 	"go.opentelemetry.io/otel/attribute"
 	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/noop"
 )
@@ -25,12 +29,13 @@ import (
 var _ oteltrace.Span = (*span)(nil)
 
 type span struct {
-	noop.Span  // https://pkg.go.dev/go.opentelemetry.io/otel/trace#hdr-API_Implementations
-	DD         tracer.Span
-	finished   bool
-	attributes map[string]interface{}
-	spanKind   oteltrace.SpanKind
-	finishOpts []tracer.FinishOption
+	noop.Span   // https://pkg.go.dev/go.opentelemetry.io/otel/trace#hdr-API_Implementations
+	DD          tracer.Span
+	finished    bool
+	attributes  map[string]interface{}
+	spanKind    oteltrace.SpanKind
+	finishOpts  []tracer.FinishOption
+	errRecorded bool // reports whether RecordError has set the error tags on the span
 	statusInfo
 	*oteltracer
 }
@@ -69,7 +74,12 @@ func (s *span) End(options ...oteltrace.SpanEndOption) {
 	var opts []tracer.FinishOption
 	if s.statusInfo.code == otelcodes.Error {
 		s.DD.SetTag(ext.ErrorMsg, s.statusInfo.description)
-		opts = append(opts, tracer.WithError(errors.New(s.statusInfo.description)))
+		if s.errRecorded {
+			// keep the error type and stack set by RecordError, only flag the span
+			s.DD.SetTag(ext.Error, true)
+		} else {
+			opts = append(opts, tracer.WithError(errors.New(s.statusInfo.description)))
+		}
 	}
 	if t := finishCfg.Timestamp(); !t.IsZero() {
 		opts = append(opts, tracer.FinishTime(t))
@@ -146,6 +156,58 @@ func (s *span) IsRecording() bool {
 	return !s.finished
 }
 
+// AddEvent adds an event with the provided name and options to the span.
+// Events are sent as part of the span when it ends.
+func (s *span) AddEvent(name string, options ...oteltrace.EventOption) {
+	if !s.IsRecording() {
+		return
+	}
+	c := oteltrace.NewEventConfig(options...)
+	s.addEvent(name, c.Timestamp(), c.Attributes())
+}
+
+// addEvent records the event on the underlying Datadog span, which sends it
+// as part of the span when it finishes.
+func (s *span) addEvent(name string, ts time.Time, attrs []attribute.KeyValue) {
+	ev, ok := s.DD.(ddtrace.SpanWithEvents)
+	if !ok {
+		return
+	}
+	var m map[string]interface{}
+	if len(attrs) > 0 {
+		m = make(map[string]interface{}, len(attrs))
+		for _, a := range attrs {
+			m[string(a.Key)] = a.Value.AsInterface()
+		}
+	}
+	ev.AddEvent(name, ts, m)
+}
+
+// RecordError records err as an "exception" span event and sets the
+// error.message, error.type and error.stack tags on the span. As per the
+// OpenTelemetry specification, it does not change the status of the span:
+// SetStatus must be used to mark the span as erroneous.
+func (s *span) RecordError(err error, options ...oteltrace.EventOption) {
+	if !s.IsRecording() || err == nil {
+		return
+	}
+	c := oteltrace.NewEventConfig(options...)
+	typ := reflect.TypeOf(err).String()
+	stack := string(debug.Stack())
+	attrs := append(c.Attributes(),
+		semconv.ExceptionType(typ),
+		semconv.ExceptionMessage(err.Error()),
+	)
+	if c.StackTrace() {
+		attrs = append(attrs, semconv.ExceptionStacktrace(stack))
+	}
+	s.addEvent(semconv.ExceptionEventName, c.Timestamp(), attrs)
+	s.DD.SetTag(ext.ErrorMsg, err.Error())
+	s.DD.SetTag(ext.ErrorType, typ)
+	s.DD.SetTag(ext.ErrorStack, stack)
+	s.errRecorded = true
+}
+
 type statusInfo struct {
 	code        otelcodes.Code
 	description string
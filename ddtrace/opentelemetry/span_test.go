@@ -8,14 +8,17 @@ package opentelemetry
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/httpmem"
@@ -227,6 +230,114 @@ func TestSpanSetStatus(t *testing.T) {
 	}
 }
 
+// decodeSpanMeta returns the meta of the first span found in the given JSON payload.
+func decodeSpanMeta(t *testing.T, payload string) map[string]string {
+	var traces [][]struct {
+		Meta map[string]string `json:"meta"`
+	}
+	if err := json.Unmarshal([]byte(payload), &traces); err != nil {
+		t.Fatalf("Unable to decode payload: %v", err)
+	}
+	if len(traces) == 0 || len(traces[0]) == 0 {
+		t.Fatalf("No spans in payload")
+	}
+	return traces[0][0].Meta
+}
+
+func TestSpanAddEvent(t *testing.T) {
+	assert := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, payloads, cleanup := mockTracerProvider(t)
+	tr := otel.Tracer("")
+	defer cleanup()
+
+	ts := time.Unix(0, 1698000000000000000)
+	_, sp := tr.Start(context.Background(), "test")
+	sp.AddEvent("cache.miss", oteltrace.WithTimestamp(ts), oteltrace.WithAttributes(
+		attribute.String("cache.key", "user:42"),
+		attribute.Int("attempt", 2),
+	))
+	sp.AddEvent("retry")
+	sp.End()
+	// events added after the span has ended are ignored
+	sp.AddEvent("ignored")
+
+	tracer.Flush()
+	payload, err := waitForPayload(ctx, payloads)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var events []ddtrace.SpanEvent
+	assert.NoError(json.Unmarshal([]byte(decodeSpanMeta(t, payload)["events"]), &events))
+	assert.Len(events, 2)
+	assert.Equal("cache.miss", events[0].Name)
+	assert.Equal(ts.UnixNano(), events[0].TimeUnixNano)
+	assert.Equal(map[string]interface{}{"cache.key": "user:42", "attempt": float64(2)}, events[0].Attributes)
+	assert.Equal("retry", events[1].Name)
+	assert.NotZero(events[1].TimeUnixNano)
+	assert.Nil(events[1].Attributes)
+}
+
+func TestSpanRecordError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, payloads, cleanup := mockTracerProvider(t)
+	tr := otel.Tracer("")
+	defer cleanup()
+
+	t.Run("no-status", func(t *testing.T) {
+		assert := assert.New(t)
+		_, sp := tr.Start(context.Background(), "test")
+		sp.RecordError(errors.New("boom"), oteltrace.WithStackTrace(true))
+		sp.RecordError(nil)
+		sp.End()
+
+		tracer.Flush()
+		payload, err := waitForPayload(ctx, payloads)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		meta := decodeSpanMeta(t, payload)
+		assert.Equal("boom", meta[ext.ErrorMsg])
+		assert.Equal("*errors.errorString", meta[ext.ErrorType])
+		assert.Contains(meta[ext.ErrorStack], "TestSpanRecordError")
+		// recording an error does not change the status of the span
+		assert.Contains(payload, `"error":0`)
+
+		var events []ddtrace.SpanEvent
+		assert.NoError(json.Unmarshal([]byte(meta["events"]), &events))
+		assert.Len(events, 1)
+		assert.Equal("exception", events[0].Name)
+		assert.Equal("boom", events[0].Attributes["exception.message"])
+		assert.Equal("*errors.errorString", events[0].Attributes["exception.type"])
+		assert.Contains(events[0].Attributes["exception.stacktrace"], "TestSpanRecordError")
+	})
+
+	t.Run("error-status", func(t *testing.T) {
+		assert := assert.New(t)
+		_, sp := tr.Start(context.Background(), "test")
+		sp.RecordError(&net.AddrError{Err: "bad address", Addr: "localhost"})
+		sp.SetStatus(codes.Error, "lookup failed")
+		sp.End()
+
+		tracer.Flush()
+		payload, err := waitForPayload(ctx, payloads)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		meta := decodeSpanMeta(t, payload)
+		assert.Equal("lookup failed", meta[ext.ErrorMsg])
+		assert.Equal("*net.AddrError", meta[ext.ErrorType])
+		assert.Contains(payload, `"error":1`)
+
+		var events []ddtrace.SpanEvent
+		assert.NoError(json.Unmarshal([]byte(meta["events"]), &events))
+		assert.Len(events, 1)
+		assert.NotContains(events[0].Attributes, "exception.stacktrace")
+	})
+}
+
 func TestSpanContextWithStartOptions(t *testing.T) {
 	assert := assert.New(t)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
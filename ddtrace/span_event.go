@@ -0,0 +1,56 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package ddtrace
+
+import "time"
+
+// MaxSpanEvents is the maximum number of events which can be recorded on a single span.
+// Events added beyond this limit are dropped.
+const MaxSpanEvents = 128
+
+// SpanEvent represents a timestamped event which occurred during the lifetime of a span,
+// such as a cache miss, a retry attempt or a circuit breaker opening.
+type SpanEvent struct {
+	// Name is the name of the event.
+	Name string `json:"name"`
+	// TimeUnixNano is the time at which the event occurred, in nanoseconds since the epoch.
+	TimeUnixNano int64 `json:"time_unix_nano"`
+	// Attributes holds additional context about the event. Values should be strings,
+	// booleans, numbers or slices of these.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// SpanWithEvents is implemented by spans which can record timestamped events.
+type SpanWithEvents interface {
+	Span
+
+	// AddEvent records an event with the given name and attributes which occurred
+	// at ts, or now if ts is zero. Events added after the span has finished, or
+	// beyond MaxSpanEvents, are ignored.
+	AddEvent(name string, ts time.Time, attrs map[string]interface{})
+
+	// Events returns a copy of the events recorded on the span.
+	Events() []SpanEvent
+}
+
+// NewSpanEvent returns a SpanEvent with the given name and attributes which occurred at
+// ts, or now if ts is zero. The attributes are copied.
+func NewSpanEvent(name string, ts time.Time, attrs map[string]interface{}) SpanEvent {
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	e := SpanEvent{
+		Name:         name,
+		TimeUnixNano: ts.UnixNano(),
+	}
+	if len(attrs) > 0 {
+		e.Attributes = make(map[string]interface{}, len(attrs))
+		for k, v := range attrs {
+			e.Attributes[k] = v
+		}
+	}
+	return e
+}
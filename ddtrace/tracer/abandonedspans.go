@@ -7,14 +7,23 @@ package tracer
 
 import (
 	"container/list"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 )
 
 var (
@@ -22,6 +31,17 @@ var (
 	logSize        = 9000
 )
 
+// creationStackDepth is the maximum number of frames captured in the creation stack
+// of spans.
+const creationStackDepth = 32
+
+// startSpanFuncs holds the functions of the tracer API starting spans, whose frames
+// are omitted from the top of creation stacks.
+var startSpanFuncs = map[string]bool{
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer.StartSpan":            true,
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer.StartSpanFromContext": true,
+}
+
 // bucket is a not thread-safe generic implementation of a dynamic collection of elements
 // stored under a value-bound key (like time). Inspired by concentrator.rawBucket.
 type bucket[K comparable, T any] struct {
@@ -74,9 +94,15 @@ func (b *bucket[K, T]) Len() int {
 // spans that can be abandoned.
 type abandonedSpanCandidate struct {
 	Name            string
+	Resource        string
+	Integration     string
 	TraceID, SpanID uint64
 	Start           int64
 	Finished        bool
+
+	// pcs holds the program counters of the stack which created the span, when it
+	// was captured. They are only symbolized when the span is reported.
+	pcs []uintptr
 }
 
 func newAbandonedSpanCandidate(s *span, finished bool) *abandonedSpanCandidate {
@@ -84,12 +110,18 @@ func newAbandonedSpanCandidate(s *span, finished bool) *abandonedSpanCandidate {
 	// at the moment of calling this method.
 	// Also, locking is not required as it's called while the span is already locked or it's
 	// being initialized.
+	integration := s.Meta[ext.Component]
+	if integration == "" {
+		integration = "manual"
+	}
 	return &abandonedSpanCandidate{
-		Name:     s.Name,
-		TraceID:  s.TraceID,
-		SpanID:   s.SpanID,
-		Start:    s.Start,
-		Finished: finished,
+		Name:        s.Name,
+		Resource:    s.Resource,
+		Integration: integration,
+		TraceID:     s.TraceID,
+		SpanID:      s.SpanID,
+		Start:       s.Start,
+		Finished:    finished,
 	}
 }
 
@@ -97,9 +129,33 @@ func newAbandonedSpanCandidate(s *span, finished bool) *abandonedSpanCandidate {
 func (s *abandonedSpanCandidate) String() string {
 	age := now() - s.Start
 	a := fmt.Sprintf("%d sec", age/1e9)
+	if stack := s.creationStack(1); len(stack) > 0 {
+		return fmt.Sprintf("[name: %s, span_id: %d, trace_id: %d, age: %s, created at: %s],", s.Name, s.SpanID, s.TraceID, a, stack[0])
+	}
 	return fmt.Sprintf("[name: %s, span_id: %d, trace_id: %d, age: %s],", s.Name, s.SpanID, s.TraceID, a)
 }
 
+// creationStack returns up to n frames of the stack which created the span, as
+// "function (file:line)" entries, or nil if it was not captured. If n is 0, all
+// frames are returned.
+func (s *abandonedSpanCandidate) creationStack(n int) []string {
+	if len(s.pcs) == 0 {
+		return nil
+	}
+	var stack []string
+	frames := runtime.CallersFrames(s.pcs)
+	for {
+		frame, more := frames.Next()
+		if len(stack) > 0 || !startSpanFuncs[frame.Function] {
+			stack = append(stack, fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line))
+		}
+		if !more || len(stack) == n {
+			break
+		}
+	}
+	return stack
+}
+
 type abandonedSpansDebugger struct {
 	// buckets holds all the potentially abandoned tracked spans sharded by the configured interval.
 	buckets map[int64]*bucket[uint64, *abandonedSpanCandidate]
@@ -107,6 +163,15 @@ type abandonedSpansDebugger struct {
 	// In takes candidate spans and adds them to the debugger.
 	In chan *abandonedSpanCandidate
 
+	// queries takes requests for the spans tracked by the debugger.
+	queries chan abandonedSpansQuery
+
+	// statsd, when set, is used to report the number of abandoned spans by integration.
+	statsd globalinternal.StatsdClient
+
+	// reported holds the integrations for which abandoned spans were reported.
+	reported map[string]struct{}
+
 	// waits for any active goroutines
 	wg sync.WaitGroup
 
@@ -124,8 +189,10 @@ type abandonedSpansDebugger struct {
 // newAbandonedSpansDebugger creates a new abandonedSpansDebugger debugger
 func newAbandonedSpansDebugger() *abandonedSpansDebugger {
 	d := &abandonedSpansDebugger{
-		buckets: make(map[int64]*bucket[uint64, *abandonedSpanCandidate]),
-		In:      make(chan *abandonedSpanCandidate, 10000),
+		buckets:  make(map[int64]*bucket[uint64, *abandonedSpanCandidate]),
+		In:       make(chan *abandonedSpanCandidate, 10000),
+		queries:  make(chan abandonedSpansQuery),
+		reported: make(map[string]struct{}),
 	}
 	atomic.SwapUint32(&d.stopped, 1)
 	return d
@@ -156,6 +223,9 @@ func (d *abandonedSpansDebugger) runConsumer(tick *time.Ticker, interval *time.D
 		select {
 		case <-tick.C:
 			d.log(interval)
+			d.reportMetrics(*interval)
+		case q := <-d.queries:
+			q.reply <- d.find(q.minAge)
 		case s := <-d.In:
 			if s.Finished {
 				d.remove(s, *interval)
@@ -298,3 +368,174 @@ func formatAbandonedSpans(b *bucket[uint64, *abandonedSpanCandidate], interval *
 	}
 	return sb.String(), spanCount
 }
+
+// find returns the tracked spans which are older than minAge, oldest first.
+func (d *abandonedSpansDebugger) find(minAge time.Duration) []*abandonedSpanCandidate {
+	curTime := now()
+	keys := make([]int64, 0, len(d.buckets))
+	for k := range d.buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	var spans []*abandonedSpanCandidate
+	for _, k := range keys {
+		b := d.buckets[k]
+		if curTime-int64(b.start) < minAge.Nanoseconds() {
+			// this bucket and the following ones only hold younger spans
+			break
+		}
+		for e := b.data.Front(); e != nil; e = e.Next() {
+			if s := e.Value.(*abandonedSpanCandidate); curTime-s.Start >= minAge.Nanoseconds() {
+				spans = append(spans, s)
+			}
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start < spans[j].Start
+	})
+	return spans
+}
+
+// reportMetrics reports the number of spans older than interval by integration, when
+// the debugger has a statsd client. Integrations which no longer have abandoned spans
+// are reported once with a zero value.
+func (d *abandonedSpansDebugger) reportMetrics(interval time.Duration) {
+	if d.statsd == nil {
+		return
+	}
+	counts := make(map[string]int)
+	for _, s := range d.find(interval) {
+		counts[s.Integration]++
+	}
+	for integration := range d.reported {
+		if _, ok := counts[integration]; !ok {
+			counts[integration] = 0
+		}
+	}
+	for integration, n := range counts {
+		d.statsd.Gauge("datadog.tracer.abandoned_spans", float64(n), []string{"integration:" + integration}, 1)
+		telemetry.GlobalClient.Record(telemetry.NamespaceTracers, telemetry.MetricKindGauge, "spans_abandoned", float64(n), []string{"integration_name:" + integration}, true)
+		if n == 0 {
+			delete(d.reported, integration)
+		} else {
+			d.reported[integration] = struct{}{}
+		}
+	}
+}
+
+// abandonedSpansQuery is a request for the spans tracked by the debugger which are
+// older than minAge.
+type abandonedSpansQuery struct {
+	minAge time.Duration
+	reply  chan []*abandonedSpanCandidate
+}
+
+// query returns the tracked spans which are older than minAge, oldest first. It
+// returns false if the debugger is stopped or ctx is done before the spans are found.
+func (d *abandonedSpansDebugger) query(ctx context.Context, minAge time.Duration) ([]*abandonedSpanCandidate, bool) {
+	if atomic.LoadUint32(&d.stopped) > 0 {
+		return nil, false
+	}
+	q := abandonedSpansQuery{minAge: minAge, reply: make(chan []*abandonedSpanCandidate, 1)}
+	select {
+	case d.queries <- q:
+		return <-q.reply, true
+	case <-d.stop:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// abandonedSpansLimit is the default maximum number of spans returned by
+// AbandonedSpansHandler.
+const abandonedSpansLimit = 1000
+
+// abandonedSpansReport is the JSON response of AbandonedSpansHandler.
+type abandonedSpansReport struct {
+	// Count is the number of open spans older than the requested age, which may be
+	// larger than the number of spans returned.
+	Count int                 `json:"count"`
+	Spans []abandonedSpanInfo `json:"spans"`
+}
+
+// abandonedSpanInfo describes an open span in an abandonedSpansReport.
+type abandonedSpanInfo struct {
+	Operation   string   `json:"operation"`
+	Resource    string   `json:"resource"`
+	Integration string   `json:"integration"`
+	TraceID     string   `json:"trace_id"`
+	SpanID      string   `json:"span_id"`
+	Start       string   `json:"start"`
+	AgeSeconds  float64  `json:"age_seconds"`
+	Stack       []string `json:"stack,omitempty"`
+}
+
+// AbandonedSpansHandler returns an http.Handler reporting, as JSON, the spans of the
+// started tracer which are still open, oldest first. It is meant to be mounted on an
+// administration endpoint to investigate spans that were never finished, and is only
+// available when the tracer is started with WithDebugSpansMode.
+//
+// The min_age query parameter, a duration such as "5m", sets the minimum age of the
+// reported spans and defaults to the timeout given to WithDebugSpansMode. The limit
+// query parameter sets the maximum number of reported spans and defaults to 1000.
+// Each span is described by its operation, resource, integration, IDs, start time and
+// age, along with its creation stack when captured as configured by
+// WithDebugSpansCreationStacks. The operation, resource and integration are those at the
+// time the span started.
+func AbandonedSpansHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, ok := internal.GetGlobalTracer().(*tracer)
+		if !ok || t.abandonedSpansDebugger == nil {
+			http.Error(w, "abandoned spans debugging is not enabled", http.StatusNotFound)
+			return
+		}
+		minAge := t.config.spanTimeout
+		if v := r.URL.Query().Get("min_age"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				http.Error(w, fmt.Sprintf("invalid min_age %q", v), http.StatusBadRequest)
+				return
+			}
+			minAge = d
+		}
+		limit := abandonedSpansLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		spans, ok := t.abandonedSpansDebugger.query(r.Context(), minAge)
+		if !ok {
+			http.Error(w, "abandoned spans debugger is not running", http.StatusServiceUnavailable)
+			return
+		}
+		report := abandonedSpansReport{Count: len(spans)}
+		if len(spans) > limit {
+			spans = spans[:limit]
+		}
+		report.Spans = make([]abandonedSpanInfo, 0, len(spans))
+		curTime := now()
+		for _, s := range spans {
+			report.Spans = append(report.Spans, abandonedSpanInfo{
+				Operation:   s.Name,
+				Resource:    s.Resource,
+				Integration: s.Integration,
+				TraceID:     strconv.FormatUint(s.TraceID, 10),
+				SpanID:      strconv.FormatUint(s.SpanID, 10),
+				Start:       time.Unix(0, s.Start).UTC().Format(time.RFC3339Nano),
+				AgeSeconds:  float64(curTime-s.Start) / 1e9,
+				Stack:       s.creationStack(0),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Debug("Error writing abandoned spans report: %v", err)
+		}
+	})
+}
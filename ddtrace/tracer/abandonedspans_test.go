@@ -6,16 +6,21 @@
 package tracer
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var warnPrefix = fmt.Sprintf("Datadog Tracer %v WARN: ", version.Tag)
@@ -258,3 +263,174 @@ func TestDebugAbandonedSpansOff(t *testing.T) {
 		s.Finish()
 	})
 }
+
+func TestAbandonedSpansHandler(t *testing.T) {
+	get := func(t *testing.T, query string) (int, abandonedSpansReport) {
+		rec := httptest.NewRecorder()
+		AbandonedSpansHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/abandoned-spans"+query, nil))
+		var report abandonedSpansReport
+		if rec.Code == http.StatusOK {
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		}
+		return rec.Code, report
+	}
+
+	t.Run("on", func(t *testing.T) {
+		assert := assert.New(t)
+		defer setTestTime()()
+		tracer, _, _, stop := startTestTracer(t, WithDebugSpansMode(5*time.Minute))
+		defer stop()
+		old := tracer.StartSpan("http.request", StartTime(spanStart), ResourceName("GET /"), Tag(ext.Component, "net/http")).(*span)
+		tracer.StartSpan("recent", StartTime(spanStart.Add(8*time.Minute)))
+		tracer.StartSpan("finished", StartTime(spanStart)).Finish()
+		d := tracer.abandonedSpansDebugger
+		assert.Eventually(func() bool {
+			return atomic.LoadUint32(&d.addedSpans) == 3 && atomic.LoadUint32(&d.removedSpans) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		code, report := get(t, "")
+		assert.Equal(http.StatusOK, code)
+		assert.Equal(1, report.Count)
+		require.Len(t, report.Spans, 1)
+		assert.Equal(abandonedSpanInfo{
+			Operation:   "http.request",
+			Resource:    "GET /",
+			Integration: "net/http",
+			TraceID:     fmt.Sprint(old.TraceID),
+			SpanID:      fmt.Sprint(old.SpanID),
+			Start:       "2023-08-18T00:00:00Z",
+			AgeSeconds:  600,
+		}, report.Spans[0])
+
+		_, report = get(t, "?min_age=1m")
+		assert.Equal(2, report.Count)
+		require.Len(t, report.Spans, 2)
+		assert.Equal("http.request", report.Spans[0].Operation)
+		assert.Equal("recent", report.Spans[1].Operation)
+		assert.Equal("manual", report.Spans[1].Integration)
+
+		_, report = get(t, "?min_age=0s&limit=1")
+		assert.Equal(2, report.Count)
+		assert.Len(report.Spans, 1)
+
+		code, _ = get(t, "?min_age=forever")
+		assert.Equal(http.StatusBadRequest, code)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		_, _, _, stop := startTestTracer(t)
+		defer stop()
+		code, _ := get(t, "")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func TestAbandonedSpansMetrics(t *testing.T) {
+	assert := assert.New(t)
+	defer func(old time.Duration) { tickerInterval = old }(tickerInterval)
+	tickerInterval = 10 * time.Millisecond
+	defer setTestTime()()
+	var tg testStatsdClient
+	tracer, _, _, stop := startTestTracer(t, WithDebugSpansMode(time.Minute), WithAbandonedSpansMetrics(true), withStatsdClient(&tg))
+	defer stop()
+
+	gauge := func(integration string) (float64, bool) {
+		var (
+			v  float64
+			ok bool
+		)
+		for _, c := range tg.GaugeCalls() {
+			if c.name == "datadog.tracer.abandoned_spans" && len(c.tags) == 1 && c.tags[0] == "integration:"+integration {
+				v, ok = c.floatVal, true
+			}
+		}
+		return v, ok
+	}
+	s1 := tracer.StartSpan("http.request", StartTime(spanStart), Tag(ext.Component, "net/http"))
+	s2 := tracer.StartSpan("http.request", StartTime(spanStart), Tag(ext.Component, "net/http"))
+	tracer.StartSpan("manual", StartTime(spanStart))
+	assert.Eventually(func() bool {
+		n, _ := gauge("net/http")
+		manual, _ := gauge("manual")
+		return n == 2 && manual == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// integrations without abandoned spans are reported once as zero
+	s1.Finish()
+	s2.Finish()
+	assert.Eventually(func() bool {
+		v, ok := gauge("net/http")
+		return ok && v == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// startLeakySpan starts a span which is never finished.
+func startLeakySpan(tracer *tracer, name string) {
+	tracer.StartSpan(name, StartTime(spanStart))
+}
+
+func TestAbandonedSpansCreationStack(t *testing.T) {
+	report := func(t *testing.T, tracer *tracer, n uint32) abandonedSpansReport {
+		d := tracer.abandonedSpansDebugger
+		assert.Eventually(t, func() bool {
+			return atomic.LoadUint32(&d.addedSpans) == n
+		}, time.Second, 10*time.Millisecond)
+		rec := httptest.NewRecorder()
+		AbandonedSpansHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/?min_age=0s", nil))
+		var report abandonedSpansReport
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		return report
+	}
+
+	t.Run("on", func(t *testing.T) {
+		assert := assert.New(t)
+		tp := new(log.RecordLogger)
+		defer setTestTime()()
+		tracer, _, _, stop := startTestTracer(t, WithLogger(tp), WithDebugSpansMode(time.Minute), WithDebugSpansCreationStacks(1))
+		startLeakySpan(tracer, "leaky")
+		r := report(t, tracer, 1)
+		require.Len(t, r.Spans, 1)
+		stack := r.Spans[0].Stack
+		require.NotEmpty(t, stack)
+		assert.True(strings.HasPrefix(stack[0], "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer.startLeakySpan ("), stack[0])
+		assert.Contains(stack[0], "abandonedspans_test.go:")
+		assert.Contains(stack[1], "TestAbandonedSpansCreationStack")
+
+		stop()
+		assert.Contains(strings.Join(tp.Logs(), "\n"), ", created at: "+stack[0]+"],")
+	})
+
+	t.Run("off", func(t *testing.T) {
+		defer setTestTime()()
+		tracer, _, _, stop := startTestTracer(t, WithDebugSpansMode(time.Minute))
+		defer stop()
+		startLeakySpan(tracer, "leaky")
+		r := report(t, tracer, 1)
+		require.Len(t, r.Spans, 1)
+		assert.Nil(t, r.Spans[0].Stack)
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		defer setTestTime()()
+		tracer, _, _, stop := startTestTracer(t, WithDebugSpansMode(time.Minute), WithDebugSpansCreationStacks(0.5))
+		defer stop()
+		for i := 0; i < 200; i++ {
+			startLeakySpan(tracer, "leaky")
+		}
+		r := report(t, tracer, 200)
+		var withStack int
+		for _, s := range r.Spans {
+			if len(s.Stack) > 0 {
+				withStack++
+			}
+		}
+		assert.Greater(t, withStack, 0)
+		assert.Less(t, withStack, 200)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_DEBUG_ABANDONED_SPANS_STACK_RATE", "0.1")
+		assert.Equal(t, 0.1, newConfig().abandonedSpansStackRate)
+	})
+}
@@ -0,0 +1,211 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+var (
+	// retryBackoffBase is the delay before the first retry of a failed upload. It
+	// doubles with every subsequent attempt.
+	retryBackoffBase = 100 * time.Millisecond
+
+	// retryBackoffMax is the maximum delay between two attempts of the same upload.
+	retryBackoffMax = 5 * time.Second
+
+	// breakerCooldownBase is the minimum amount of time during which uploads are
+	// suspended once the agent reports being overloaded. It doubles every time the
+	// agent is still overloaded once the cooldown expires.
+	breakerCooldownBase = time.Second
+
+	// breakerCooldownMax is the maximum amount of time during which uploads are
+	// suspended once the agent reports being overloaded.
+	breakerCooldownMax = time.Minute
+)
+
+// errAgentOverloaded is returned instead of sending a payload when the agent
+// recently reported being overloaded.
+var errAgentOverloaded = errors.New("agent is overloaded, upload skipped")
+
+// retryDelay returns how long to wait before the given retry attempt (starting at 0)
+// of an upload which failed with err. The delay grows exponentially with jitter and
+// honors any Retry-After duration sent by the agent, up to retryBackoffMax.
+func retryDelay(attempt int, err error) time.Duration {
+	d := retryBackoffBase
+	for i := 0; i < attempt && d < retryBackoffMax; i++ {
+		d *= 2
+	}
+	if d > retryBackoffMax {
+		d = retryBackoffMax
+	}
+	// "equal jitter": wait between d/2 and d, so that concurrent clients spread out
+	d = d/2 + time.Duration(random.Int63n(int64(d/2)+1))
+	var herr *httpStatusError
+	if errors.As(err, &herr) && herr.retryAfter > d {
+		d = herr.retryAfter
+		if d > retryBackoffMax {
+			d = retryBackoffMax
+		}
+	}
+	return d
+}
+
+// isOverloaded reports whether err signals that the agent is overloaded and
+// uploads should be suspended for a while.
+func isOverloaded(err error) bool {
+	var herr *httpStatusError
+	if !errors.As(err, &herr) {
+		return false
+	}
+	return herr.code == http.StatusTooManyRequests || herr.code == http.StatusServiceUnavailable
+}
+
+// breakerState is the state of an agentBreaker.
+type breakerState int
+
+const (
+	// breakerClosed is the normal state, where all uploads go through.
+	breakerClosed breakerState = iota
+	// breakerOpen is the state in which the agent is overloaded and uploads are skipped.
+	breakerOpen
+	// breakerHalfOpen is the state in which the cooldown has expired and uploads are
+	// attempted again to find out whether the agent has recovered.
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// agentBreaker is a circuit breaker which suspends uploads to the agent while it
+// reports being overloaded (HTTP 429 or 503). While the breaker is not closed,
+// the tracer also sheds traces which the agent would not keep (P0 traces).
+//
+// All methods are safe to call on a nil *agentBreaker, which never opens.
+type agentBreaker struct {
+	// statsd is used to report state transitions.
+	statsd internal.StatsdClient
+
+	mu        sync.Mutex    // guards below fields
+	state     breakerState  // current state
+	openUntil time.Time     // when the breaker is open, the time at which it becomes half-open
+	cooldown  time.Duration // the duration of the next open period
+}
+
+// newAgentBreaker returns a new, closed agentBreaker reporting its state
+// transitions to statsd.
+func newAgentBreaker(statsd internal.StatsdClient) *agentBreaker {
+	return &agentBreaker{
+		statsd:   statsd,
+		cooldown: breakerCooldownBase,
+	}
+}
+
+// allow reports whether an upload may be attempted.
+func (b *agentBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerOpen {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.setState(breakerHalfOpen)
+	return true
+}
+
+// suspended reports whether uploads are currently suspended. Unlike allow, it
+// never changes the state of the breaker.
+func (b *agentBreaker) suspended() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerOpen && time.Now().Before(b.openUntil)
+}
+
+// shedding reports whether traces which the agent would drop anyway should be
+// discarded by the tracer, because the agent is overloaded.
+func (b *agentBreaker) shedding() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != breakerClosed
+}
+
+// success records a successful upload, closing the breaker.
+func (b *agentBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerClosed {
+		return
+	}
+	b.cooldown = breakerCooldownBase
+	b.setState(breakerClosed)
+}
+
+// failure records an upload which failed with err. The breaker opens if err
+// signals that the agent is overloaded, for at least as long as requested by its
+// Retry-After header.
+func (b *agentBreaker) failure(err error) {
+	if b == nil || !isOverloaded(err) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	d := b.cooldown
+	var herr *httpStatusError
+	if errors.As(err, &herr) && herr.retryAfter > d {
+		d = herr.retryAfter
+	}
+	if d > breakerCooldownMax {
+		d = breakerCooldownMax
+	}
+	b.openUntil = time.Now().Add(d)
+	if b.cooldown *= 2; b.cooldown > breakerCooldownMax {
+		b.cooldown = breakerCooldownMax
+	}
+	if b.state != breakerOpen {
+		log.Warn("Agent is overloaded, suspending uploads for %s: %v", d, err)
+		b.setState(breakerOpen)
+	}
+}
+
+// setState transitions the breaker to s, reporting the change. b.mu must be held.
+func (b *agentBreaker) setState(s breakerState) {
+	if b.state == s {
+		return
+	}
+	log.Debug("Agent circuit breaker transitioned from %s to %s", b.state, s)
+	b.state = s
+	if b.statsd != nil {
+		b.statsd.Incr("datadog.tracer.agent_breaker.transitions", []string{"state:" + s.String()}, 1)
+	}
+}
@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDelay(t *testing.T) {
+	assert := assert.New(t)
+	defer func(base, max time.Duration) {
+		retryBackoffBase, retryBackoffMax = base, max
+	}(retryBackoffBase, retryBackoffMax)
+	retryBackoffBase, retryBackoffMax = 100*time.Millisecond, time.Second
+
+	for attempt, want := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 10; i++ {
+			d := retryDelay(attempt, errors.New("oops"))
+			assert.GreaterOrEqual(d, want/2)
+			assert.LessOrEqual(d, want)
+		}
+	}
+
+	t.Run("retry-after", func(t *testing.T) {
+		err := &httpStatusError{code: http.StatusTooManyRequests, retryAfter: 500 * time.Millisecond}
+		assert.Equal(500*time.Millisecond, retryDelay(0, err))
+		err.retryAfter = time.Hour
+		assert.Equal(time.Second, retryDelay(0, err))
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+	assert.Zero(parseRetryAfter(""))
+	assert.Zero(parseRetryAfter("soon"))
+	assert.Zero(parseRetryAfter("-1"))
+	assert.Equal(3*time.Second, parseRetryAfter("3"))
+	d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.Greater(d, 58*time.Second)
+	assert.LessOrEqual(d, time.Minute)
+	assert.Zero(parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)))
+}
+
+func TestAgentBreaker(t *testing.T) {
+	defer func(base, max time.Duration) {
+		breakerCooldownBase, breakerCooldownMax = base, max
+	}(breakerCooldownBase, breakerCooldownMax)
+	breakerCooldownBase, breakerCooldownMax = 10*time.Millisecond, time.Second
+
+	t.Run("nil", func(t *testing.T) {
+		var b *agentBreaker
+		b.failure(&httpStatusError{code: http.StatusServiceUnavailable})
+		b.success()
+		assert.True(t, b.allow())
+		assert.False(t, b.shedding())
+	})
+
+	t.Run("transitions", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		b := newAgentBreaker(&tg)
+
+		// errors which are not about the agent being overloaded do not open the breaker
+		b.failure(errors.New("connection refused"))
+		b.failure(&httpStatusError{code: http.StatusBadRequest})
+		assert.True(b.allow())
+		assert.False(b.shedding())
+
+		b.failure(&httpStatusError{code: http.StatusTooManyRequests})
+		assert.False(b.allow())
+		assert.True(b.shedding())
+
+		time.Sleep(20 * time.Millisecond)
+		assert.True(b.allow())
+		assert.Equal(breakerHalfOpen, b.state)
+		assert.True(b.shedding())
+
+		// still overloaded: the cooldown doubles
+		b.failure(&httpStatusError{code: http.StatusServiceUnavailable})
+		assert.Equal(breakerOpen, b.state)
+		assert.Equal(40*time.Millisecond, b.cooldown)
+		assert.False(b.allow())
+
+		time.Sleep(30 * time.Millisecond)
+		assert.True(b.allow())
+		b.success()
+		assert.Equal(breakerClosed, b.state)
+		assert.Equal(breakerCooldownBase, b.cooldown)
+		assert.False(b.shedding())
+
+		var states []string
+		for _, c := range tg.IncrCalls() {
+			if c.name == "datadog.tracer.agent_breaker.transitions" {
+				states = append(states, c.tags...)
+			}
+		}
+		assert.Equal([]string{"state:open", "state:half_open", "state:open", "state:half_open", "state:closed"}, states)
+	})
+
+	t.Run("retry-after", func(t *testing.T) {
+		assert := assert.New(t)
+		b := newAgentBreaker(nil)
+		b.failure(&httpStatusError{code: http.StatusTooManyRequests, retryAfter: 100 * time.Millisecond})
+		time.Sleep(20 * time.Millisecond)
+		assert.False(b.allow())
+
+		b = newAgentBreaker(nil)
+		b.failure(&httpStatusError{code: http.StatusTooManyRequests, retryAfter: time.Hour})
+		assert.WithinDuration(time.Now().Add(breakerCooldownMax), b.openUntil, 100*time.Millisecond)
+	})
+}
+
+func TestTraceWriterAgentOverloaded(t *testing.T) {
+	assert := assert.New(t)
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := newConfig(withTransport(newHTTPTransport(srv.URL, defaultClient)), WithSendRetries(2))
+	var statsd testStatsdClient
+	c.agentBreaker = newAgentBreaker(&statsd)
+	h := newAgentTraceWriter(c, nil, &statsd)
+
+	h.add([]*span{makeSpan(0)})
+	start := time.Now()
+	h.flush()
+	h.wg.Wait()
+	// the agent asked to back off, so no retry is attempted while the breaker is open
+	assert.Equal(1, hits)
+	assert.Less(time.Since(start), time.Second)
+	assert.True(c.agentBreaker.shedding())
+
+	// P0 traces are shed while the agent is overloaded, kept traces are not
+	p0, p1 := makeSpan(0), makeSpan(0)
+	p0.SetTag(ext.ManualDrop, true)
+	p1.SetTag(ext.ManualKeep, true)
+	h.add([]*span{p0})
+	assert.Equal(0, h.payload.itemCount())
+	h.add([]*span{p1})
+	assert.Equal(1, h.payload.itemCount())
+
+	counts := statsd.Counts()
+	assert.Equal(int64(1), counts["datadog.tracer.agent_breaker.transitions"])
+	assert.Equal(int64(2), counts["datadog.tracer.traces_dropped"])
+}
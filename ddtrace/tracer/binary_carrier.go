@@ -0,0 +1,276 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"encoding/binary"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+// BinaryCarrier holds a span context using a compact binary encoding. Inject a span
+// context into a *BinaryCarrier to encode it, and Extract from a BinaryCarrier to decode
+// it. It is meant for protocols carrying opaque binary values, where the size of the
+// propagated context matters.
+//
+// The encoding carries the 128-bit trace ID, the span ID, the sampling priority, the
+// origin, the propagating trace tags and the baggage.
+type BinaryCarrier []byte
+
+// binaryContextHeader is the header holding the binary encoded span context when binary
+// propagation is enabled for HeadersCarriers.
+const binaryContextHeader = "x-datadog-context-bin"
+
+// binaryPropagationStyle names the binary encoding in the span links of restarted traces,
+// like the names of the propagation styles.
+const binaryPropagationStyle = "binary"
+
+// binaryContextVersion is the version of the binary span context encoding, which is its first byte.
+const binaryContextVersion = 1
+
+// Flags of the binary span context encoding, telling which optional fields are present.
+const (
+	binaryFlagPriority = 1 << iota
+	binaryFlagOrigin
+	binaryFlagTags
+	binaryFlagBaggage
+)
+
+// encodeBinaryContext encodes spanCtx into the compact binary format:
+//
+//	version(1) flags(1) trace-id(16) span-id(8) [priority(1)] [origin] [tags] [baggage]
+//
+// where strings are prefixed by their uvarint length, and tags and baggage are a uvarint
+// count followed by as many key and value strings.
+func encodeBinaryContext(spanCtx ddtrace.SpanContext) ([]byte, error) {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok || ctx.traceID.Empty() || ctx.spanID == 0 {
+		return nil, ErrInvalidSpanContext
+	}
+	var flags byte
+	priority, hasPriority := ctx.SamplingPriority()
+	if hasPriority {
+		flags |= binaryFlagPriority
+	}
+	if ctx.origin != "" {
+		flags |= binaryFlagOrigin
+	}
+	var tags [][2]string
+	if ctx.trace != nil {
+		ctx.trace.iteratePropagatingTags(func(k, v string) bool {
+			tags = append(tags, [2]string{k, v})
+			return true
+		})
+	}
+	if len(tags) > 0 {
+		flags |= binaryFlagTags
+	}
+	var baggage [][2]string
+	ctx.ForeachBaggageItem(func(k, v string) bool {
+		baggage = append(baggage, [2]string{k, v})
+		return true
+	})
+	if len(baggage) > 0 {
+		flags |= binaryFlagBaggage
+	}
+	b := make([]byte, 0, 32)
+	b = append(b, binaryContextVersion, flags)
+	b = append(b, ctx.traceID[:]...)
+	b = binary.BigEndian.AppendUint64(b, ctx.spanID)
+	if hasPriority {
+		b = append(b, byte(int8(priority)))
+	}
+	if ctx.origin != "" {
+		b = appendBinaryString(b, ctx.origin)
+	}
+	for _, kvs := range [][][2]string{tags, baggage} {
+		if len(kvs) == 0 {
+			continue
+		}
+		b = binary.AppendUvarint(b, uint64(len(kvs)))
+		for _, kv := range kvs {
+			b = appendBinaryString(b, kv[0])
+			b = appendBinaryString(b, kv[1])
+		}
+	}
+	return b, nil
+}
+
+// appendBinaryString appends s to b, prefixed by its uvarint length.
+func appendBinaryString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// decodeBinaryContext decodes a span context encoded by encodeBinaryContext.
+func decodeBinaryContext(b []byte) (*spanContext, error) {
+	if len(b) == 0 {
+		return nil, ErrSpanContextNotFound
+	}
+	if b[0] != binaryContextVersion || len(b) < 26 {
+		return nil, ErrSpanContextCorrupted
+	}
+	flags := b[1]
+	var ctx spanContext
+	copy(ctx.traceID[:], b[2:18])
+	ctx.spanID = binary.BigEndian.Uint64(b[18:26])
+	if ctx.traceID.Empty() || ctx.spanID == 0 {
+		return nil, ErrSpanContextCorrupted
+	}
+	d := binaryDecoder(b[26:])
+	if flags&binaryFlagPriority != 0 {
+		p, ok := d.byte()
+		if !ok {
+			return nil, ErrSpanContextCorrupted
+		}
+		ctx.setSamplingPriority(int(int8(p)), samplernames.Unknown)
+	}
+	if flags&binaryFlagOrigin != 0 {
+		origin, ok := d.string()
+		if !ok {
+			return nil, ErrSpanContextCorrupted
+		}
+		ctx.origin = origin
+	}
+	for _, f := range []struct {
+		flag byte
+		set  func(k, v string)
+	}{
+		{binaryFlagTags, func(k, v string) { setPropagatingTag(&ctx, k, v) }},
+		{binaryFlagBaggage, ctx.setBaggageItem},
+	} {
+		if flags&f.flag == 0 {
+			continue
+		}
+		n, ok := d.uvarint()
+		if !ok {
+			return nil, ErrSpanContextCorrupted
+		}
+		for i := uint64(0); i < n; i++ {
+			k, ok := d.string()
+			if !ok {
+				return nil, ErrSpanContextCorrupted
+			}
+			v, ok := d.string()
+			if !ok {
+				return nil, ErrSpanContextCorrupted
+			}
+			f.set(k, v)
+		}
+	}
+	return &ctx, nil
+}
+
+// binaryDecoder reads the fields of a binary encoded span context.
+type binaryDecoder []byte
+
+func (d *binaryDecoder) byte() (byte, bool) {
+	if len(*d) == 0 {
+		return 0, false
+	}
+	c := (*d)[0]
+	*d = (*d)[1:]
+	return c, true
+}
+
+func (d *binaryDecoder) uvarint() (uint64, bool) {
+	v, n := binary.Uvarint(*d)
+	if n <= 0 {
+		return 0, false
+	}
+	*d = (*d)[n:]
+	return v, true
+}
+
+func (d *binaryDecoder) string() (string, bool) {
+	n, ok := d.uvarint()
+	if !ok || n > uint64(len(*d)) {
+		return "", false
+	}
+	s := string((*d)[:n])
+	*d = (*d)[n:]
+	return s, true
+}
+
+// headersTextMap adapts a HeadersCarrier into a TextMapWriter and TextMapReader.
+type headersTextMap struct {
+	HeadersCarrier
+}
+
+// Set implements TextMapWriter.
+func (c headersTextMap) Set(key, val string) {
+	c.SetHeader(key, []byte(val))
+}
+
+// ForeachKey implements TextMapReader.
+func (c headersTextMap) ForeachKey(handler func(key, val string) error) error {
+	return c.ForeachHeader(func(key string, val []byte) error {
+		return handler(key, string(val))
+	})
+}
+
+// injectBinary injects spanCtx into carrier if it is a *BinaryCarrier, or a HeadersCarrier
+// and binary propagation is enabled. It reports whether the carrier was handled.
+func (t *tracer) injectBinary(spanCtx ddtrace.SpanContext, carrier interface{}) (bool, error) {
+	switch c := carrier.(type) {
+	case *BinaryCarrier:
+		b, err := encodeBinaryContext(spanCtx)
+		if err != nil {
+			return true, err
+		}
+		*c = b
+		return true, nil
+	case HeadersCarrier:
+		if !t.config.binaryPropagation {
+			return false, nil
+		}
+		b, err := encodeBinaryContext(spanCtx)
+		if err != nil {
+			return true, err
+		}
+		c.SetHeader(binaryContextHeader, b)
+		return true, nil
+	}
+	return false, nil
+}
+
+// extractBinary extracts a span context from carrier if it is a BinaryCarrier, or a
+// HeadersCarrier holding a binary encoded span context. It reports whether the carrier
+// was handled.
+func (t *tracer) extractBinary(carrier interface{}) (ddtrace.SpanContext, bool, error) {
+	switch c := carrier.(type) {
+	case BinaryCarrier:
+		ctx, err := decodeBinaryContext(c)
+		return wrapBinaryContext(ctx, err)
+	case *BinaryCarrier:
+		ctx, err := decodeBinaryContext(*c)
+		return wrapBinaryContext(ctx, err)
+	case HeadersCarrier:
+		var b []byte
+		c.ForeachHeader(func(key string, val []byte) error {
+			if key == binaryContextHeader {
+				b = val
+			}
+			return nil
+		})
+		if b == nil {
+			return nil, false, nil
+		}
+		ctx, err := decodeBinaryContext(b)
+		return wrapBinaryContext(ctx, err)
+	}
+	return nil, false, nil
+}
+
+// wrapBinaryContext returns the results of extractBinary for a handled carrier, avoiding
+// a non-nil ddtrace.SpanContext holding a nil *spanContext.
+func wrapBinaryContext(ctx *spanContext, err error) (ddtrace.SpanContext, bool, error) {
+	if err != nil {
+		return nil, true, err
+	}
+	return ctx, true, nil
+}
@@ -0,0 +1,197 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHeadersCarrier is a HeadersCarrier which is not a TextMapReader or TextMapWriter.
+type testHeadersCarrier map[string][]byte
+
+func (c testHeadersCarrier) SetHeader(key string, val []byte) {
+	c[key] = val
+}
+
+func (c testHeadersCarrier) ForeachHeader(handler func(key string, val []byte) error) error {
+	for k, v := range c {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestBinaryContextEncoding(t *testing.T) {
+	t.Run("round-trip", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := newSpanContext(&span{TraceID: 1, SpanID: 2}, nil)
+		ctx.traceID.SetUpper(0x640cfd8d00000000)
+		ctx.setSamplingPriority(ext.PriorityUserKeep, 0)
+		ctx.origin = "synthetics"
+		ctx.trace.setPropagatingTag("_dd.p.dm", "-4")
+		ctx.setBaggageItem("user", "alice")
+
+		b, err := encodeBinaryContext(ctx)
+		require.NoError(t, err)
+		got, err := decodeBinaryContext(b)
+		require.NoError(t, err)
+		assert.Equal(ctx.traceID, got.traceID)
+		assert.Equal(uint64(2), got.spanID)
+		p, ok := got.SamplingPriority()
+		assert.True(ok)
+		assert.Equal(ext.PriorityUserKeep, p)
+		assert.Equal("synthetics", got.origin)
+		assert.Equal("-4", got.trace.propagatingTag("_dd.p.dm"))
+		assert.Equal("alice", got.baggageItem("user"))
+	})
+
+	t.Run("minimal", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := newSpanContext(&span{TraceID: 1, SpanID: 2}, nil)
+		b, err := encodeBinaryContext(ctx)
+		require.NoError(t, err)
+		assert.Len(b, 26)
+		got, err := decodeBinaryContext(b)
+		require.NoError(t, err)
+		_, ok := got.SamplingPriority()
+		assert.False(ok)
+		assert.Equal("", got.origin)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := encodeBinaryContext(&spanContext{})
+		assert.Equal(t, ErrInvalidSpanContext, err)
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		ctx := newSpanContext(&span{TraceID: 1, SpanID: 2}, nil)
+		ctx.origin = "synthetics"
+		b, err := encodeBinaryContext(ctx)
+		require.NoError(t, err)
+
+		_, err = decodeBinaryContext(nil)
+		assert.Equal(t, ErrSpanContextNotFound, err)
+		for _, in := range [][]byte{
+			b[:20],
+			b[:len(b)-1],
+			append([]byte{2}, b[1:]...),
+			append([]byte{binaryContextVersion, binaryFlagTags}, make([]byte, 24)...),
+		} {
+			_, err = decodeBinaryContext(in)
+			assert.Equal(t, ErrSpanContextCorrupted, err)
+		}
+	})
+}
+
+func TestBinaryCarrier(t *testing.T) {
+	t.Run("binary", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		root.SetBaggageItem("item", "x")
+		ctx := root.Context().(*spanContext)
+
+		var carrier BinaryCarrier
+		require.NoError(t, tracer.Inject(ctx, &carrier))
+		assert.NotEmpty(carrier)
+
+		sctx, err := tracer.Extract(carrier)
+		require.NoError(t, err)
+		got := sctx.(*spanContext)
+		assert.Equal(ctx.traceID, got.traceID)
+		assert.Equal(ctx.spanID, got.spanID)
+		assert.Equal("x", got.baggageItem("item"))
+
+		_, err = tracer.Extract(BinaryCarrier{})
+		assert.Equal(ErrSpanContextNotFound, err)
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer := newTracer(WithBinaryPropagation(true))
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		ctx := root.Context().(*spanContext)
+
+		carrier := testHeadersCarrier{}
+		require.NoError(t, tracer.Inject(ctx, carrier))
+		assert.Len(carrier, 1)
+		assert.Contains(carrier, binaryContextHeader)
+
+		sctx, err := tracer.Extract(carrier)
+		require.NoError(t, err)
+		assert.Equal(ctx.spanID, sctx.(*spanContext).spanID)
+	})
+
+	t.Run("headers-baggage", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "datadog,baggage")
+		tracer := newTracer(WithBinaryPropagation(true))
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		ctx := root.Context().(*spanContext)
+
+		carrier := testHeadersCarrier{baggageHeader: []byte("user.id=42")}
+		require.NoError(t, tracer.Inject(ctx, carrier))
+		sctx, err := tracer.Extract(carrier)
+		require.NoError(t, err)
+		assert.Equal(ctx.spanID, sctx.(*spanContext).spanID)
+		assert.Equal("42", sctx.(*spanContext).baggageItem("user.id"))
+	})
+
+	t.Run("behavior", func(t *testing.T) {
+		for _, behavior := range []string{PropagationBehaviorRestart, PropagationBehaviorIgnore} {
+			t.Run(behavior, func(t *testing.T) {
+				assert := assert.New(t)
+				t.Setenv(headerPropagationBehaviorExtract, behavior)
+				tracer := newTracer()
+				defer tracer.Stop()
+				root := tracer.StartSpan("web.request").(*span)
+				root.SetBaggageItem("item", "x")
+				ctx := root.Context().(*spanContext)
+
+				var carrier BinaryCarrier
+				require.NoError(t, tracer.Inject(ctx, &carrier))
+				sctx, err := tracer.Extract(carrier)
+				if behavior == PropagationBehaviorIgnore {
+					assert.Equal(ErrSpanContextNotFound, err)
+					return
+				}
+				require.NoError(t, err)
+				got := sctx.(*spanContext)
+				assert.True(got.baggageOnly)
+				assert.Equal("x", got.baggageItem("item"))
+				require.Len(t, got.links, 1)
+				assert.Equal(ctx.spanID, got.links[0].SpanID)
+				assert.Equal(binaryPropagationStyle, got.links[0].Attributes["context_headers"])
+			})
+		}
+	})
+
+	t.Run("headers-text", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		ctx := root.Context().(*spanContext)
+
+		carrier := testHeadersCarrier{}
+		require.NoError(t, tracer.Inject(ctx, carrier))
+		assert.NotContains(carrier, binaryContextHeader)
+		assert.Contains(carrier, DefaultTraceIDHeader)
+
+		sctx, err := tracer.Extract(carrier)
+		require.NoError(t, err)
+		assert.Equal(ctx.spanID, sctx.(*spanContext).spanID)
+	})
+}
@@ -13,15 +13,16 @@ import (
 
 // dynamicConfig is a thread-safe generic data structure to represent configuration fields.
 // It's designed to satisfy the dynamic configuration semantics (i.e reset, update, apply configuration changes).
-// This structure will be extended to track the origin of configuration values as well (e.g remote_config, env_var).
+// It also tracks the origin of configuration values (e.g remote_config, env_var, code).
 type dynamicConfig[T any] struct {
 	sync.RWMutex
-	current   T                 // holds the current configuration value
-	startup   T                 // holds the startup configuration value
-	cfgName   string            // holds the name of the configuration, has to be compatible with telemetry.Configuration.Name
-	cfgOrigin string            // holds the origin of the current configuration value (currently only supports remote_config, empty otherwise)
-	apply     func(T) bool      // executes any config-specific operations to propagate the update properly, returns whether the update was applied
-	equal     func(x, y T) bool // compares two configuration values, this is used to avoid unnecessary config and telemetry updates
+	current       T                 // holds the current configuration value
+	startup       T                 // holds the startup configuration value
+	cfgName       string            // holds the name of the configuration, has to be compatible with telemetry.Configuration.Name
+	cfgOrigin     string            // holds the origin of the current configuration value
+	startupOrigin string            // holds the origin of the startup configuration value, empty if unknown
+	apply         func(T) bool      // executes any config-specific operations to propagate the update properly, returns whether the update was applied
+	equal         func(x, y T) bool // compares two configuration values, this is used to avoid unnecessary config and telemetry updates
 }
 
 func newDynamicConfig[T any](name string, val T, apply func(T) bool, equal func(x, y T) bool) dynamicConfig[T] {
@@ -34,6 +35,15 @@ func newDynamicConfig[T any](name string, val T, apply func(T) bool, equal func(
 	}
 }
 
+// setStartupOrigin records where the startup configuration value comes from (e.g env_var, code).
+// It must be called before the configuration is updated.
+func (dc *dynamicConfig[T]) setStartupOrigin(origin string) {
+	dc.Lock()
+	defer dc.Unlock()
+	dc.startupOrigin = origin
+	dc.cfgOrigin = origin
+}
+
 // get returns the current configuration value
 func (dc *dynamicConfig[T]) get() T {
 	dc.RLock()
@@ -61,7 +71,7 @@ func (dc *dynamicConfig[T]) reset() bool {
 		return false
 	}
 	dc.current = dc.startup
-	dc.cfgOrigin = ""
+	dc.cfgOrigin = dc.startupOrigin
 	return dc.apply(dc.startup)
 }
 
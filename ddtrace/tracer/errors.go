@@ -0,0 +1,184 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"hash/fnv"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// maxErrorChainLength is the maximum number of errors visited when walking an error chain,
+// protecting against very long or cyclic chains.
+const maxErrorChainLength = 32
+
+// fingerprintFrames is the number of top stack frames taken into account when computing
+// an error fingerprint.
+const fingerprintFrames = 5
+
+// errorInfo holds what was learned by walking an error chain.
+type errorInfo struct {
+	// types holds the type of every error in the chain, starting with the path to cause.
+	types []string
+	// cause is the innermost error, found by following the first wrapped error at each level.
+	cause error
+	// stack holds the program counters of the deepest stack trace carried by an error
+	// on the path to cause, if any.
+	stack []uintptr
+}
+
+// inspectError walks the chain of errors wrapped by err, following both
+// Unwrap() error and Unwrap() []error, as created by fmt.Errorf and errors.Join.
+func inspectError(err error) errorInfo {
+	var info errorInfo
+	var rest []error
+	// walk the main path from err down to its innermost cause
+	for e := err; e != nil && len(info.types) < maxErrorChainLength; {
+		if pcs := errorStack(e); len(pcs) > 0 {
+			info.stack = pcs
+		}
+		info.cause = e
+		info.types = append(info.types, reflect.TypeOf(e).String())
+		switch u := e.(type) {
+		case interface{ Unwrap() error }:
+			e = u.Unwrap()
+		case interface{ Unwrap() []error }:
+			e = nil
+			errs := u.Unwrap()
+			for i, w := range errs {
+				if w != nil {
+					e = w
+					// the other branches of a joined error only contribute their types
+					rest = append(rest, errs[i+1:]...)
+					break
+				}
+			}
+		default:
+			e = nil
+		}
+	}
+	info.types = append(info.types, errorTypes(rest, maxErrorChainLength-len(info.types))...)
+	return info
+}
+
+// errorTypes returns the types of errs and of the errors they wrap, in depth-first order,
+// up to max entries.
+func errorTypes(errs []error, max int) []string {
+	var types []string
+	stack := make([]error, 0, len(errs))
+	for i := len(errs) - 1; i >= 0; i-- {
+		stack = append(stack, errs[i])
+	}
+	for len(stack) > 0 && len(types) < max {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e == nil {
+			continue
+		}
+		types = append(types, reflect.TypeOf(e).String())
+		switch u := e.(type) {
+		case interface{ Unwrap() error }:
+			stack = append(stack, u.Unwrap())
+		case interface{ Unwrap() []error }:
+			errs := u.Unwrap()
+			for i := len(errs) - 1; i >= 0; i-- {
+				stack = append(stack, errs[i])
+			}
+		}
+	}
+	return types
+}
+
+// errorStack returns the program counters of the stack trace stored inside err, if any.
+// It supports errors exposing a StackTrace method returning a slice of program counters,
+// such as the ones created by github.com/pkg/errors, and errors exposing a
+// Callers() []uintptr method, such as the ones created by github.com/go-errors/errors.
+func errorStack(err error) []uintptr {
+	if c, ok := err.(interface{ Callers() []uintptr }); ok {
+		return c.Callers()
+	}
+	v := reflect.ValueOf(err)
+	m := v.MethodByName("StackTrace")
+	if !m.IsValid() {
+		return nil
+	}
+	mt := m.Type()
+	if mt.NumIn() != 0 || mt.NumOut() != 1 {
+		return nil
+	}
+	if out := mt.Out(0); out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	st := m.Call(nil)[0]
+	pcs := make([]uintptr, st.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(st.Index(i).Uint())
+	}
+	return pcs
+}
+
+// callerPCs returns the program counters of up to n callers, skipping the first skip
+// entries. If n is 0, up to defaultStackLength entries are retrieved.
+func callerPCs(n, skip uint) []uintptr {
+	if n == 0 {
+		n = defaultStackLength
+	}
+	pcs := make([]uintptr, n)
+	// +2 to exclude runtime.Callers and callerPCs
+	return pcs[:runtime.Callers(2+int(skip), pcs)]
+}
+
+// formatStack formats the frames of pcs, returning the resulting stack trace along with
+// the names of the functions of the first fingerprintFrames frames.
+func formatStack(pcs []uintptr) (stack string, funcs []string) {
+	if len(pcs) == 0 {
+		return "", nil
+	}
+	var builder strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		if i != 0 {
+			builder.WriteByte('\n')
+		}
+		if i < fingerprintFrames {
+			funcs = append(funcs, frame.Function)
+		}
+		builder.WriteString(frame.Function)
+		builder.WriteByte('\n')
+		builder.WriteByte('\t')
+		builder.WriteString(frame.File)
+		builder.WriteByte(':')
+		builder.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return builder.String(), funcs
+}
+
+// errorFingerprint computes a stable fingerprint for grouping errors, based on the types
+// of the errors in the chain and on the functions at the top of its stack trace. Error
+// messages and line numbers are left out, as they commonly vary between occurrences
+// and releases.
+func errorFingerprint(types, funcs []string) string {
+	h := fnv.New64a()
+	for _, t := range types {
+		h.Write([]byte(t))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+	for _, f := range funcs {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
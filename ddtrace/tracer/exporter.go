@@ -0,0 +1,221 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// SpanExporter exports finished traces to a destination other than the Datadog
+// Agent, such as a file, a message queue or an in-house pipeline. Exporters are
+// registered using WithExporter.
+//
+// The methods of a SpanExporter are never called concurrently by the tracer.
+type SpanExporter interface {
+	// ExportSpans exports a chunk of finished spans belonging to the same trace.
+	// The chunk may be a complete local trace, or only a portion of it when
+	// partial flushing is enabled. The spans must not be retained after the
+	// tracer has been stopped.
+	ExportSpans(spans []ReadOnlySpan) error
+
+	// Flush exports any traces buffered by the exporter. It is called
+	// periodically and whenever the tracer is flushed.
+	Flush() error
+
+	// Shutdown flushes and releases any resource held by the exporter. It is
+	// called once, when the tracer is stopped.
+	Shutdown() error
+}
+
+// ReadOnlySpan is a read-only view of a finished span, as passed to a SpanExporter.
+type ReadOnlySpan interface {
+	// OperationName returns the operation name of the span.
+	OperationName() string
+
+	// ServiceName returns the service name of the span.
+	ServiceName() string
+
+	// ResourceName returns the resource name of the span.
+	ResourceName() string
+
+	// SpanType returns the type of the span, e.g. "web" or "db".
+	SpanType() string
+
+	// TraceID returns the lower 64 bits of the span's trace ID.
+	TraceID() uint64
+
+	// TraceID128 returns the hex-encoded 128-bit trace ID of the span.
+	TraceID128() string
+
+	// SpanID returns the span's ID.
+	SpanID() uint64
+
+	// ParentID returns the span's parent ID, or 0 for root spans.
+	ParentID() uint64
+
+	// StartTime returns the time when the span has started.
+	StartTime() time.Time
+
+	// Duration returns the duration of the span.
+	Duration() time.Duration
+
+	// IsError reports whether the span was marked as erroneous.
+	IsError() bool
+
+	// Tag returns the value of the string or numeric tag at key k, or nil
+	// if it is not set.
+	Tag(k string) interface{}
+
+	// Tags returns a copy of all the string and numeric tags of the span.
+	Tags() map[string]interface{}
+
+	// Links returns a copy of the links attached to the span.
+	Links() []ddtrace.SpanLink
+
+	// Events returns a copy of the events recorded on the span.
+	Events() []ddtrace.SpanEvent
+
+	// SamplingPriority returns the sampling priority of the span's trace, if set.
+	SamplingPriority() (p int, ok bool)
+}
+
+// readOnlySpan implements ReadOnlySpan on top of a finished span.
+type readOnlySpan struct {
+	s *span
+}
+
+var _ ReadOnlySpan = (*readOnlySpan)(nil)
+
+func (r readOnlySpan) OperationName() string {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return r.s.Name
+}
+
+func (r readOnlySpan) ServiceName() string {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return r.s.Service
+}
+
+func (r readOnlySpan) ResourceName() string {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return r.s.Resource
+}
+
+func (r readOnlySpan) SpanType() string {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return r.s.Type
+}
+
+func (r readOnlySpan) TraceID() uint64 { return r.s.TraceID }
+
+func (r readOnlySpan) TraceID128() string { return r.s.context.TraceID128() }
+
+func (r readOnlySpan) SpanID() uint64 { return r.s.SpanID }
+
+func (r readOnlySpan) ParentID() uint64 { return r.s.ParentID }
+
+func (r readOnlySpan) StartTime() time.Time { return time.Unix(0, r.s.Start) }
+
+func (r readOnlySpan) Duration() time.Duration {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return time.Duration(r.s.Duration)
+}
+
+func (r readOnlySpan) IsError() bool {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	return r.s.Error != 0
+}
+
+func (r readOnlySpan) Tag(k string) interface{} {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	if v, ok := r.s.Meta[k]; ok {
+		return v
+	}
+	if v, ok := r.s.Metrics[k]; ok {
+		return v
+	}
+	return nil
+}
+
+func (r readOnlySpan) Tags() map[string]interface{} {
+	r.s.RLock()
+	defer r.s.RUnlock()
+	tags := make(map[string]interface{}, len(r.s.Meta)+len(r.s.Metrics))
+	for k, v := range r.s.Meta {
+		tags[k] = v
+	}
+	for k, v := range r.s.Metrics {
+		tags[k] = v
+	}
+	return tags
+}
+
+func (r readOnlySpan) Links() []ddtrace.SpanLink { return r.s.Links() }
+
+func (r readOnlySpan) Events() []ddtrace.SpanEvent { return r.s.Events() }
+
+func (r readOnlySpan) SamplingPriority() (p int, ok bool) {
+	return r.s.context.SamplingPriority()
+}
+
+// exporterTraceWriter is a traceWriter which hands finished traces to the
+// user-provided exporters instead of sending them to the agent.
+type exporterTraceWriter struct {
+	exporters []SpanExporter
+	statsd    globalinternal.StatsdClient
+}
+
+var _ traceWriter = (*exporterTraceWriter)(nil)
+
+func newExporterTraceWriter(exporters []SpanExporter, statsdClient globalinternal.StatsdClient) *exporterTraceWriter {
+	return &exporterTraceWriter{
+		exporters: exporters,
+		statsd:    statsdClient,
+	}
+}
+
+func (h *exporterTraceWriter) add(trace []*span) {
+	spans := make([]ReadOnlySpan, len(trace))
+	for i, s := range trace {
+		spans[i] = readOnlySpan{s}
+	}
+	for _, e := range h.exporters {
+		if err := e.ExportSpans(spans); err != nil {
+			h.statsd.Incr("datadog.tracer.traces_dropped", []string{"reason:export_failed"}, 1)
+			log.Error("Error exporting trace: %v", err)
+		}
+	}
+}
+
+// flush flushes all the exporters.
+func (h *exporterTraceWriter) flush() {
+	for _, e := range h.exporters {
+		if err := e.Flush(); err != nil {
+			log.Error("Error flushing exporter: %v", err)
+		}
+	}
+}
+
+// stop shuts down all the exporters.
+func (h *exporterTraceWriter) stop() {
+	h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:shutdown"}, 1)
+	for _, e := range h.exporters {
+		if err := e.Shutdown(); err != nil {
+			log.Error("Error shutting down exporter: %v", err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExporter is a SpanExporter which records all the traces it receives.
+type recordingExporter struct {
+	mu       sync.Mutex
+	traces   [][]ReadOnlySpan
+	flushes  int
+	shutdown int
+	err      error // returned by ExportSpans
+}
+
+func (e *recordingExporter) ExportSpans(spans []ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.traces = append(e.traces, spans)
+	return e.err
+}
+
+func (e *recordingExporter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flushes++
+	return nil
+}
+
+func (e *recordingExporter) Shutdown() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown++
+	return nil
+}
+
+func (e *recordingExporter) Traces() [][]ReadOnlySpan {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.traces
+}
+
+// waitTraces waits until exp has received n traces and returns them.
+func (e *recordingExporter) waitTraces(t *testing.T, n int) [][]ReadOnlySpan {
+	require.Eventually(t, func() bool { return len(e.Traces()) >= n }, 5*time.Second, time.Millisecond)
+	return e.Traces()
+}
+
+func TestExporter(t *testing.T) {
+	t.Run("views", func(t *testing.T) {
+		assert := assert.New(t)
+		exp := new(recordingExporter)
+		tracer := newTracer(WithExporter(exp), WithServiceVersion("1.0"), withNoopStats())
+		internal.SetGlobalTracer(tracer)
+		defer tracer.Stop()
+		assert.IsType(&exporterTraceWriter{}, tracer.traceWriter)
+		assert.False(tracer.config.canComputeStats())
+
+		start := time.Now().Add(-time.Second)
+		link := ddtrace.SpanLink{TraceID: 1, SpanID: 2}
+		root := tracer.StartSpan("http.request", ResourceName("GET /"), SpanType(ext.SpanTypeWeb), StartTime(start))
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()), Tag("db.rows", 3), WithSpanLinks([]ddtrace.SpanLink{link}))
+		child.SetTag("db.system", "postgres")
+		child.Finish(WithError(errors.New("boom")))
+		root.Finish()
+
+		traces := exp.waitTraces(t, 1)
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 2)
+		byName := map[string]ReadOnlySpan{}
+		for _, s := range traces[0] {
+			byName[s.OperationName()] = s
+		}
+		r, c := byName["http.request"], byName["db.query"]
+		require.NotNil(t, r)
+		require.NotNil(t, c)
+
+		assert.Equal(tracer.config.serviceName, r.ServiceName())
+		assert.Equal("1.0", r.Tag(ext.Version))
+		assert.Equal("GET /", r.ResourceName())
+		assert.Equal(ext.SpanTypeWeb, r.SpanType())
+		assert.Equal(root.Context().TraceID(), r.TraceID())
+		assert.Equal(root.Context().(*spanContext).TraceID128(), r.TraceID128())
+		assert.Equal(root.Context().SpanID(), r.SpanID())
+		assert.Zero(r.ParentID())
+		assert.Equal(start.UnixNano(), r.StartTime().UnixNano())
+		assert.GreaterOrEqual(r.Duration(), time.Second)
+		assert.False(r.IsError())
+		p, ok := r.SamplingPriority()
+		assert.True(ok)
+		assert.Equal(ext.PriorityAutoKeep, p)
+
+		assert.Equal(r.SpanID(), c.ParentID())
+		assert.Equal(r.TraceID(), c.TraceID())
+		assert.True(c.IsError())
+		assert.Equal("boom", c.Tag(ext.ErrorMsg))
+		assert.Equal("postgres", c.Tag("db.system"))
+		assert.Equal(float64(3), c.Tag("db.rows"))
+		assert.Nil(c.Tag("missing"))
+		assert.Equal([]ddtrace.SpanLink{link}, c.Links())
+		assert.Nil(c.Events())
+
+		tags := c.Tags()
+		assert.Equal("postgres", tags["db.system"])
+		tags["db.system"] = "mysql"
+		assert.Equal("postgres", c.Tag("db.system"), "Tags must return a copy")
+	})
+
+	t.Run("multiple", func(t *testing.T) {
+		exp1, exp2 := new(recordingExporter), new(recordingExporter)
+		// the periodic flush is disabled so that only the explicit one is counted
+		tracer := newTracer(WithExporter(exp1), WithExporter(exp2), WithExporter(nil), withNoopStats(), withTickChan(make(chan time.Time)))
+		internal.SetGlobalTracer(tracer)
+		tracer.StartSpan("a").Finish()
+		tracer.StartSpan("b").Finish()
+
+		exp1.waitTraces(t, 2)
+		exp2.waitTraces(t, 2)
+		tracer.flushSync()
+		exp1.mu.Lock()
+		assert.Equal(t, 1, exp1.flushes)
+		exp1.mu.Unlock()
+
+		tracer.Stop()
+		assert.Equal(t, 1, exp1.shutdown)
+		assert.Equal(t, 1, exp2.shutdown)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		exp := &recordingExporter{err: errors.New("unavailable")}
+		var tg testStatsdClient
+		tracer := newTracer(WithExporter(exp), withStatsdClient(&tg))
+		internal.SetGlobalTracer(tracer)
+		tracer.StartSpan("a").Finish()
+
+		exp.waitTraces(t, 1)
+		tracer.Stop()
+		assert.Contains(t, tg.IncrCalls(), testStatsdCall{
+			name: "datadog.tracer.traces_dropped",
+			tags: []string{"reason:export_failed"},
+			rate: 1,
+		})
+	})
+}
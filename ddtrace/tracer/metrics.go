@@ -94,6 +94,11 @@ func (t *tracer) reportHealthMetrics(interval time.Duration) {
 			t.statsd.Count("datadog.tracer.spans_started", int64(atomic.SwapUint32(&t.spansStarted, 0)), nil, 1)
 			t.statsd.Count("datadog.tracer.spans_finished", int64(atomic.SwapUint32(&t.spansFinished, 0)), nil, 1)
 			t.statsd.Count("datadog.tracer.traces_dropped", int64(atomic.SwapUint32(&t.tracesDropped, 0)), []string{"reason:trace_too_large"}, 1)
+			if w, ok := t.traceWriter.(*agentTraceWriter); ok && w.spool != nil {
+				payloads, bytes := w.spool.depth()
+				t.statsd.Gauge("datadog.tracer.spool.payloads", float64(payloads), nil, 1)
+				t.statsd.Gauge("datadog.tracer.spool.bytes", float64(bytes), nil, 1)
+			}
 		case <-t.stop:
 			return
 		}
@@ -133,6 +133,13 @@ type config struct {
 	// failure.
 	sendRetries int
 
+	// spoolDir, when set, specifies the directory in which payloads that could not
+	// be sent to the agent are stored until they can be replayed.
+	spoolDir string
+
+	// spoolMaxBytes is the maximum total size of the payloads stored in spoolDir.
+	spoolMaxBytes int64
+
 	// logStartup, when true, causes various startup info to be written
 	// when the tracer starts.
 	logStartup bool
@@ -166,6 +173,25 @@ type config struct {
 	// transport specifies the Transport interface which will be used to send data to the agent.
 	transport transport
 
+	// agentBreaker suspends uploads to the agent while it reports being overloaded.
+	// It is set up when the tracer starts.
+	agentBreaker *agentBreaker
+
+	// traceProtocol specifies the encoding used for trace payloads sent to the agent. It is
+	// traceProtocolV05 when the agent supports it, and traceProtocolV04 otherwise.
+	traceProtocol float64
+
+	// otlpEndpoint, when set, specifies the OTLP/HTTP endpoint to which traces are sent
+	// instead of the agent.
+	otlpEndpoint string
+
+	// exporters, when set, receive all finished traces instead of the agent.
+	exporters []SpanExporter
+
+	// spanProcessors holds the processors which inspect and modify spans before
+	// they are sampled and exported.
+	spanProcessors []SpanProcessor
+
 	// propagator propagates span context cross-process
 	propagator Propagator
 
@@ -183,6 +209,10 @@ type config struct {
 	// runtimeMetrics specifies whether collection of runtime metrics is enabled.
 	runtimeMetrics bool
 
+	// runtimeMetricsV2 specifies whether runtime metrics are read from the runtime/metrics
+	// package instead of runtime.ReadMemStats, when runtime metrics are enabled.
+	runtimeMetricsV2 bool
+
 	// dogstatsdAddr specifies the address to connect for sending metrics to the
 	// Datadog Agent. If not set, it defaults to "localhost:8125" or to the
 	// combination of the environment variables DD_AGENT_HOST and DD_DOGSTATSD_PORT.
@@ -229,6 +259,16 @@ type config struct {
 	// peerServiceMappings holds a set of service mappings to dynamically rename peer.service values.
 	peerServiceMappings map[string]string
 
+	// tailSamplingPredicates, when set, enables tail sampling: traces matching any of
+	// the predicates once they complete are kept.
+	tailSamplingPredicates []TailSamplingPredicate
+
+	// tailSamplingMaxTraces is the maximum number of traces with a deferred sampling decision.
+	tailSamplingMaxTraces int
+
+	// tailSamplingTimeout is the maximum amount of time a sampling decision can be deferred.
+	tailSamplingTimeout time.Duration
+
 	// debugAbandonedSpans controls if the tracer should log when old, open spans are found
 	debugAbandonedSpans bool
 
@@ -236,6 +276,15 @@ type config struct {
 	// misconfiguration
 	spanTimeout time.Duration
 
+	// abandonedSpansMetrics specifies whether the number of abandoned spans is reported
+	// by integration. Value from DD_TRACE_ABANDONED_SPANS_METRICS_ENABLED, default false.
+	abandonedSpansMetrics bool
+
+	// abandonedSpansStackRate is the rate at which the creation stack of spans is captured
+	// when debugging abandoned spans. Value from DD_TRACE_DEBUG_ABANDONED_SPANS_STACK_RATE,
+	// default 0.
+	abandonedSpansStackRate float64
+
 	// partialFlushMinSpans is the number of finished spans in a single trace to trigger a
 	// partial flush, or 0 if partial flushing is disabled.
 	// Value from DD_TRACE_PARTIAL_FLUSH_MIN_SPANS, default 1000.
@@ -248,6 +297,10 @@ type config struct {
 	// statsComputationEnabled enables client-side stats computation (aka trace metrics).
 	statsComputationEnabled bool
 
+	// redMetrics enables the export of the computed stats as local RED metrics, to
+	// DogStatsD and REDMetricsHandler. Value from DD_TRACE_RED_METRICS_ENABLED, default false.
+	redMetrics bool
+
 	// dataStreamsMonitoringEnabled specifies whether the tracer should enable monitoring of data streams
 	dataStreamsMonitoringEnabled bool
 
@@ -258,6 +311,18 @@ type config struct {
 	// traceSampleRate holds the trace sample rate.
 	traceSampleRate dynamicConfig[float64]
 
+	// binaryPropagation specifies whether span contexts are injected into HeadersCarriers
+	// using the compact binary encoding.
+	binaryPropagation bool
+
+	// traceSamplingRules holds the trace sampling rules in effect, which may be updated
+	// through remote configuration.
+	traceSamplingRules dynamicConfig[[]SamplingRule]
+
+	// spanSamplingRules holds the single span sampling rules in effect, which may be
+	// updated through remote configuration.
+	spanSamplingRules dynamicConfig[[]SamplingRule]
+
 	// headerAsTags holds the header as tags configuration.
 	headerAsTags dynamicConfig[[]string]
 }
@@ -340,7 +405,9 @@ func newConfig(opts ...StartOption) *config {
 		c.logToStdout = true
 	}
 	c.logStartup = internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true)
-	c.runtimeMetrics = internal.BoolEnv("DD_RUNTIME_METRICS_ENABLED", false)
+	c.runtimeMetricsV2 = internal.BoolEnv("DD_RUNTIME_METRICS_V2_ENABLED", false)
+	// enabling the V2 runtime metrics enables runtime metrics, unless they are disabled explicitly
+	c.runtimeMetrics = internal.BoolEnv("DD_RUNTIME_METRICS_ENABLED", c.runtimeMetricsV2)
 	c.debug = internal.BoolEnv("DD_TRACE_DEBUG", false)
 	c.enabled = internal.BoolEnv("DD_TRACE_ENABLED", true)
 	c.profilerEndpoints = internal.BoolEnv(traceprof.EndpointEnvVar, true)
@@ -350,7 +417,10 @@ func newConfig(opts ...StartOption) *config {
 	if c.debugAbandonedSpans {
 		c.spanTimeout = internal.DurationEnv("DD_TRACE_ABANDONED_SPAN_TIMEOUT", 10*time.Minute)
 	}
+	c.abandonedSpansMetrics = internal.BoolEnv("DD_TRACE_ABANDONED_SPANS_METRICS_ENABLED", false)
+	c.abandonedSpansStackRate = internal.FloatEnv("DD_TRACE_DEBUG_ABANDONED_SPANS_STACK_RATE", 0)
 	c.statsComputationEnabled = internal.BoolEnv("DD_TRACE_STATS_COMPUTATION_ENABLED", false)
+	c.redMetrics = internal.BoolEnv("DD_TRACE_RED_METRICS_ENABLED", false)
 	c.dataStreamsMonitoringEnabled = internal.BoolEnv("DD_DATA_STREAMS_ENABLED", false)
 	c.partialFlushEnabled = internal.BoolEnv("DD_TRACE_PARTIAL_FLUSH_ENABLED", false)
 	c.partialFlushMinSpans = internal.IntEnv("DD_TRACE_PARTIAL_FLUSH_MIN_SPANS", partialFlushMinSpansDefault)
@@ -438,7 +508,11 @@ func newConfig(opts ...StartOption) *config {
 		}
 	}
 	if c.transport == nil {
-		c.transport = newHTTPTransport(c.agentURL.String(), c.httpClient)
+		if c.otlpEndpoint != "" {
+			c.transport = newOTLPTransport(c.otlpEndpoint, c.httpClient)
+		} else {
+			c.transport = newHTTPTransport(c.agentURL.String(), c.httpClient)
+		}
 	}
 	if c.propagator == nil {
 		envKey := "DD_TRACE_X_DATADOG_TAGS_MAX_LENGTH"
@@ -461,7 +535,13 @@ func newConfig(opts ...StartOption) *config {
 	if c.debug {
 		log.SetLevel(log.LevelDebug)
 	}
-	c.agent = loadAgentFeatures(c.logToStdout, c.agentURL, c.httpClient)
+	// there is no agent to query for features when exporting traces via OTLP or custom exporters
+	c.agent = loadAgentFeatures(c.logToStdout || c.otlpEndpoint != "" || len(c.exporters) > 0, c.agentURL, c.httpClient)
+	c.traceProtocol = traceProtocolV04
+	if _, ok := c.transport.(*httpTransport); ok && c.agent.V05 {
+		// only the agent's HTTP transport knows where to deliver v0.5 payloads
+		c.traceProtocol = traceProtocolV05
+	}
 	info, ok := debug.ReadBuildInfo()
 	if !ok {
 		c.loadContribIntegrations([]*debug.Module{})
@@ -577,6 +657,10 @@ type agentFeatures struct {
 	// the /v0.1/pipeline_stats endpoint.
 	DataStreams bool
 
+	// V05 reports whether the agent can receive traces encoded in the v0.5
+	// string table format on the /v0.5/traces endpoint.
+	V05 bool
+
 	// StatsdPort specifies the Dogstatsd port as provided by the agent.
 	// If it's the default, it will be 0, which means 8125.
 	StatsdPort int
@@ -627,6 +711,8 @@ func loadAgentFeatures(logToStdout bool, agentURL *url.URL, httpClient *http.Cli
 			features.Stats = true
 		case "/v0.1/pipeline_stats":
 			features.DataStreams = true
+		case "/v0.5/traces":
+			features.V05 = true
 		}
 	}
 	features.featureFlags = make(map[string]struct{}, len(info.FeatureFlags))
@@ -768,6 +854,17 @@ func WithDebugStack(enabled bool) StartOption {
 	}
 }
 
+// WithBinaryPropagation specifies whether span contexts injected into HeadersCarriers,
+// such as the Kafka message carriers, are stored in a single header using a compact
+// binary encoding instead of the configured propagation styles. Span contexts using the
+// binary encoding are always extracted, so consumers should be upgraded before producers
+// enable it.
+func WithBinaryPropagation(enabled bool) StartOption {
+	return func(c *config) {
+		c.binaryPropagation = enabled
+	}
+}
+
 // WithDebugMode enables debug mode on the tracer, resulting in more verbose logging.
 func WithDebugMode(enabled bool) StartOption {
 	return func(c *config) {
@@ -793,6 +890,19 @@ func WithSendRetries(retries int) StartOption {
 	}
 }
 
+// WithPayloadSpool enables storing trace payloads which could not be submitted to
+// the agent, even after retrying, in the directory dir. Stored payloads are sent,
+// oldest first, as soon as the agent is reachable again, including by subsequent
+// runs of the program using the same directory. When the total size of the stored
+// payloads would exceed maxBytes, the oldest ones are discarded. If maxBytes is not
+// positive, a limit of 64 MiB is used.
+func WithPayloadSpool(dir string, maxBytes int64) StartOption {
+	return func(c *config) {
+		c.spoolDir = dir
+		c.spoolMaxBytes = maxBytes
+	}
+}
+
 // WithPropagator sets an alternative propagator to be used by the tracer.
 func WithPropagator(p Propagator) StartOption {
 	return func(c *config) {
@@ -823,6 +933,42 @@ func WithService(name string) StartOption {
 	}
 }
 
+// WithOTLPExporter configures the tracer to send traces to the given OTLP/HTTP
+// endpoint (e.g. "http://otel-collector:4318"), such as an OpenTelemetry
+// Collector, instead of the Datadog Agent. Traces are encoded using the OTLP
+// protobuf encoding. If the endpoint has no path, the default "/v1/traces" is used.
+// Since there is no agent, client-side stats computation and agent-provided
+// sampling rates are not available when this option is used.
+func WithOTLPExporter(endpoint string) StartOption {
+	return func(c *config) {
+		c.otlpEndpoint = endpoint
+	}
+}
+
+// WithExporter registers a SpanExporter which will receive all finished traces
+// instead of the Datadog Agent. It may be used multiple times to register several
+// exporters, in which case each of them receives every trace. Since there is no
+// agent, client-side stats computation and agent-provided sampling rates are not
+// available when this option is used.
+func WithExporter(e SpanExporter) StartOption {
+	return func(c *config) {
+		if e != nil {
+			c.exporters = append(c.exporters, e)
+		}
+	}
+}
+
+// WithSpanProcessor registers a SpanProcessor which is called as spans are started and
+// finished, and before chunks of finished spans are sent. It may be used multiple times
+// to register several processors, which then run in registration order.
+func WithSpanProcessor(p SpanProcessor) StartOption {
+	return func(c *config) {
+		if p != nil {
+			c.spanProcessors = append(c.spanProcessors, p)
+		}
+	}
+}
+
 // WithGlobalServiceName causes contrib libraries to use the global service name and not any locally defined service name.
 // This is synonymous with `DD_TRACE_REMOVE_INTEGRATION_SERVICE_NAMES_ENABLED`.
 func WithGlobalServiceName(enabled bool) StartOption {
@@ -966,6 +1112,18 @@ func WithRuntimeMetrics() StartOption {
 	}
 }
 
+// WithRuntimeMetricsV2 enables automatic collection of runtime metrics every 10 seconds,
+// read from the runtime/metrics package. Unlike the metrics enabled by WithRuntimeMetrics,
+// collecting them does not stop the world. They include scheduler latencies and GC pauses
+// summarized as percentiles, the heap goal, goroutine counts by state and the mutex wait
+// time, and are reported under the runtime.go.metrics prefix.
+func WithRuntimeMetricsV2() StartOption {
+	return func(cfg *config) {
+		cfg.runtimeMetrics = true
+		cfg.runtimeMetricsV2 = true
+	}
+}
+
 // WithDogstatsdAddress specifies the address to connect to for sending metrics to the Datadog
 // Agent. It should be a "host:port" string, or the path to a unix domain socket.If not set, it
 // attempts to determine the address of the statsd service according to the following rules:
@@ -1075,6 +1233,65 @@ func WithDebugSpansMode(timeout time.Duration) StartOption {
 	}
 }
 
+// WithAbandonedSpansMetrics enables reporting the number of spans that may have been
+// abandoned, as found when WithDebugSpansMode is enabled. Each minute, the number of
+// open spans older than the timeout is sent to DogStatsD as datadog.tracer.abandoned_spans
+// and to instrumentation telemetry, tagged with the integration which created them.
+// This setting can also be configured by setting DD_TRACE_ABANDONED_SPANS_METRICS_ENABLED
+// to true.
+func WithAbandonedSpansMetrics(enabled bool) StartOption {
+	return func(c *config) {
+		c.abandonedSpansMetrics = enabled
+	}
+}
+
+// WithDebugSpansCreationStacks captures the stack which created spans when
+// WithDebugSpansMode is enabled, to find the code paths leaking them. The stack is
+// included in the abandoned spans logs, which show where the span was started, and in
+// the reports of AbandonedSpansHandler. Its program counters are recorded when the span
+// starts and only symbolized when it is reported. To bound the overhead, stacks are only
+// captured for the given rate of spans, between 0 and 1.
+// This setting can also be configured by setting DD_TRACE_DEBUG_ABANDONED_SPANS_STACK_RATE.
+func WithDebugSpansCreationStacks(rate float64) StartOption {
+	return func(c *config) {
+		c.abandonedSpansStackRate = rate
+	}
+}
+
+// WithTailSampling enables local tail-based sampling. The sampling decision of
+// each trace is deferred until all of its spans in this process have finished,
+// at which point the trace is kept if any of its spans matches any of the given
+// predicates, and otherwise follows the regular head-based sampling decision.
+// For example, the following keeps all traces with errors or lasting more than
+// 2 seconds, and 1% of the others:
+//
+//	tracer.Start(
+//		tracer.WithSamplingRules([]tracer.SamplingRule{tracer.RateRule(0.01)}),
+//		tracer.WithTailSampling(tracer.TailSampleErrors(), tracer.TailSampleSlowerThan(2*time.Second)),
+//	)
+//
+// Note that the decision is local to this process: services downstream of it
+// have already received the head-based decision. Partial flushing is disabled
+// for traces whose decision is deferred. See WithTailSamplingLimits to bound the
+// resources used by tail sampling.
+func WithTailSampling(predicates ...TailSamplingPredicate) StartOption {
+	return func(c *config) {
+		c.tailSamplingPredicates = append(c.tailSamplingPredicates, predicates...)
+	}
+}
+
+// WithTailSamplingLimits bounds the resources used by tail sampling: the decision
+// is deferred for at most maxTraces traces at once, further traces only being
+// subject to head-based sampling, and for at most timeout, after which the
+// finished spans of the trace are flushed. Non-positive values select the
+// defaults of 10000 traces and 30 seconds.
+func WithTailSamplingLimits(maxTraces int, timeout time.Duration) StartOption {
+	return func(c *config) {
+		c.tailSamplingMaxTraces = maxTraces
+		c.tailSamplingTimeout = timeout
+	}
+}
+
 // WithPartialFlushing enables flushing of partially finished traces.
 // This is done after "numSpans" have finished in a single local trace at
 // which point all finished spans in that trace will be flushed, freeing up
@@ -1100,6 +1317,19 @@ func WithStatsComputation(enabled bool) StartOption {
 	}
 }
 
+// WithREDMetrics enables the export of RED (rate, errors, duration) metrics computed
+// from the spans of the tracer, using the same aggregations as client-side stats. Each
+// 10 seconds, the hits and errors counts are sent to DogStatsD as trace.red.hits and
+// trace.red.errors, and span durations as the trace.red.duration distribution. The
+// metrics are also exposed in the Prometheus text format by REDMetricsHandler.
+// They are computed whether or not the agent supports client-side stats.
+// This can also be configured by setting DD_TRACE_RED_METRICS_ENABLED to true.
+func WithREDMetrics(enabled bool) StartOption {
+	return func(c *config) {
+		c.redMetrics = enabled
+	}
+}
+
 // WithOrchestrion configures Orchestrion's auto-instrumentation metadata.
 // This option is only intended to be used by Orchestrion https://github.com/DataDog/orchestrion
 func WithOrchestrion(metadata map[string]string) StartOption {
@@ -1166,6 +1396,15 @@ func ChildOf(ctx ddtrace.SpanContext) StartSpanOption {
 	}
 }
 
+// WithSpanLinks sets span links on the started span. Links reference spans
+// in other traces which are causally related to the started span, e.g. the
+// producer spans of all the messages processed by a batch consumer.
+func WithSpanLinks(links []ddtrace.SpanLink) StartSpanOption {
+	return func(cfg *ddtrace.StartSpanConfig) {
+		cfg.SpanLinks = append(cfg.SpanLinks, links...)
+	}
+}
+
 // withContext associates the ctx with the span.
 func withContext(ctx context.Context) StartSpanOption {
 	return func(cfg *ddtrace.StartSpanConfig) {
@@ -1205,8 +1444,10 @@ func FinishTime(t time.Time) FinishOption {
 }
 
 // WithError marks the span as having had an error. It uses the information from
-// err to set tags such as the error message, error type and stack trace. It has
-// no effect if the error is nil.
+// err to set tags such as the error message, error type and stack trace. Wrapped
+// errors are inspected to also record the type of the innermost cause, the stack
+// trace stored by packages such as github.com/pkg/errors, and a fingerprint for
+// grouping similar errors. It has no effect if the error is nil.
 func WithError(err error) FinishOption {
 	return func(cfg *ddtrace.FinishConfig) {
 		cfg.Error = err
@@ -219,6 +219,22 @@ func TestLoadAgentFeatures(t *testing.T) {
 		assert.True(t, cfg.agent.Stats)
 		assert.True(t, cfg.agent.HasFlag("a"))
 		assert.True(t, cfg.agent.HasFlag("b"))
+		assert.False(t, cfg.agent.V05)
+		assert.Equal(t, traceProtocolV04, cfg.traceProtocol)
+	})
+
+	t.Run("v0.5", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(`{"endpoints":["/v0.4/traces","/v0.5/traces","/v0.6/stats"]}`))
+		}))
+		defer srv.Close()
+		cfg := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		assert.True(t, cfg.agent.V05)
+		assert.Equal(t, traceProtocolV05, cfg.traceProtocol)
+
+		cfg = newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")), withTransport(newDummyTransport()))
+		assert.True(t, cfg.agent.V05)
+		assert.Equal(t, traceProtocolV04, cfg.traceProtocol)
 	})
 
 	t.Run("discovery", func(t *testing.T) {
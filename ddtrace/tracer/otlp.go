@@ -0,0 +1,420 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
+
+	"github.com/tinylib/msgp/msgp"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// otlpTracesPath is the default OTLP/HTTP path for traces, used when the configured
+// endpoint does not specify one.
+const otlpTracesPath = "/v1/traces"
+
+// otlpTransport is a transport which sends traces to an OTLP/HTTP endpoint, such as an
+// OpenTelemetry Collector, using the protobuf encoding of ExportTraceServiceRequest.
+// See https://github.com/open-telemetry/opentelemetry-proto/blob/v1.0.0/opentelemetry/proto/collector/trace/v1/trace_service.proto
+type otlpTransport struct {
+	traceURL string            // the delivery URL for traces
+	client   *http.Client      // the HTTP client used in the POST
+	headers  map[string]string // the Transport headers
+}
+
+var _ transport = (*otlpTransport)(nil)
+
+// newOTLPTransport returns a new transport that sends traces to the OTLP/HTTP endpoint
+// found at the given URL, using the given *http.Client.
+func newOTLPTransport(endpoint string, client *http.Client) *otlpTransport {
+	traceURL := endpoint
+	if u, err := url.Parse(endpoint); err == nil && (u.Path == "" || u.Path == "/") {
+		u.Path = otlpTracesPath
+		traceURL = u.String()
+	}
+	return &otlpTransport{
+		traceURL: traceURL,
+		client:   client,
+		headers: map[string]string{
+			"Content-Type": "application/x-protobuf",
+			"User-Agent":   "dd-trace-go/" + version.Tag,
+		},
+	}
+}
+
+// send decodes the traces held by p and sends them as an OTLP request. The returned
+// body holds an empty set of sampling rates, as the collector does not provide any.
+func (t *otlpTransport) send(p *payload) (body io.ReadCloser, err error) {
+	var traces spanLists
+	if err := msgp.Decode(p, &traces); err != nil {
+		return nil, fmt.Errorf("cannot decode payload: %v", err)
+	}
+	req, err := http.NewRequest("POST", t.traceURL, bytes.NewReader(encodeOTLPTraces(traces)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create http request: %v", err)
+	}
+	for header, value := range t.headers {
+		req.Header.Set(header, value)
+	}
+	response, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 400 {
+		return nil, newHTTPStatusError(response)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	return io.NopCloser(strings.NewReader("{}")), nil
+}
+
+// sendStats implements transport. OTLP endpoints do not accept client-computed stats.
+func (t *otlpTransport) sendStats(_ *statsPayload) error {
+	return errors.New("stats are not supported by the OTLP exporter")
+}
+
+func (t *otlpTransport) endpoint() string {
+	return t.traceURL
+}
+
+// Field numbers and enum values of the OTLP protobuf messages, as defined in
+// https://github.com/open-telemetry/opentelemetry-proto/tree/v1.0.0/opentelemetry/proto
+const (
+	otlpRequestResourceSpans protowire.Number = 1 // ExportTraceServiceRequest.resource_spans
+
+	otlpResourceSpansResource   protowire.Number = 1 // ResourceSpans.resource
+	otlpResourceSpansScopeSpans protowire.Number = 2 // ResourceSpans.scope_spans
+
+	otlpResourceAttributes protowire.Number = 1 // Resource.attributes
+
+	otlpScopeSpansScope protowire.Number = 1 // ScopeSpans.scope
+	otlpScopeSpansSpans protowire.Number = 2 // ScopeSpans.spans
+
+	otlpScopeName    protowire.Number = 1 // InstrumentationScope.name
+	otlpScopeVersion protowire.Number = 2 // InstrumentationScope.version
+
+	otlpSpanTraceID      protowire.Number = 1  // Span.trace_id
+	otlpSpanSpanID       protowire.Number = 2  // Span.span_id
+	otlpSpanParentSpanID protowire.Number = 4  // Span.parent_span_id
+	otlpSpanName         protowire.Number = 5  // Span.name
+	otlpSpanKind         protowire.Number = 6  // Span.kind
+	otlpSpanStartTime    protowire.Number = 7  // Span.start_time_unix_nano
+	otlpSpanEndTime      protowire.Number = 8  // Span.end_time_unix_nano
+	otlpSpanAttributes   protowire.Number = 9  // Span.attributes
+	otlpSpanEvents       protowire.Number = 11 // Span.events
+	otlpSpanLinks        protowire.Number = 13 // Span.links
+	otlpSpanStatus       protowire.Number = 15 // Span.status
+
+	otlpEventTime       protowire.Number = 1 // Span.Event.time_unix_nano
+	otlpEventName       protowire.Number = 2 // Span.Event.name
+	otlpEventAttributes protowire.Number = 3 // Span.Event.attributes
+
+	otlpLinkTraceID    protowire.Number = 1 // Span.Link.trace_id
+	otlpLinkSpanID     protowire.Number = 2 // Span.Link.span_id
+	otlpLinkTraceState protowire.Number = 3 // Span.Link.trace_state
+	otlpLinkAttributes protowire.Number = 4 // Span.Link.attributes
+	otlpLinkFlags      protowire.Number = 6 // Span.Link.flags
+
+	otlpStatusMessage protowire.Number = 2 // Status.message
+	otlpStatusCode    protowire.Number = 3 // Status.code
+
+	otlpKeyValueKey   protowire.Number = 1 // KeyValue.key
+	otlpKeyValueValue protowire.Number = 2 // KeyValue.value
+
+	otlpAnyValueString protowire.Number = 1 // AnyValue.string_value
+	otlpAnyValueBool   protowire.Number = 2 // AnyValue.bool_value
+	otlpAnyValueInt    protowire.Number = 3 // AnyValue.int_value
+	otlpAnyValueDouble protowire.Number = 4 // AnyValue.double_value
+
+	otlpStatusCodeError = 2 // Status.StatusCode.STATUS_CODE_ERROR
+)
+
+// otlpSpanKinds maps the values of the span.kind tag to the OTLP Span.SpanKind enum.
+var otlpSpanKinds = map[string]uint64{
+	ext.SpanKindInternal: 1,
+	ext.SpanKindServer:   2,
+	ext.SpanKindClient:   3,
+	ext.SpanKindProducer: 4,
+	ext.SpanKindConsumer: 5,
+}
+
+// otlpResource identifies the OTLP resource that a span belongs to.
+type otlpResource struct {
+	service, env, version string
+}
+
+// otlpSpan holds a span along with the upper 64 bits of its trace ID, which is
+// only known by the first span of each chunk.
+type otlpSpan struct {
+	*span
+	traceIDUpper uint64
+}
+
+// encodeOTLPTraces encodes the given traces into an OTLP ExportTraceServiceRequest.
+// Spans are grouped into one ResourceSpans per service, environment and version.
+func encodeOTLPTraces(traces spanLists) []byte {
+	var order []otlpResource
+	groups := make(map[otlpResource][]otlpSpan)
+	for _, trace := range traces {
+		var upper uint64
+		for _, s := range trace {
+			if tid, ok := s.Meta[keyTraceID128]; ok {
+				if u, err := strconv.ParseUint(tid, 16, 64); err == nil {
+					upper = u
+				}
+				break
+			}
+		}
+		for _, s := range trace {
+			r := otlpResource{
+				service: s.Service,
+				env:     s.Meta[ext.Environment],
+				version: s.Meta[ext.Version],
+			}
+			if _, ok := groups[r]; !ok {
+				order = append(order, r)
+			}
+			groups[r] = append(groups[r], otlpSpan{span: s, traceIDUpper: upper})
+		}
+	}
+	var b []byte
+	for _, r := range order {
+		b = protowire.AppendTag(b, otlpRequestResourceSpans, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeOTLPResourceSpans(r, groups[r]))
+	}
+	return b
+}
+
+func encodeOTLPResourceSpans(r otlpResource, spans []otlpSpan) []byte {
+	var res []byte
+	res = appendOTLPStringAttr(res, otlpResourceAttributes, "service.name", r.service)
+	if r.env != "" {
+		res = appendOTLPStringAttr(res, otlpResourceAttributes, "deployment.environment", r.env)
+	}
+	if r.version != "" {
+		res = appendOTLPStringAttr(res, otlpResourceAttributes, "service.version", r.version)
+	}
+	res = appendOTLPStringAttr(res, otlpResourceAttributes, "telemetry.sdk.name", "datadog")
+	res = appendOTLPStringAttr(res, otlpResourceAttributes, "telemetry.sdk.language", "go")
+	res = appendOTLPStringAttr(res, otlpResourceAttributes, "telemetry.sdk.version", version.Tag)
+
+	var scope []byte
+	scope = protowire.AppendTag(scope, otlpScopeName, protowire.BytesType)
+	scope = protowire.AppendString(scope, "dd-trace-go")
+	scope = protowire.AppendTag(scope, otlpScopeVersion, protowire.BytesType)
+	scope = protowire.AppendString(scope, version.Tag)
+
+	var ss []byte
+	ss = protowire.AppendTag(ss, otlpScopeSpansScope, protowire.BytesType)
+	ss = protowire.AppendBytes(ss, scope)
+	for _, s := range spans {
+		ss = protowire.AppendTag(ss, otlpScopeSpansSpans, protowire.BytesType)
+		ss = protowire.AppendBytes(ss, encodeOTLPSpan(s))
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, otlpResourceSpansResource, protowire.BytesType)
+	b = protowire.AppendBytes(b, res)
+	b = protowire.AppendTag(b, otlpResourceSpansScopeSpans, protowire.BytesType)
+	return protowire.AppendBytes(b, ss)
+}
+
+func encodeOTLPSpan(s otlpSpan) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, otlpSpanTraceID, protowire.BytesType)
+	b = protowire.AppendBytes(b, otlpTraceID(s.traceIDUpper, s.TraceID))
+	b = protowire.AppendTag(b, otlpSpanSpanID, protowire.BytesType)
+	b = protowire.AppendBytes(b, otlpSpanID(s.SpanID))
+	if s.ParentID != 0 {
+		b = protowire.AppendTag(b, otlpSpanParentSpanID, protowire.BytesType)
+		b = protowire.AppendBytes(b, otlpSpanID(s.ParentID))
+	}
+	b = protowire.AppendTag(b, otlpSpanName, protowire.BytesType)
+	b = protowire.AppendString(b, s.Name)
+	kind, ok := otlpSpanKinds[s.Meta[ext.SpanKind]]
+	if !ok {
+		kind = otlpSpanKinds[ext.SpanKindInternal]
+	}
+	b = protowire.AppendTag(b, otlpSpanKind, protowire.VarintType)
+	b = protowire.AppendVarint(b, kind)
+	b = protowire.AppendTag(b, otlpSpanStartTime, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(s.Start))
+	b = protowire.AppendTag(b, otlpSpanEndTime, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, uint64(s.Start+s.Duration))
+
+	// The Datadog specific fields are sent as attributes recognized by the
+	// Datadog OTLP ingestion.
+	b = appendOTLPStringAttr(b, otlpSpanAttributes, "operation.name", s.Name)
+	b = appendOTLPStringAttr(b, otlpSpanAttributes, "resource.name", s.Resource)
+	if s.Type != "" {
+		b = appendOTLPStringAttr(b, otlpSpanAttributes, "span.type", s.Type)
+	}
+	for k, v := range s.Meta {
+		if k == keySpanEvents {
+			continue // sent as native span events below
+		}
+		b = appendOTLPStringAttr(b, otlpSpanAttributes, k, v)
+	}
+	for k, v := range s.Metrics {
+		b = appendOTLPDoubleAttr(b, otlpSpanAttributes, k, v)
+	}
+	for _, e := range decodeOTLPEvents(s.Meta[keySpanEvents]) {
+		var event []byte
+		event = protowire.AppendTag(event, otlpEventTime, protowire.Fixed64Type)
+		event = protowire.AppendFixed64(event, uint64(e.TimeUnixNano))
+		event = protowire.AppendTag(event, otlpEventName, protowire.BytesType)
+		event = protowire.AppendString(event, e.Name)
+		for k, v := range e.Attributes {
+			event = appendOTLPKeyValue(event, otlpEventAttributes, k, otlpAnyValue(v))
+		}
+		b = protowire.AppendTag(b, otlpSpanEvents, protowire.BytesType)
+		b = protowire.AppendBytes(b, event)
+	}
+	for _, l := range s.SpanLinks {
+		var link []byte
+		link = protowire.AppendTag(link, otlpLinkTraceID, protowire.BytesType)
+		link = protowire.AppendBytes(link, otlpTraceID(l.TraceIDHigh, l.TraceID))
+		link = protowire.AppendTag(link, otlpLinkSpanID, protowire.BytesType)
+		link = protowire.AppendBytes(link, otlpSpanID(l.SpanID))
+		if l.Tracestate != "" {
+			link = protowire.AppendTag(link, otlpLinkTraceState, protowire.BytesType)
+			link = protowire.AppendString(link, l.Tracestate)
+		}
+		for k, v := range l.Attributes {
+			link = appendOTLPStringAttr(link, otlpLinkAttributes, k, v)
+		}
+		if l.Flags != 0 {
+			link = protowire.AppendTag(link, otlpLinkFlags, protowire.Fixed32Type)
+			link = protowire.AppendFixed32(link, l.Flags)
+		}
+		b = protowire.AppendTag(b, otlpSpanLinks, protowire.BytesType)
+		b = protowire.AppendBytes(b, link)
+	}
+	if s.Error != 0 {
+		var status []byte
+		if msg := s.Meta[ext.ErrorMsg]; msg != "" {
+			status = protowire.AppendTag(status, otlpStatusMessage, protowire.BytesType)
+			status = protowire.AppendString(status, msg)
+		}
+		status = protowire.AppendTag(status, otlpStatusCode, protowire.VarintType)
+		status = protowire.AppendVarint(status, otlpStatusCodeError)
+		b = protowire.AppendTag(b, otlpSpanStatus, protowire.BytesType)
+		b = protowire.AppendBytes(b, status)
+	}
+	return b
+}
+
+// otlpTraceID returns the 16 bytes, big endian representation of a 128-bit trace ID.
+func otlpTraceID(upper, lower uint64) []byte {
+	var id traceID
+	id.SetUpper(upper)
+	id.SetLower(lower)
+	return id[:]
+}
+
+// otlpSpanID returns the 8 bytes, big endian representation of a span ID.
+func otlpSpanID(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+// appendOTLPStringAttr appends a KeyValue message holding a string value as field num of b.
+func appendOTLPStringAttr(b []byte, num protowire.Number, k, v string) []byte {
+	var val []byte
+	val = protowire.AppendTag(val, otlpAnyValueString, protowire.BytesType)
+	val = protowire.AppendString(val, v)
+	return appendOTLPKeyValue(b, num, k, val)
+}
+
+// appendOTLPDoubleAttr appends a KeyValue message holding a double value as field num of b.
+func appendOTLPDoubleAttr(b []byte, num protowire.Number, k string, v float64) []byte {
+	var val []byte
+	val = protowire.AppendTag(val, otlpAnyValueDouble, protowire.Fixed64Type)
+	val = protowire.AppendFixed64(val, math.Float64bits(v))
+	return appendOTLPKeyValue(b, num, k, val)
+}
+
+// decodeOTLPEvents decodes the JSON encoded span events held by the keySpanEvents tag.
+// Integer attributes are kept as integers.
+func decodeOTLPEvents(v string) []ddtrace.SpanEvent {
+	if v == "" {
+		return nil
+	}
+	var events []ddtrace.SpanEvent
+	d := json.NewDecoder(strings.NewReader(v))
+	d.UseNumber()
+	if err := d.Decode(&events); err != nil {
+		log.Debug("Unable to decode span events, dropping them: %v", err)
+		return nil
+	}
+	for _, e := range events {
+		for k, v := range e.Attributes {
+			n, ok := v.(json.Number)
+			if !ok {
+				continue
+			}
+			if i, err := n.Int64(); err == nil {
+				e.Attributes[k] = i
+			} else if f, err := n.Float64(); err == nil {
+				e.Attributes[k] = f
+			}
+		}
+	}
+	return events
+}
+
+// otlpAnyValue returns the AnyValue message holding v. Values which are not strings,
+// booleans or numbers are formatted as strings.
+func otlpAnyValue(v interface{}) []byte {
+	var val []byte
+	switch v := v.(type) {
+	case string:
+		val = protowire.AppendTag(val, otlpAnyValueString, protowire.BytesType)
+		val = protowire.AppendString(val, v)
+	case bool:
+		val = protowire.AppendTag(val, otlpAnyValueBool, protowire.VarintType)
+		val = protowire.AppendVarint(val, protowire.EncodeBool(v))
+	case int:
+		val = protowire.AppendTag(val, otlpAnyValueInt, protowire.VarintType)
+		val = protowire.AppendVarint(val, uint64(v))
+	case int64:
+		val = protowire.AppendTag(val, otlpAnyValueInt, protowire.VarintType)
+		val = protowire.AppendVarint(val, uint64(v))
+	case float64:
+		val = protowire.AppendTag(val, otlpAnyValueDouble, protowire.Fixed64Type)
+		val = protowire.AppendFixed64(val, math.Float64bits(v))
+	default:
+		val = protowire.AppendTag(val, otlpAnyValueString, protowire.BytesType)
+		val = protowire.AppendString(val, fmt.Sprint(v))
+	}
+	return val
+}
+
+func appendOTLPKeyValue(b []byte, num protowire.Number, k string, val []byte) []byte {
+	var kv []byte
+	kv = protowire.AppendTag(kv, otlpKeyValueKey, protowire.BytesType)
+	kv = protowire.AppendString(kv, k)
+	kv = protowire.AppendTag(kv, otlpKeyValueValue, protowire.BytesType)
+	kv = protowire.AppendBytes(kv, val)
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, kv)
+}
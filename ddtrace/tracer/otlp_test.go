@@ -0,0 +1,217 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoMessage holds the decoded fields of a protobuf message, by field number. Values
+// are uint64 for varint and fixed size fields, and []byte for length-delimited fields.
+type protoMessage map[protowire.Number][]interface{}
+
+func decodeProto(t *testing.T, b []byte) protoMessage {
+	m := protoMessage{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		var v interface{}
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var u uint32
+			u, n = protowire.ConsumeFixed32(b)
+			v = uint64(u)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		m[num] = append(m[num], v)
+	}
+	return m
+}
+
+func (m protoMessage) message(t *testing.T, num protowire.Number) protoMessage {
+	return decodeProto(t, m[num][0].([]byte))
+}
+
+func (m protoMessage) messages(t *testing.T, num protowire.Number) []protoMessage {
+	var msgs []protoMessage
+	for _, v := range m[num] {
+		msgs = append(msgs, decodeProto(t, v.([]byte)))
+	}
+	return msgs
+}
+
+func (m protoMessage) str(num protowire.Number) string {
+	return string(m[num][0].([]byte))
+}
+
+// attributes decodes the KeyValue messages found at field num of m.
+func (m protoMessage) attributes(t *testing.T, num protowire.Number) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for _, kv := range m.messages(t, num) {
+		val := kv.message(t, otlpKeyValueValue)
+		if v, ok := val[otlpAnyValueString]; ok {
+			attrs[kv.str(otlpKeyValueKey)] = string(v[0].([]byte))
+		} else if v, ok := val[otlpAnyValueInt]; ok {
+			attrs[kv.str(otlpKeyValueKey)] = int64(v[0].(uint64))
+		} else if v, ok := val[otlpAnyValueBool]; ok {
+			attrs[kv.str(otlpKeyValueKey)] = v[0].(uint64) != 0
+		} else {
+			attrs[kv.str(otlpKeyValueKey)] = math.Float64frombits(val[otlpAnyValueDouble][0].(uint64))
+		}
+	}
+	return attrs
+}
+
+func TestOTLPTransportEndpoint(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"http://localhost:4318":                "http://localhost:4318/v1/traces",
+		"http://localhost:4318/":               "http://localhost:4318/v1/traces",
+		"https://collector.example.com/traces": "https://collector.example.com/traces",
+	} {
+		assert.Equal(t, want, newOTLPTransport(endpoint, defaultClient).endpoint())
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	assert := assert.New(t)
+	requests := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/v1/traces", r.URL.Path)
+		assert.Equal("application/x-protobuf", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(err)
+		requests <- body
+	}))
+	defer srv.Close()
+
+	tracer := newTracer(
+		WithOTLPExporter(srv.URL),
+		WithService("otlp-svc"),
+		WithEnv("prod"),
+		WithServiceVersion("1.2.3"),
+		withNoopStats(),
+	)
+	internal.SetGlobalTracer(tracer)
+	defer tracer.Stop()
+	assert.False(tracer.config.canComputeStats())
+
+	link := ddtrace.SpanLink{TraceID: 7, TraceIDHigh: 8, SpanID: 9, Tracestate: "dd=s:1", Flags: 1}
+	root := tracer.StartSpan("http.request", ResourceName("GET /users"), SpanType(ext.SpanTypeWeb),
+		Tag(ext.SpanKind, ext.SpanKindServer))
+	child := tracer.StartSpan("kafka.consume", ChildOf(root.Context()), WithSpanLinks([]ddtrace.SpanLink{link}))
+	eventTime := time.Unix(1700000000, 42)
+	child.(ddtrace.SpanWithEvents).AddEvent("retry", eventTime, map[string]interface{}{
+		"cache.key": "user:42",
+		"attempt":   2,
+		"hit":       false,
+		"ratio":     0.5,
+	})
+	child.Finish(WithError(errors.New("timeout")))
+	root.Finish()
+	tracer.flushSync()
+
+	var body []byte
+	select {
+	case body = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the OTLP request")
+	}
+	req := decodeProto(t, body)
+	require.Len(t, req[otlpRequestResourceSpans], 1)
+	rs := req.message(t, otlpRequestResourceSpans)
+
+	resource := rs.message(t, otlpResourceSpansResource).attributes(t, otlpResourceAttributes)
+	assert.Equal("otlp-svc", resource["service.name"])
+	assert.Equal("prod", resource["deployment.environment"])
+	assert.Equal("1.2.3", resource["service.version"])
+
+	ss := rs.message(t, otlpResourceSpansScopeSpans)
+	assert.Equal("dd-trace-go", ss.message(t, otlpScopeSpansScope).str(otlpScopeName))
+	spans := ss.messages(t, otlpScopeSpansSpans)
+	require.Len(t, spans, 2)
+	byName := map[string]protoMessage{}
+	for _, s := range spans {
+		byName[s.str(otlpSpanName)] = s
+	}
+
+	rootCtx := root.Context().(*spanContext)
+	traceID := rootCtx.TraceID128Bytes()
+	r, c := byName["http.request"], byName["kafka.consume"]
+	assert.Equal(traceID[:], r[otlpSpanTraceID][0])
+	assert.Equal(traceID[:], c[otlpSpanTraceID][0])
+	assert.Equal(otlpSpanID(root.Context().SpanID()), r[otlpSpanSpanID][0])
+	assert.Equal(otlpSpanID(root.Context().SpanID()), c[otlpSpanParentSpanID][0])
+	assert.NotContains(r, otlpSpanParentSpanID)
+	assert.Equal(uint64(2), r[otlpSpanKind][0])
+	assert.Equal(uint64(1), c[otlpSpanKind][0])
+	assert.Greater(r[otlpSpanEndTime][0].(uint64), r[otlpSpanStartTime][0].(uint64))
+
+	attrs := r.attributes(t, otlpSpanAttributes)
+	assert.Equal("GET /users", attrs["resource.name"])
+	assert.Equal(ext.SpanTypeWeb, attrs["span.type"])
+	assert.Equal(float64(1), attrs[keyTopLevel])
+	assert.NotContains(r, otlpSpanStatus)
+
+	status := c.message(t, otlpSpanStatus)
+	assert.Equal("timeout", status.str(otlpStatusMessage))
+	assert.Equal(uint64(otlpStatusCodeError), status[otlpStatusCode][0])
+
+	links := c.messages(t, otlpSpanLinks)
+	require.Len(t, links, 1)
+	assert.Equal(otlpTraceID(8, 7), links[0][otlpLinkTraceID][0])
+	assert.Equal(otlpSpanID(9), links[0][otlpLinkSpanID][0])
+	assert.Equal("dd=s:1", links[0].str(otlpLinkTraceState))
+	assert.Equal(uint64(1), links[0][otlpLinkFlags][0])
+
+	assert.NotContains(c.attributes(t, otlpSpanAttributes), keySpanEvents)
+	events := c.messages(t, otlpSpanEvents)
+	require.Len(t, events, 1)
+	assert.Equal("retry", events[0].str(otlpEventName))
+	assert.Equal(uint64(eventTime.UnixNano()), events[0][otlpEventTime][0])
+	assert.Equal(map[string]interface{}{
+		"cache.key": "user:42",
+		"attempt":   int64(2),
+		"hit":       false,
+		"ratio":     0.5,
+	}, events[0].attributes(t, otlpEventAttributes))
+}
+
+func TestOTLPTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("collector overloaded"))
+	}))
+	defer srv.Close()
+
+	p := newPayload()
+	p.push(newSpanList(1))
+	_, err := newOTLPTransport(srv.URL, defaultClient).send(p)
+	assert.EqualError(t, err, "collector overloaded (Status: Service Unavailable)")
+}
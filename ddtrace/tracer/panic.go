@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+)
+
+// panicErrorType is the value of the error.type tag of spans finished because of a panic.
+const panicErrorType = "panic"
+
+// PanicError is the error set on spans finished using WithPanicCapture. It holds the
+// value recovered from a panic along with the stack of the panicking goroutine.
+type PanicError struct {
+	// Value is the value which was recovered from the panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine, as returned by debug.Stack.
+	Stack []byte
+}
+
+// Error implements error.
+func (e *PanicError) Error() string {
+	return fmt.Sprint(e.Value)
+}
+
+// Unwrap returns the recovered value if it is an error, or nil otherwise.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// WithPanicCapture marks the span as having been finished because of a panic, given r,
+// the value returned by recover. It tags the span with an error.type of "panic", the
+// recovered value as the error message and the stack of the panicking goroutine. It has
+// no effect if r is nil. It must be used from within the deferred function which
+// recovered from the panic, so that the stack of the panicking goroutine is still available.
+func WithPanicCapture(r interface{}) FinishOption {
+	if r == nil {
+		return func(_ *ddtrace.FinishConfig) {}
+	}
+	err := &PanicError{Value: r, Stack: debug.Stack()}
+	return func(cfg *ddtrace.FinishConfig) {
+		cfg.Error = err
+	}
+}
+
+// RecoverAndFinish finishes the span s using the given options. If the goroutine is
+// panicking, it recovers, finishes the span with the panic information as described by
+// WithPanicCapture, and re-panics with the same value. It must be called directly by a
+// deferred function call for recover to take effect, for example:
+//
+//	span := tracer.StartSpan("job.run")
+//	defer tracer.RecoverAndFinish(span)
+func RecoverAndFinish(s Span, opts ...FinishOption) {
+	r := recover()
+	if r == nil {
+		s.Finish(opts...)
+		return
+	}
+	s.Finish(append(opts[:len(opts):len(opts)], WithPanicCapture(r))...)
+	panic(r)
+}
@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func panickingJob(s Span) {
+	defer RecoverAndFinish(s)
+	panic(io.EOF)
+}
+
+func TestRecoverAndFinish(t *testing.T) {
+	t.Run("panic", func(t *testing.T) {
+		assert := assert.New(t)
+		span := newBasicSpan("job.run")
+		assert.PanicsWithValue(io.EOF, func() { panickingJob(span) })
+
+		assert.True(span.finished)
+		assert.Equal(int32(1), span.Error)
+		assert.Equal("panic", span.Meta[ext.ErrorType])
+		assert.Equal("EOF", span.Meta[ext.ErrorMsg])
+		assert.Contains(span.Meta[ext.ErrorStack], "goroutine ")
+		assert.Contains(span.Meta[ext.ErrorStack], "tracer.panickingJob")
+		assert.NotEmpty(span.Meta[ext.ErrorFingerprint])
+	})
+
+	t.Run("no-panic", func(t *testing.T) {
+		assert := assert.New(t)
+		span := newBasicSpan("job.run")
+		func() {
+			defer RecoverAndFinish(span, WithError(io.EOF))
+		}()
+
+		assert.True(span.finished)
+		assert.Equal("*errors.errorString", span.Meta[ext.ErrorType])
+	})
+
+	t.Run("no-debug-stack", func(t *testing.T) {
+		assert := assert.New(t)
+		span := newBasicSpan("job.run")
+		assert.Panics(func() {
+			defer RecoverAndFinish(span, NoDebugStack())
+			panic("boom")
+		})
+
+		assert.Equal("panic", span.Meta[ext.ErrorType])
+		assert.Equal("boom", span.Meta[ext.ErrorMsg])
+		assert.Empty(span.Meta[ext.ErrorStack])
+	})
+}
+
+func TestWithPanicCapture(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		span := newBasicSpan("job.run")
+		span.Finish(WithPanicCapture(nil))
+		assert.Equal(t, int32(0), span.Error)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		span := newBasicSpan("job.run")
+		span.Finish(WithPanicCapture(io.EOF))
+		assert.Equal(t, int32(1), span.Error)
+		assert.Equal(t, "panic", span.Meta[ext.ErrorType])
+	})
+
+	t.Run("unwrap", func(t *testing.T) {
+		assert.True(t, errors.Is(&PanicError{Value: io.EOF}, io.EOF))
+		assert.Nil(t, (&PanicError{Value: "boom"}).Unwrap())
+	})
+}
@@ -8,6 +8,7 @@ package tracer
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"io"
 	"sync/atomic"
 
@@ -57,24 +58,65 @@ type payload struct {
 
 	// reader is used for reading the contents of buf.
 	reader *bytes.Reader
+
+	// protocol specifies the trace encoding used by this payload. It is either
+	// traceProtocolV04 or traceProtocolV05.
+	protocol float64
+
+	// strings holds the string table used by the v0.5 encoding. It is nil for
+	// v0.4 payloads.
+	strings *stringTable
+
+	// v05reader is used for reading the contents of a v0.5 payload, including
+	// its string table.
+	v05reader io.Reader
+
+	// scratch is a reusable buffer for encoding v0.5 traces.
+	scratch []byte
 }
 
+const (
+	// traceProtocolV04 is the default trace encoding, in which each span is a
+	// msgpack map holding all of its strings.
+	traceProtocolV04 = 0.4
+
+	// traceProtocolV05 is the trace encoding in which all strings are deduplicated
+	// into a string table and spans are msgpack arrays referring to it by index.
+	// See https://github.com/DataDog/datadog-agent/blob/7.48.0/pkg/trace/api/version.go#L62-L84
+	traceProtocolV05 = 0.5
+)
+
 var _ io.Reader = (*payload)(nil)
 
 // newPayload returns a ready to use payload.
 func newPayload() *payload {
 	p := &payload{
-		header: make([]byte, 8),
-		off:    8,
+		header:   make([]byte, 8),
+		off:      8,
+		protocol: traceProtocolV04,
 	}
 	return p
 }
 
+// newPayloadV05 returns a ready to use payload which encodes traces using the
+// v0.5 string table format.
+func newPayloadV05() *payload {
+	p := newPayload()
+	p.protocol = traceProtocolV05
+	p.strings = newStringTable()
+	return p
+}
+
 // push pushes a new item into the stream.
 func (p *payload) push(t spanList) error {
-	p.buf.Grow(t.Msgsize())
-	if err := msgp.Encode(&p.buf, t); err != nil {
-		return err
+	if p.protocol == traceProtocolV05 {
+		p.scratch = encodeSpanListV05(p.scratch[:0], t, p.strings)
+		p.buf.Write(p.scratch)
+	} else {
+		p.buf.Grow(t.Msgsize())
+		if err := msgp.Encode(&p.buf, t); err != nil {
+			return err
+		}
 	}
 	atomic.AddUint32(&p.count, 1)
 	p.updateHeader()
@@ -89,6 +131,10 @@ func (p *payload) itemCount() int {
 // size returns the payload size in bytes. After the first read the value becomes
 // inaccurate by up to 8 bytes.
 func (p *payload) size() int {
+	if p.protocol == traceProtocolV05 {
+		// 1 byte for the outer fixarray holding the string table and the traces
+		return 1 + p.strings.size() + p.buf.Len() + len(p.header) - p.off
+	}
 	return p.buf.Len() + len(p.header) - p.off
 }
 
@@ -100,12 +146,18 @@ func (p *payload) reset() {
 	if p.reader != nil {
 		p.reader.Seek(0, 0)
 	}
+	p.v05reader = nil
 }
 
 // clear empties the payload buffers.
 func (p *payload) clear() {
 	p.buf = bytes.Buffer{}
 	p.reader = nil
+	p.v05reader = nil
+	p.scratch = nil
+	if p.strings != nil {
+		p.strings = newStringTable()
+	}
 }
 
 // https://github.com/msgpack/msgpack/blob/master/spec.md#array-format-family
@@ -141,6 +193,9 @@ func (p *payload) Close() error {
 
 // Read implements io.Reader. It reads from the msgpack-encoded stream.
 func (p *payload) Read(b []byte) (n int, err error) {
+	if p.protocol == traceProtocolV05 {
+		return p.readV05(b)
+	}
 	if p.off < len(p.header) {
 		// reading header
 		n = copy(b, p.header[p.off:])
@@ -152,3 +207,107 @@ func (p *payload) Read(b []byte) (n int, err error) {
 	}
 	return p.reader.Read(b)
 }
+
+// readV05 reads from a v0.5 payload, which is a msgpack array of two elements:
+// the string table and the array of traces.
+func (p *payload) readV05(b []byte) (n int, err error) {
+	if p.v05reader == nil {
+		p.v05reader = io.MultiReader(
+			bytes.NewReader([]byte{msgpackArrayFix + 2}),
+			bytes.NewReader(msgp.AppendArrayHeader(nil, uint32(len(p.strings.list)))),
+			bytes.NewReader(p.strings.buf.Bytes()),
+			bytes.NewReader(p.header[p.off:]),
+			bytes.NewReader(p.buf.Bytes()),
+		)
+	}
+	return p.v05reader.Read(b)
+}
+
+// stringTable deduplicates the strings of a v0.5 payload. Spans refer to
+// strings by their index in the table.
+type stringTable struct {
+	index map[string]uint32 // maps strings to their index in list
+	list  []string          // all strings, in order of insertion
+	buf   bytes.Buffer      // msgpack encoding of all strings in list
+}
+
+// newStringTable returns a new string table holding the empty string at
+// index 0, as expected by the agent.
+func newStringTable() *stringTable {
+	t := &stringTable{index: make(map[string]uint32)}
+	t.add("")
+	return t
+}
+
+// add returns the index of s in the table, adding it if it is not yet present.
+func (t *stringTable) add(s string) uint32 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	i := uint32(len(t.list))
+	t.index[s] = i
+	t.list = append(t.list, s)
+	t.buf.Write(msgp.AppendString(nil, s))
+	return i
+}
+
+// size returns the size in bytes of the msgpack encoded string table.
+func (t *stringTable) size() int {
+	return len(msgp.AppendArrayHeader(nil, uint32(len(t.list)))) + t.buf.Len()
+}
+
+// encodeSpanListV05 appends the v0.5 encoding of the trace t to b, adding all
+// of its strings to the table st.
+func encodeSpanListV05(b []byte, t spanList, st *stringTable) []byte {
+	b = msgp.AppendArrayHeader(b, uint32(len(t)))
+	for _, s := range t {
+		b = encodeSpanV05(b, s, st)
+	}
+	return b
+}
+
+// encodeSpanV05 appends the v0.5 encoding of s to b. The span is an array of 12
+// elements, where all strings are indexes into st:
+//
+//	[service, name, resource, trace_id, span_id, parent_id, start, duration, error, meta, metrics, type]
+//
+// The format has no room for span links, so they are JSON encoded into the
+// "_dd.span_links" meta tag, as the agent expects.
+func encodeSpanV05(b []byte, s *span, st *stringTable) []byte {
+	b = msgp.AppendArrayHeader(b, 12)
+	b = msgp.AppendUint32(b, st.add(s.Service))
+	b = msgp.AppendUint32(b, st.add(s.Name))
+	b = msgp.AppendUint32(b, st.add(s.Resource))
+	b = msgp.AppendUint64(b, s.TraceID)
+	b = msgp.AppendUint64(b, s.SpanID)
+	b = msgp.AppendUint64(b, s.ParentID)
+	b = msgp.AppendInt64(b, s.Start)
+	b = msgp.AppendInt64(b, s.Duration)
+	b = msgp.AppendInt32(b, s.Error)
+	var links []byte
+	if len(s.SpanLinks) > 0 {
+		if v, err := json.Marshal(s.SpanLinks); err == nil {
+			links = v
+		}
+	}
+	n := len(s.Meta)
+	if links != nil {
+		n++
+	}
+	b = msgp.AppendMapHeader(b, uint32(n))
+	for k, v := range s.Meta {
+		b = msgp.AppendUint32(b, st.add(k))
+		b = msgp.AppendUint32(b, st.add(v))
+	}
+	if links != nil {
+		b = msgp.AppendUint32(b, st.add(keySpanLinks))
+		b = msgp.AppendUint32(b, st.add(string(links)))
+	}
+	b = msgp.AppendMapHeader(b, uint32(len(s.Metrics)))
+	for k, v := range s.Metrics {
+		b = msgp.AppendUint32(b, st.add(k))
+		b = msgp.AppendFloat64(b, v)
+	}
+	b = msgp.AppendUint32(b, st.add(s.Type))
+	return b
+}
@@ -13,6 +13,8 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/tinylib/msgp/msgp"
 )
@@ -74,6 +76,130 @@ func TestPayloadDecode(t *testing.T) {
 	}
 }
 
+// decodeV05 decodes a v0.5 payload back into its traces.
+func decodeV05(t *testing.T, b []byte) spanLists {
+	n, b, err := msgp.ReadArrayHeaderBytes(b)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	n, b, err = msgp.ReadArrayHeaderBytes(b)
+	assert.NoError(t, err)
+	table := make([]string, n)
+	for i := range table {
+		table[i], b, err = msgp.ReadStringBytes(b)
+		assert.NoError(t, err)
+	}
+	str := func() string {
+		var i uint32
+		i, b, err = msgp.ReadUint32Bytes(b)
+		assert.NoError(t, err)
+		return table[i]
+	}
+	n, b, err = msgp.ReadArrayHeaderBytes(b)
+	assert.NoError(t, err)
+	traces := make(spanLists, n)
+	for i := range traces {
+		n, b, err = msgp.ReadArrayHeaderBytes(b)
+		assert.NoError(t, err)
+		traces[i] = make(spanList, n)
+		for j := range traces[i] {
+			n, b, err = msgp.ReadArrayHeaderBytes(b)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 12, n)
+			s := &span{Meta: map[string]string{}, Metrics: map[string]float64{}}
+			s.Service, s.Name, s.Resource = str(), str(), str()
+			s.TraceID, b, _ = msgp.ReadUint64Bytes(b)
+			s.SpanID, b, _ = msgp.ReadUint64Bytes(b)
+			s.ParentID, b, _ = msgp.ReadUint64Bytes(b)
+			s.Start, b, _ = msgp.ReadInt64Bytes(b)
+			s.Duration, b, _ = msgp.ReadInt64Bytes(b)
+			s.Error, b, _ = msgp.ReadInt32Bytes(b)
+			n, b, err = msgp.ReadMapHeaderBytes(b)
+			assert.NoError(t, err)
+			for ; n > 0; n-- {
+				k := str()
+				s.Meta[k] = str()
+			}
+			n, b, err = msgp.ReadMapHeaderBytes(b)
+			assert.NoError(t, err)
+			for ; n > 0; n-- {
+				k := str()
+				s.Metrics[k], b, err = msgp.ReadFloat64Bytes(b)
+				assert.NoError(t, err)
+			}
+			s.Type = str()
+			traces[i][j] = s
+		}
+	}
+	assert.Empty(t, b)
+	return traces
+}
+
+func TestPayloadV05(t *testing.T) {
+	assert := assert.New(t)
+	p := newPayloadV05()
+	var want spanLists
+	for i := 0; i < 20; i++ {
+		list := newSpanList(i%5 + 1)
+		list[0].Meta["http.url"] = "/users/" + strconv.Itoa(i)
+		list[0].Metrics["custom"] = float64(i)
+		want = append(want, list)
+		assert.NoError(p.push(list))
+	}
+	assert.Equal(20, p.itemCount())
+
+	got, err := io.ReadAll(p)
+	assert.NoError(err)
+	assert.Equal(p.size(), len(got))
+	traces := decodeV05(t, got)
+	assert.Len(traces, len(want))
+	for i := range want {
+		assert.Len(traces[i], len(want[i]))
+		for j, s := range want[i] {
+			g := traces[i][j]
+			assert.Equal(s.Service, g.Service)
+			assert.Equal(s.Name, g.Name)
+			assert.Equal(s.Resource, g.Resource)
+			assert.Equal(s.Type, g.Type)
+			assert.Equal(s.TraceID, g.TraceID)
+			assert.Equal(s.SpanID, g.SpanID)
+			assert.Equal(s.ParentID, g.ParentID)
+			assert.Equal(s.Start, g.Start)
+			assert.Equal(s.Duration, g.Duration)
+			assert.Equal(s.Error, g.Error)
+			assert.Equal(s.Meta, g.Meta)
+			assert.Equal(s.Metrics, g.Metrics)
+		}
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		p.reset()
+		again, err := io.ReadAll(p)
+		assert.NoError(err)
+		assert.Equal(got, again)
+	})
+
+	t.Run("dedup", func(t *testing.T) {
+		p4, p5 := newPayload(), newPayloadV05()
+		for i := 0; i < 100; i++ {
+			list := newSpanList(5)
+			p4.push(list)
+			p5.push(list)
+		}
+		assert.Less(p5.size(), p4.size())
+	})
+
+	t.Run("links", func(t *testing.T) {
+		p := newPayloadV05()
+		s := newBasicSpan("linked")
+		s.SpanLinks = []ddtrace.SpanLink{{TraceID: 1, SpanID: 2}}
+		assert.NoError(p.push(spanList{s}))
+		got, err := io.ReadAll(p)
+		assert.NoError(err)
+		traces := decodeV05(t, got)
+		assert.JSONEq(`[{"trace_id":1,"trace_id_high":0,"span_id":2,"attributes":null,"tracestate":"","flags":0}]`, traces[0][0].Meta[keySpanLinks])
+	})
+}
+
 func BenchmarkPayloadThroughput(b *testing.B) {
 	b.Run("10K", benchmarkPayloadThroughput(1))
 	b.Run("100K", benchmarkPayloadThroughput(10))
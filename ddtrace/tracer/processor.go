@@ -0,0 +1,179 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+)
+
+// SpanProcessor allows inspecting and modifying spans as they go through the tracer, for
+// example to scrub sensitive data, rename resources or drop noisy spans. Processors are
+// registered using WithSpanProcessor and run in registration order.
+//
+// Processors run before the sampling decisions are made and before client-side stats are
+// computed, so both see the processed values. OnStart and OnFinish are called from the
+// goroutines starting and finishing spans, and may thus be called concurrently.
+type SpanProcessor interface {
+	// OnStart is called when a span is started, once its initial tags have been set.
+	OnStart(s ReadWriteSpan)
+
+	// OnFinish is called when a span is finished, once its duration has been set.
+	OnFinish(s ReadWriteSpan)
+
+	// OnChunk is called with a chunk of finished spans belonging to the same trace,
+	// before it is sent. It returns the spans which should be kept; the other ones
+	// are dropped. The chunk may be a complete local trace, or only a portion of it
+	// when partial flushing is enabled. Returning spans which were not part of the
+	// chunk has no effect.
+	OnChunk(spans []ReadWriteSpan) []ReadWriteSpan
+}
+
+// ReadWriteSpan is a mutable view of a span, as passed to a SpanProcessor. It must not be
+// retained or used once the processor method it was passed to has returned.
+type ReadWriteSpan interface {
+	ReadOnlySpan
+
+	// SetOperationName sets the operation name of the span.
+	SetOperationName(name string)
+
+	// SetServiceName sets the service name of the span.
+	SetServiceName(service string)
+
+	// SetResourceName sets the resource name of the span.
+	SetResourceName(resource string)
+
+	// SetTag sets a tag on the span, the same way Span.SetTag does.
+	SetTag(key string, value interface{})
+
+	// DeleteTag removes the string or numeric tag at key from the span.
+	DeleteTag(key string)
+}
+
+// spanView implements ReadWriteSpan on top of a span. Unlike readOnlySpan, it does not
+// lock the span, as processors are always called with the span locked or finished.
+type spanView struct {
+	s *span
+}
+
+var _ ReadWriteSpan = (*spanView)(nil)
+
+func (v spanView) OperationName() string { return v.s.Name }
+
+func (v spanView) ServiceName() string { return v.s.Service }
+
+func (v spanView) ResourceName() string { return v.s.Resource }
+
+func (v spanView) SpanType() string { return v.s.Type }
+
+func (v spanView) TraceID() uint64 { return v.s.TraceID }
+
+func (v spanView) TraceID128() string { return v.s.context.TraceID128() }
+
+func (v spanView) SpanID() uint64 { return v.s.SpanID }
+
+func (v spanView) ParentID() uint64 { return v.s.ParentID }
+
+func (v spanView) StartTime() time.Time { return time.Unix(0, v.s.Start) }
+
+func (v spanView) Duration() time.Duration { return time.Duration(v.s.Duration) }
+
+func (v spanView) IsError() bool { return v.s.Error != 0 }
+
+func (v spanView) Tag(k string) interface{} {
+	if val, ok := v.s.Meta[k]; ok {
+		return val
+	}
+	if val, ok := v.s.Metrics[k]; ok {
+		return val
+	}
+	return nil
+}
+
+func (v spanView) Tags() map[string]interface{} {
+	tags := make(map[string]interface{}, len(v.s.Meta)+len(v.s.Metrics))
+	for k, val := range v.s.Meta {
+		tags[k] = val
+	}
+	for k, val := range v.s.Metrics {
+		tags[k] = val
+	}
+	return tags
+}
+
+func (v spanView) Links() []ddtrace.SpanLink {
+	return append([]ddtrace.SpanLink(nil), v.s.SpanLinks...)
+}
+
+func (v spanView) Events() []ddtrace.SpanEvent {
+	return append([]ddtrace.SpanEvent(nil), v.s.SpanEvents...)
+}
+
+func (v spanView) SamplingPriority() (p int, ok bool) {
+	return v.s.context.SamplingPriority()
+}
+
+func (v spanView) SetOperationName(name string) { v.s.setMeta(ext.SpanName, name) }
+
+func (v spanView) SetServiceName(service string) { v.s.setMeta(ext.ServiceName, service) }
+
+func (v spanView) SetResourceName(resource string) { v.s.setMeta(ext.ResourceName, resource) }
+
+func (v spanView) SetTag(key string, value interface{}) { v.s.setTag(key, value) }
+
+func (v spanView) DeleteTag(key string) {
+	delete(v.s.Meta, key)
+	delete(v.s.Metrics, key)
+}
+
+// processStart runs the OnStart method of the span processors on s. It must be called
+// with the span locked.
+func (t *tracer) processStart(s *span) {
+	for _, p := range t.config.spanProcessors {
+		p.OnStart(spanView{s})
+	}
+}
+
+// processFinish runs the OnFinish method of the span processors on s. It must be called
+// with the span locked.
+func (t *tracer) processFinish(s *span) {
+	for _, p := range t.config.spanProcessors {
+		p.OnFinish(spanView{s})
+	}
+}
+
+// processChunk runs the OnChunk method of the span processors on the finished spans,
+// returning the ones which were kept, in their original order.
+func (t *tracer) processChunk(spans []*span) []*span {
+	if len(t.config.spanProcessors) == 0 || len(spans) == 0 {
+		return spans
+	}
+	views := make([]ReadWriteSpan, len(spans))
+	for i, s := range spans {
+		views[i] = spanView{s}
+	}
+	for _, p := range t.config.spanProcessors {
+		views = p.OnChunk(views)
+		if len(views) == 0 {
+			return nil
+		}
+	}
+	keep := make(map[*span]struct{}, len(views))
+	for _, v := range views {
+		if sv, ok := v.(spanView); ok {
+			keep[sv.s] = struct{}{}
+		}
+	}
+	kept := make([]*span, 0, len(keep))
+	for _, s := range spans {
+		if _, ok := keep[s]; ok {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
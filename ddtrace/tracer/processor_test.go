@@ -0,0 +1,219 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// funcProcessor is a SpanProcessor calling the given functions, when set.
+type funcProcessor struct {
+	onStart  func(s ReadWriteSpan)
+	onFinish func(s ReadWriteSpan)
+	onChunk  func(spans []ReadWriteSpan) []ReadWriteSpan
+}
+
+func (p funcProcessor) OnStart(s ReadWriteSpan) {
+	if p.onStart != nil {
+		p.onStart(s)
+	}
+}
+
+func (p funcProcessor) OnFinish(s ReadWriteSpan) {
+	if p.onFinish != nil {
+		p.onFinish(s)
+	}
+}
+
+func (p funcProcessor) OnChunk(spans []ReadWriteSpan) []ReadWriteSpan {
+	if p.onChunk != nil {
+		return p.onChunk(spans)
+	}
+	return spans
+}
+
+func TestSpanProcessor(t *testing.T) {
+	t.Run("mutate", func(t *testing.T) {
+		assert := assert.New(t)
+		var order []string
+		first := funcProcessor{
+			onStart: func(s ReadWriteSpan) {
+				order = append(order, "first")
+				if strings.HasPrefix(s.ResourceName(), "GET /users/") {
+					s.SetResourceName("GET /users/?")
+				}
+				s.SetTag("processed", true)
+			},
+			onFinish: func(s ReadWriteSpan) {
+				assert.NotZero(s.Duration())
+				s.DeleteTag("user.email")
+				s.SetServiceName("scrubbed-service")
+			},
+		}
+		second := funcProcessor{
+			onStart: func(s ReadWriteSpan) {
+				order = append(order, "second")
+				assert.Equal("GET /users/?", s.ResourceName())
+			},
+		}
+		tracer, transport, flush, stop := startTestTracer(t, WithSpanProcessor(first), WithSpanProcessor(nil), WithSpanProcessor(second))
+		defer stop()
+
+		span := tracer.StartSpan("http.request", ResourceName("GET /users/42"))
+		span.SetTag("user.email", "alice@example.com")
+		span.SetTag("user.id", 42)
+		span.Finish()
+		flush(1)
+
+		assert.Equal([]string{"first", "second"}, order)
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 1)
+		s := traces[0][0]
+		assert.Equal("GET /users/?", s.Resource)
+		assert.Equal("scrubbed-service", s.Service)
+		assert.Equal("true", s.Meta["processed"])
+		assert.NotContains(s.Meta, "user.email")
+		assert.Equal(42., s.Metrics["user.id"])
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		assert := assert.New(t)
+		dropHealth := funcProcessor{
+			onChunk: func(spans []ReadWriteSpan) []ReadWriteSpan {
+				kept := spans[:0]
+				for _, s := range spans {
+					if s.OperationName() != "health.check" {
+						kept = append(kept, s)
+					}
+				}
+				return kept
+			},
+		}
+		tracer, transport, flush, stop := startTestTracer(t, WithSpanProcessor(dropHealth))
+		defer stop()
+		tracer.stats.Stop()
+		tracer.config.agent.Stats = true
+		tracer.config.statsComputationEnabled = true
+
+		// a trace made only of dropped spans is not sent
+		tracer.StartSpan("health.check").Finish()
+
+		root := tracer.StartSpan("health.check")
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()), Measured())
+		child.Finish()
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 1)
+		s := traces[0][0]
+		assert.Equal("db.query", s.Name)
+		// the trace-level tags were moved to the first kept span
+		assert.Equal(float64(ext.PriorityAutoKeep), s.Metrics[keySamplingPriority])
+		assert.Equal("-1", s.Meta[keyDecisionMaker])
+
+		// stats are only computed for the kept spans
+		require.Len(t, tracer.stats.In, 1)
+		assert.Equal("db.query", (<-tracer.stats.In).key.Name)
+	})
+
+	t.Run("finish-tags", func(t *testing.T) {
+		assert := assert.New(t)
+		p := funcProcessor{
+			onFinish: func(s ReadWriteSpan) {
+				s.SetServiceName("users-db")
+				s.SetTag(ext.PeerHostname, "db.example.com")
+			},
+		}
+		tracer, _, _, stop := startTestTracer(t, WithService("web"), WithPeerServiceDefaults(true), WithSpanProcessor(p))
+		defer stop()
+
+		span := tracer.StartSpan("db.query", Tag(ext.SpanKind, ext.SpanKindClient)).(*span)
+		span.Finish()
+
+		// the tags derived from the service and tags set by processors are up to date
+		assert.Equal("web", span.Meta[keyBaseService])
+		assert.Equal("db.example.com", span.Meta[ext.PeerService])
+	})
+
+	t.Run("chunk-trace-access", func(t *testing.T) {
+		assert := assert.New(t)
+		var (
+			priority int
+			ok       bool
+		)
+		p := funcProcessor{
+			onChunk: func(spans []ReadWriteSpan) []ReadWriteSpan {
+				// accessing the trace from a processor must not deadlock
+				priority, ok = spans[0].SamplingPriority()
+				spans[0].SetTag(ext.ManualKeep, true)
+				return spans
+			},
+		}
+		tracer, transport, flush, stop := startTestTracer(t, WithSpanProcessor(p))
+		defer stop()
+
+		tracer.StartSpan("web.request").Finish()
+		flush(1)
+
+		assert.True(ok)
+		assert.Equal(ext.PriorityAutoKeep, priority)
+		assert.Len(transport.Traces(), 1)
+	})
+
+	t.Run("sampling-rules", func(t *testing.T) {
+		assert := assert.New(t)
+		p := funcProcessor{
+			onFinish: func(s ReadWriteSpan) {
+				if strings.HasPrefix(s.ResourceName(), "GET /users/") {
+					s.SetResourceName("GET /users/?")
+				}
+			},
+		}
+		rule := SamplingRule{Resource: regexp.MustCompile("^GET /users/\\?$"), Rate: 0}
+		tracer, _, _, stop := startTestTracer(t, WithSamplingRules([]SamplingRule{rule}), WithSpanProcessor(p))
+		defer stop()
+
+		span := tracer.StartSpan("http.request", ResourceName("GET /users/42")).(*span)
+		span.Finish()
+
+		// the rule matches the resource set by the processor
+		assert.Equal("GET /users/?", span.Resource)
+		assert.Equal(float64(ext.PriorityUserReject), span.Metrics[keySamplingPriority])
+		assert.Equal(0., span.Metrics[keyRulesSamplerAppliedRate])
+	})
+
+	t.Run("full", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithSpanProcessor(funcProcessor{}))
+		defer stop()
+		defer func(old int) { traceMaxSize = old }(traceMaxSize)
+		traceMaxSize = 1
+		tracer.stats.Stop()
+		tracer.config.agent.Stats = true
+		tracer.config.statsComputationEnabled = true
+
+		// the child doesn't fit in the trace buffer, which is no longer flushed
+		root := tracer.StartSpan("web.request")
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()), Measured())
+		child.Finish()
+		root.Finish()
+
+		// stats are still computed for both spans
+		require.Len(t, tracer.stats.In, 2)
+		assert.Equal("db.query", (<-tracer.stats.In).key.Name)
+		assert.Equal("web.request", (<-tracer.stats.In).key.Name)
+	})
+}
@@ -39,6 +39,22 @@ type TextMapReader interface {
 	ForeachKey(handler func(key, val string) error) error
 }
 
+// HeadersCarrier is implemented by carriers which store headers having binary values,
+// such as Kafka record headers, AMQP tables or gRPC binary metadata. HeadersCarriers may
+// be used with Inject and Extract: header values are then written and read as UTF-8 text,
+// unless binary propagation is enabled (see WithBinaryPropagation), in which case the span
+// context is stored in a single header using a compact binary encoding.
+type HeadersCarrier interface {
+	// SetHeader sets the header key to val, replacing any existing header with the same key.
+	SetHeader(key string, val []byte)
+
+	// ForeachHeader iterates over all headers that exist in the underlying
+	// carrier. It takes a callback function which will be called
+	// using all headers as arguments. ForeachHeader will return
+	// the first error returned by the handler.
+	ForeachHeader(handler func(key string, val []byte) error) error
+}
+
 var (
 	// ErrInvalidCarrier is returned when the carrier provided to the propagator
 	// does not implement the correct interfaces.
@@ -7,6 +7,7 @@ package tracer
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
@@ -28,9 +29,85 @@ type target struct {
 }
 
 type libConfig struct {
-	SamplingRate *float64    `json:"tracing_sampling_rate,omitempty"`
-	HeaderTags   *headerTags `json:"tracing_header_tags,omitempty"`
-	Tags         *tags       `json:"tracing_tags,omitempty"`
+	SamplingRate      *float64          `json:"tracing_sampling_rate,omitempty"`
+	SamplingRules     *[]rcSamplingRule `json:"tracing_sampling_rules,omitempty"`
+	SpanSamplingRules *json.RawMessage  `json:"span_sampling_rules,omitempty"`
+	HeaderTags        *headerTags       `json:"tracing_header_tags,omitempty"`
+	Tags              *tags             `json:"tracing_tags,omitempty"`
+}
+
+// rcSamplingRule is a trace sampling rule sent through remote configuration.
+type rcSamplingRule struct {
+	Service    string  `json:"service"`
+	Name       string  `json:"name"`
+	Resource   string  `json:"resource"`
+	Tags       []rcTag `json:"tags"`
+	SampleRate float64 `json:"sample_rate"`
+	Provenance string  `json:"provenance"`
+}
+
+// rcTag holds the glob pattern which the value of a span tag must match.
+type rcTag struct {
+	Key       string `json:"key"`
+	ValueGlob string `json:"value_glob"`
+}
+
+// convertRemoteSamplingRules returns the trace sampling rules equivalent to the remote
+// ones, or nil if rules is nil.
+func convertRemoteSamplingRules(rules *[]rcSamplingRule) (*[]SamplingRule, error) {
+	if rules == nil {
+		return nil, nil
+	}
+	// an empty list of rules overrides the startup rules
+	converted := make([]SamplingRule, 0, len(*rules))
+	for i, r := range *rules {
+		if r.SampleRate < 0.0 || r.SampleRate > 1.0 {
+			return nil, fmt.Errorf("at index %d: rate %v is out of [0.0, 1.0] range", i, r.SampleRate)
+		}
+		rule := SamplingRule{Rate: r.SampleRate}
+		switch r.Provenance {
+		case provenanceCustomer.String():
+			rule.provenance = provenanceCustomer
+		case provenanceDynamic.String():
+			rule.provenance = provenanceDynamic
+		default:
+			return nil, fmt.Errorf("at index %d: unknown provenance %q", i, r.Provenance)
+		}
+		if r.Service != "" {
+			rule.Service = globMatch(r.Service)
+		}
+		if r.Name != "" {
+			rule.Name = globMatch(r.Name)
+		}
+		if r.Resource != "" {
+			rule.Resource = globMatch(r.Resource)
+		}
+		if len(r.Tags) > 0 {
+			rule.Tags = make(map[string]string, len(r.Tags))
+			for _, t := range r.Tags {
+				rule.Tags[t.Key] = t.ValueGlob
+			}
+		}
+		converted = append(converted, rule)
+	}
+	return &converted, nil
+}
+
+// spanSamplingRules parses the single span sampling rules found in raw, which uses the
+// same format as DD_SPAN_SAMPLING_RULES. It returns nil if raw is nil.
+func spanSamplingRules(raw *json.RawMessage) (*[]SamplingRule, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	rules, err := unmarshalSamplingRules(*raw, SamplingRuleSpan)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		// an empty list of rules overrides the startup rules
+		rules = []SamplingRule{}
+	}
+	return &rules, nil
 }
 
 type headerTags []headerTag
@@ -102,6 +179,14 @@ func (t *tracer) onRemoteConfigUpdate(u remoteconfig.ProductUpdate) map[string]s
 		if updated {
 			telemConfigs = append(telemConfigs, t.config.traceSampleRate.toTelemetry())
 		}
+		updated = t.config.traceSamplingRules.reset()
+		if updated {
+			telemConfigs = append(telemConfigs, t.config.traceSamplingRules.toTelemetry())
+		}
+		updated = t.config.spanSamplingRules.reset()
+		if updated {
+			telemConfigs = append(telemConfigs, t.config.spanSamplingRules.toTelemetry())
+		}
 		updated = t.config.headerAsTags.reset()
 		if updated {
 			telemConfigs = append(telemConfigs, t.config.headerAsTags.toTelemetry())
@@ -137,11 +222,31 @@ func (t *tracer) onRemoteConfigUpdate(u remoteconfig.ProductUpdate) map[string]s
 			statuses[path] = state.ApplyStatus{State: state.ApplyStateError, Error: "env mismatch"}
 			continue
 		}
+		rules, err := convertRemoteSamplingRules(c.LibConfig.SamplingRules)
+		if err != nil {
+			log.Debug("Invalid trace sampling rules for %s: %v. Configuration won't be applied.", path, err)
+			statuses[path] = state.ApplyStatus{State: state.ApplyStateError, Error: err.Error()}
+			continue
+		}
+		spanRules, err := spanSamplingRules(c.LibConfig.SpanSamplingRules)
+		if err != nil {
+			log.Debug("Invalid span sampling rules for %s: %v. Configuration won't be applied.", path, err)
+			statuses[path] = state.ApplyStatus{State: state.ApplyStateError, Error: err.Error()}
+			continue
+		}
 		statuses[path] = state.ApplyStatus{State: state.ApplyStateAcknowledged}
 		updated := t.config.traceSampleRate.handleRC(c.LibConfig.SamplingRate)
 		if updated {
 			telemConfigs = append(telemConfigs, t.config.traceSampleRate.toTelemetry())
 		}
+		updated = t.config.traceSamplingRules.handleRC(rules)
+		if updated {
+			telemConfigs = append(telemConfigs, t.config.traceSamplingRules.toTelemetry())
+		}
+		updated = t.config.spanSamplingRules.handleRC(spanRules)
+		if updated {
+			telemConfigs = append(telemConfigs, t.config.spanSamplingRules.toTelemetry())
+		}
 		updated = t.config.headerAsTags.handleRC(c.LibConfig.HeaderTags.toSlice())
 		if updated {
 			telemConfigs = append(telemConfigs, t.config.headerAsTags.toTelemetry())
@@ -169,6 +274,7 @@ func (t *tracer) startRemoteConfig(rcConfig remoteconfig.ClientConfig) error {
 		state.ProductAPMTracing,
 		t.onRemoteConfigUpdate,
 		remoteconfig.APMTracingSampleRate,
+		remoteconfig.APMTracingSampleRules,
 		remoteconfig.APMTracingHTTPHeaderTags,
 		remoteconfig.APMTracingCustomTags,
 	)
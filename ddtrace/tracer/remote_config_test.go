@@ -11,6 +11,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry/telemetrytest"
 
@@ -288,6 +289,132 @@ func TestOnRemoteConfigUpdate(t *testing.T) {
 		telemetryClient.AssertCalled(t, "ConfigChange", []telemetry.Configuration{{Name: "trace_tags", Value: "key0:val0,key1:val1,key2:val2," + runtimeIDTag, Origin: ""}})
 	})
 
+	t.Run("RC trace sampling rules are applied and can be reverted", func(t *testing.T) {
+		telemetryClient := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(telemetryClient)()
+
+		t.Setenv("DD_TRACE_SAMPLING_RULES", `[{"service": "my-service", "name": "web.request", "sample_rate": 0.1}]`)
+		tracer, _, _, stop := startTestTracer(t, WithService("my-service"), WithEnv("my-env"))
+		defer stop()
+
+		// Apply RC. Assert the RC rules replace the ones from the environment.
+		input := remoteconfig.ProductUpdate{
+			"path": []byte(`{"lib_config": {"tracing_sampling_rules": [{"resource": "GET /users", "sample_rate": 0.3, "provenance": "customer"}, {"service": "my-*", "tags": [{"key": "tier", "value_glob": "g*"}], "sample_rate": 0.4, "provenance": "dynamic"}]}, "service_target": {"service": "my-service", "env": "my-env"}}`),
+		}
+		applyStatus := tracer.onRemoteConfigUpdate(input)
+		require.Equal(t, state.ApplyStateAcknowledged, applyStatus["path"].State)
+		s := tracer.StartSpan("web.request", ResourceName("GET /users")).(*span)
+		s.Finish()
+		require.Equal(t, 0.3, s.Metrics[keyRulesSamplerAppliedRate])
+		require.Equal(t, samplernames.RemoteUserRate, s.context.trace.sampler)
+		s = tracer.StartSpan("web.request", Tag("tier", "gold")).(*span)
+		s.Finish()
+		require.Equal(t, 0.4, s.Metrics[keyRulesSamplerAppliedRate])
+		require.Equal(t, samplernames.RemoteRate, s.context.trace.sampler)
+		s = tracer.StartSpan("web.request").(*span)
+		s.Finish()
+		require.NotContains(t, s.Metrics, keyRulesSamplerAppliedRate)
+
+		// Applying the same rules again is not reported as a change.
+		applyStatus = tracer.onRemoteConfigUpdate(input)
+		require.Equal(t, state.ApplyStateAcknowledged, applyStatus["path"].State)
+
+		// Telemetry
+		telemetryClient.AssertNumberOfCalls(t, "ConfigChange", 1)
+		telemetryClient.AssertCalled(t, "ConfigChange", []telemetry.Configuration{{
+			Name:   "trace_sample_rules",
+			Value:  `[{"service":"","name":"","resource":"^GET /users$","sample_rate":0.3,"type":"trace(0)","provenance":"customer"},{"service":"^my-.*$","name":"","tags":{"tier":"g*"},"sample_rate":0.4,"type":"trace(0)","provenance":"dynamic"}]`,
+			Origin: "remote_config",
+		}})
+
+		// Unset RC. Assert the rules from the environment are applied again.
+		input = remoteconfig.ProductUpdate{"path": []byte(`{"lib_config": {}, "service_target": {"service": "my-service", "env": "my-env"}}`)}
+		applyStatus = tracer.onRemoteConfigUpdate(input)
+		require.Equal(t, state.ApplyStateAcknowledged, applyStatus["path"].State)
+		s = tracer.StartSpan("web.request", ResourceName("GET /users")).(*span)
+		s.Finish()
+		require.Equal(t, 0.1, s.Metrics[keyRulesSamplerAppliedRate])
+
+		// Telemetry
+		telemetryClient.AssertNumberOfCalls(t, "ConfigChange", 2)
+		telemetryClient.AssertCalled(t, "ConfigChange", []telemetry.Configuration{{
+			Name:   "trace_sample_rules",
+			Value:  `[{"service":"my-service","name":"web.request","sample_rate":0.1,"type":"trace(0)"}]`,
+			Origin: "env_var",
+		}})
+	})
+
+	t.Run("RC span sampling rules are applied and can be reverted", func(t *testing.T) {
+		telemetryClient := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(telemetryClient)()
+
+		tracer, _, _, stop := startTestTracer(t, WithService("my-service"), WithEnv("my-env"),
+			WithSamplingRules([]SamplingRule{SpanNameServiceRule("db.query", "my-service", 1.0)}))
+		defer stop()
+		require.True(t, tracer.rulesSampling.HasSpanRules())
+
+		input := remoteconfig.ProductUpdate{
+			"path": []byte(`{"lib_config": {"span_sampling_rules": [{"name": "web.*", "sample_rate": 1.0, "max_per_second": 10}]}, "service_target": {"service": "my-service", "env": "my-env"}}`),
+		}
+		applyStatus := tracer.onRemoteConfigUpdate(input)
+		require.Equal(t, state.ApplyStateAcknowledged, applyStatus["path"].State)
+		s := tracer.newRootSpan("web.request", "my-service", "")
+		s.finished = true
+		require.True(t, tracer.rulesSampling.SampleSpan(s))
+		require.Equal(t, 10.0, s.Metrics[keySingleSpanSamplingMPS])
+		s = tracer.newRootSpan("db.query", "my-service", "")
+		s.finished = true
+		require.False(t, tracer.rulesSampling.SampleSpan(s))
+
+		telemetryClient.AssertNumberOfCalls(t, "ConfigChange", 1)
+		telemetryClient.AssertCalled(t, "ConfigChange", []telemetry.Configuration{{
+			Name:   "span_sample_rules",
+			Value:  `[{"service":"^.*$","name":"^web\\..*$","sample_rate":1,"type":"span(1)","max_per_second":10}]`,
+			Origin: "remote_config",
+		}})
+
+		// An empty list of rules disables single span sampling.
+		input = remoteconfig.ProductUpdate{
+			"path": []byte(`{"lib_config": {"span_sampling_rules": []}, "service_target": {"service": "my-service", "env": "my-env"}}`),
+		}
+		applyStatus = tracer.onRemoteConfigUpdate(input)
+		require.Equal(t, state.ApplyStateAcknowledged, applyStatus["path"].State)
+		require.False(t, tracer.rulesSampling.HasSpanRules())
+
+		// Remove RC. Assert the rules set in code are applied again.
+		input = remoteconfig.ProductUpdate{"path": nil}
+		applyStatus = tracer.onRemoteConfigUpdate(input)
+		require.Equal(t, state.ApplyStateAcknowledged, applyStatus["path"].State)
+		s = tracer.newRootSpan("db.query", "my-service", "")
+		s.finished = true
+		require.True(t, tracer.rulesSampling.SampleSpan(s))
+
+		telemetryClient.AssertNumberOfCalls(t, "ConfigChange", 3)
+		telemetryClient.AssertCalled(t, "ConfigChange", []telemetry.Configuration{{
+			Name:   "span_sample_rules",
+			Value:  `[{"service":"^my-service$","name":"db.query","sample_rate":1,"type":"span(1)"}]`,
+			Origin: "code",
+		}})
+	})
+
+	t.Run("RC invalid sampling rules are rejected", func(t *testing.T) {
+		telemetryClient := new(telemetrytest.MockClient)
+		defer telemetry.MockGlobalClient(telemetryClient)()
+
+		tracer, _, _, stop := startTestTracer(t, WithService("my-service"), WithEnv("my-env"))
+		defer stop()
+
+		input := remoteconfig.ProductUpdate{
+			"path": []byte(`{"lib_config": {"tracing_sampling_rate": 0.5, "tracing_sampling_rules": [{"service": "my-service", "sample_rate": 4.2, "provenance": "customer"}]}, "service_target": {"service": "my-service", "env": "my-env"}}`),
+		}
+		applyStatus := tracer.onRemoteConfigUpdate(input)
+		require.Equal(t, state.ApplyStateError, applyStatus["path"].State)
+		s := tracer.StartSpan("web.request").(*span)
+		s.Finish()
+		require.NotContains(t, s.Metrics, keyRulesSamplerAppliedRate)
+		telemetryClient.AssertNumberOfCalls(t, "ConfigChange", 0)
+	})
+
 	t.Run("Deleted config", func(t *testing.T) {
 		defer globalconfig.ClearHeaderTags()
 		telemetryClient := new(telemetrytest.MockClient)
@@ -354,6 +481,12 @@ func TestStartRemoteConfig(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, found)
 
+	// the capability bit is defined by the remote config protocol
+	require.EqualValues(t, 29, remoteconfig.APMTracingSampleRules)
+	found, err = remoteconfig.HasCapability(remoteconfig.APMTracingSampleRules)
+	require.NoError(t, err)
+	require.True(t, found)
+
 	found, err = remoteconfig.HasCapability(remoteconfig.APMTracingHTTPHeaderTags)
 	require.NoError(t, err)
 	require.True(t, found)
@@ -53,7 +53,7 @@ func (r *rulesSampler) HasSpanRules() bool { return r.spans.enabled() }
 func (r *rulesSampler) TraceRateLimit() (float64, bool) { return r.traces.limit() }
 
 // SamplingRule is used for applying sampling rates to spans that match
-// the service name, operation name or both.
+// the service name, operation name, resource name, tags or any combination of them.
 // For basic usage, consider using the helper functions ServiceRule, NameRule, etc.
 type SamplingRule struct {
 	// Service specifies the regex pattern that a span service name must match.
@@ -62,6 +62,15 @@ type SamplingRule struct {
 	// Name specifies the regex pattern that a span operation name must match.
 	Name *regexp.Regexp
 
+	// Resource specifies the regex pattern that a span resource name must match.
+	Resource *regexp.Regexp
+
+	// Tags specifies glob patterns, in which only '?' and '*' are special, that
+	// the values of the given span tags must match. A span which does not have
+	// one of the tags does not match. Numeric tags are matched using their
+	// decimal representation, e.g. "5??" matches any 5xx http.status_code.
+	Tags map[string]string
+
 	// Rate specifies the sampling rate that should be applied to spans that match
 	// service and/or name of the rule.
 	Rate float64
@@ -74,6 +83,48 @@ type SamplingRule struct {
 	exactService string
 	exactName    string
 	limiter      *rateLimiter
+	tags         map[string]*regexp.Regexp // compiled Tags
+	provenance   provenance
+}
+
+// provenance specifies where a sampling rule comes from.
+type provenance int32
+
+const (
+	// provenanceLocal rules are set using the environment or in code.
+	provenanceLocal provenance = iota
+	// provenanceCustomer rules are defined by the user in Datadog and sent through
+	// remote configuration.
+	provenanceCustomer
+	// provenanceDynamic rules are computed by Datadog and sent through remote
+	// configuration.
+	provenanceDynamic
+)
+
+// String returns the name of the provenance as used by remote configuration,
+// or an empty string for local rules.
+func (p provenance) String() string {
+	switch p {
+	case provenanceCustomer:
+		return "customer"
+	case provenanceDynamic:
+		return "dynamic"
+	default:
+		return ""
+	}
+}
+
+// mechanism returns the sampling mechanism of the trace sampling decisions made
+// by the rule.
+func (sr *SamplingRule) mechanism() samplernames.SamplerName {
+	switch sr.provenance {
+	case provenanceCustomer:
+		return samplernames.RemoteUserRate
+	case provenanceDynamic:
+		return samplernames.RemoteRate
+	default:
+		return samplernames.RuleRate
+	}
 }
 
 // match returns true when the span's details match all the expected values in the rule.
@@ -88,9 +139,60 @@ func (sr *SamplingRule) match(s *span) bool {
 	} else if sr.exactName != "" && sr.exactName != s.Name {
 		return false
 	}
+	if sr.Resource != nil && !sr.Resource.MatchString(s.Resource) {
+		return false
+	}
+	for k, re := range sr.tags {
+		if v, ok := s.Meta[k]; ok {
+			if !re.MatchString(v) {
+				return false
+			}
+		} else if v, ok := s.Metrics[k]; ok {
+			if !re.MatchString(strconv.FormatFloat(v, 'f', -1, 64)) {
+				return false
+			}
+		} else {
+			return false
+		}
+	}
+	return true
+}
+
+// equalSamplingRules reports whether x and y hold the same rules, in the same order.
+func equalSamplingRules(x, y []SamplingRule) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		a, errA := x[i].MarshalJSON()
+		b, errB := y[i].MarshalJSON()
+		if errA != nil || errB != nil || string(a) != string(b) {
+			return false
+		}
+	}
 	return true
 }
 
+// compileSamplingRules returns a copy of rules in which the tag patterns are compiled,
+// ready to be matched against spans.
+func compileSamplingRules(rules []SamplingRule) []SamplingRule {
+	if len(rules) == 0 {
+		return rules
+	}
+	compiled := make([]SamplingRule, len(rules))
+	copy(compiled, rules)
+	for i, r := range compiled {
+		if len(r.Tags) == 0 {
+			continue
+		}
+		compiled[i].tags = make(map[string]*regexp.Regexp, len(r.Tags))
+		for k, v := range r.Tags {
+			compiled[i].tags[k] = globMatch(v)
+		}
+	}
+	return compiled
+}
+
 // SamplingRuleType represents a type of sampling rule spans are matched against.
 type SamplingRuleType int
 
@@ -185,7 +287,7 @@ func SpanNameServiceMPSRule(name, service string, rate, limit float64) SamplingR
 }
 
 // traceRulesSampler allows a user-defined list of rules to apply to traces.
-// These rules can match based on the span's Service, Name or both.
+// These rules can match based on the span's Service, Name, Resource and Tags.
 // When making a sampling decision, the rules are checked in order until
 // a match is found.
 // If a match is found, the rate from that rule is used.
@@ -209,7 +311,7 @@ type traceRulesSampler struct {
 // Invalid rules or environment variable values are tolerated, by logging warnings and then ignoring them.
 func newTraceRulesSampler(rules []SamplingRule, traceSampleRate float64) *traceRulesSampler {
 	return &traceRulesSampler{
-		rules:      rules,
+		rules:      compileSamplingRules(rules),
 		globalRate: traceSampleRate,
 		limiter:    newRateLimiter(),
 	}
@@ -262,6 +364,19 @@ func (rs *traceRulesSampler) setGlobalSampleRate(rate float64) bool {
 	return true
 }
 
+// setRules replaces the sampling rules with the given ones.
+// Returns whether the rules were changed or not.
+func (rs *traceRulesSampler) setRules(rules []SamplingRule) bool {
+	compiled := compileSamplingRules(rules)
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	if equalSamplingRules(rs.rules, compiled) {
+		return false
+	}
+	rs.rules = compiled
+	return true
+}
+
 // apply uses the sampling rules to determine the sampling rate for the
 // provided span. If the rules don't match, and a default rate hasn't been
 // set using DD_TRACE_SAMPLE_RATE, then it returns false and the span is not
@@ -272,41 +387,80 @@ func (rs *traceRulesSampler) apply(span *span) bool {
 		return false
 	}
 
-	var matched bool
 	rs.m.RLock()
 	rate := rs.globalRate
+	rules := rs.rules
 	rs.m.RUnlock()
-	for _, rule := range rs.rules {
-		if rule.match(span) {
-			matched = true
-			rate = rule.Rate
+	var matched *SamplingRule
+	mechanism := samplernames.RuleRate
+	for i := range rules {
+		if rules[i].match(span) {
+			matched = &rules[i]
+			rate = matched.Rate
+			mechanism = matched.mechanism()
 			break
 		}
 	}
-	if !matched && math.IsNaN(rate) {
+	if matched == nil && math.IsNaN(rate) {
 		// no matching rule or global rate, so we want to fall back
 		// to priority sampling
 		return false
 	}
 
-	rs.applyRule(span, rate, time.Now())
+	span.Lock()
+	defer span.Unlock()
+	span.samplingRule = matched
+	rs.applyRuleLocked(span, rate, mechanism, time.Now())
 	return true
 }
 
+// applyFinished matches the sampling rules again against the root span when it
+// finishes, so that its resource and the tags set after it started are taken into
+// account. The sampling priority is only changed when the first matching rule is
+// not the one which already set it. It returns whether the priority was changed.
+// The span must be locked.
+func (rs *traceRulesSampler) applyFinished(span *span) bool {
+	rs.m.RLock()
+	rules := rs.rules
+	rs.m.RUnlock()
+	for i := range rules {
+		if !rules[i].match(span) {
+			continue
+		}
+		if span.samplingRule == &rules[i] {
+			return false
+		}
+		// the decision maker is set again by the rule
+		span.context.trace.unsetPropagatingTag(keyDecisionMaker)
+		span.samplingRule = &rules[i]
+		rs.applyRuleLocked(span, rules[i].Rate, rules[i].mechanism(), time.Now())
+		return true
+	}
+	return false
+}
+
 func (rs *traceRulesSampler) applyRule(span *span, rate float64, now time.Time) {
-	span.SetTag(keyRulesSamplerAppliedRate, rate)
+	span.Lock()
+	defer span.Unlock()
+	rs.applyRuleLocked(span, rate, samplernames.RuleRate, now)
+}
+
+// applyRuleLocked samples the span using the given rate and the rate limiter,
+// recording mechanism as the sampling mechanism. The span must be locked.
+func (rs *traceRulesSampler) applyRuleLocked(span *span, rate float64, mechanism samplernames.SamplerName, now time.Time) {
+	span.setMetric(keyRulesSamplerAppliedRate, rate)
 	if !sampledByRate(span.TraceID, rate) {
-		span.setSamplingPriority(ext.PriorityUserReject, samplernames.RuleRate)
+		span.setSamplingPriorityLocked(ext.PriorityUserReject, mechanism)
 		return
 	}
 
 	sampled, rate := rs.limiter.allowOne(now)
 	if sampled {
-		span.setSamplingPriority(ext.PriorityUserKeep, samplernames.RuleRate)
+		span.setSamplingPriorityLocked(ext.PriorityUserKeep, mechanism)
 	} else {
-		span.setSamplingPriority(ext.PriorityUserReject, samplernames.RuleRate)
+		span.setSamplingPriorityLocked(ext.PriorityUserReject, mechanism)
 	}
-	span.SetTag(keyRulesSamplerLimiterRate, rate)
+	span.setMetric(keyRulesSamplerLimiterRate, rate)
 }
 
 // limit returns the rate limit set in the rules sampler, controlled by DD_TRACE_RATE_LIMIT, and
@@ -345,7 +499,7 @@ func newRateLimiter() *rateLimiter {
 
 // singleSpanRulesSampler allows a user-defined list of rules to apply to spans
 // to sample single spans.
-// These rules match based on the span's Service and Name. If empty value is supplied
+// These rules match based on the span's Service, Name, Resource and Tags. If empty value is supplied
 // to either Service or Name field, it will default to "*", allow all.
 // When making a sampling decision, the rules are checked in order until
 // a match is found.
@@ -357,6 +511,7 @@ func newRateLimiter() *rateLimiter {
 // Its value is the max number of spans to sample per second.
 // Spans that matched the rules but exceeded the rate limit are not sampled.
 type singleSpanRulesSampler struct {
+	m     sync.RWMutex
 	rules []SamplingRule // the rules to match spans with
 }
 
@@ -364,19 +519,37 @@ type singleSpanRulesSampler struct {
 // Invalid rules or environment variable values are tolerated, by logging warnings and then ignoring them.
 func newSingleSpanRulesSampler(rules []SamplingRule) *singleSpanRulesSampler {
 	return &singleSpanRulesSampler{
-		rules: rules,
+		rules: compileSamplingRules(rules),
 	}
 }
 
 func (rs *singleSpanRulesSampler) enabled() bool {
+	rs.m.RLock()
+	defer rs.m.RUnlock()
 	return len(rs.rules) > 0
 }
 
+// setRules replaces the sampling rules with the given ones.
+// Returns whether the rules were changed or not.
+func (rs *singleSpanRulesSampler) setRules(rules []SamplingRule) bool {
+	compiled := compileSamplingRules(rules)
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	if equalSamplingRules(rs.rules, compiled) {
+		return false
+	}
+	rs.rules = compiled
+	return true
+}
+
 // apply uses the sampling rules to determine the sampling rate for the
 // provided span. If the rules don't match, then it returns false and the span is not
 // modified.
 func (rs *singleSpanRulesSampler) apply(span *span) bool {
-	for _, rule := range rs.rules {
+	rs.m.RLock()
+	rules := rs.rules
+	rs.m.RUnlock()
+	for _, rule := range rules {
 		if rule.match(span) {
 			rate := rule.Rate
 			span.setMetric(keyRulesSamplerAppliedRate, rate)
@@ -514,6 +687,19 @@ func samplingRulesFromEnv() (trace, span []SamplingRule, err error) {
 	return trace, span, err
 }
 
+// samplingRulesOrigin returns the telemetry origin of the startup sampling rules, given
+// whether they were found in the environment and the rules set using WithSamplingRules.
+func samplingRulesOrigin(fromEnv bool, code []SamplingRule) string {
+	switch {
+	case fromEnv:
+		return "env_var"
+	case len(code) > 0:
+		return "code"
+	default:
+		return ""
+	}
+}
+
 // unmarshalSamplingRules unmarshals JSON from b and returns the sampling rules found, attributing
 // the type t to them. If any errors are occurred, they are returned.
 func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule, error) {
@@ -521,10 +707,12 @@ func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule
 		return nil, nil
 	}
 	var jsonRules []struct {
-		Service      string      `json:"service"`
-		Name         string      `json:"name"`
-		Rate         json.Number `json:"sample_rate"`
-		MaxPerSecond float64     `json:"max_per_second"`
+		Service      string            `json:"service"`
+		Name         string            `json:"name"`
+		Resource     string            `json:"resource"`
+		Tags         map[string]string `json:"tags"`
+		Rate         json.Number       `json:"sample_rate"`
+		MaxPerSecond float64           `json:"max_per_second"`
 	}
 	err := json.Unmarshal(b, &jsonRules)
 	if err != nil {
@@ -550,11 +738,17 @@ func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule
 			errs = append(errs, fmt.Sprintf("at index %d: ignoring rule %+v: rate is out of [0.0, 1.0] range", i, v))
 			continue
 		}
+		var resource *regexp.Regexp
+		if v.Resource != "" {
+			resource = globMatch(v.Resource)
+		}
 		switch spanType {
 		case SamplingRuleSpan:
 			rules = append(rules, SamplingRule{
 				Service:      globMatch(v.Service),
 				Name:         globMatch(v.Name),
+				Resource:     resource,
+				Tags:         v.Tags,
 				Rate:         rate,
 				MaxPerSecond: v.MaxPerSecond,
 				limiter:      newSingleSpanRateLimiter(v.MaxPerSecond),
@@ -575,14 +769,16 @@ func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule
 				continue
 			}
 
-			switch {
-			case v.Service != "" && v.Name != "":
-				rules = append(rules, NameServiceRule(v.Name, v.Service, rate))
-			case v.Service != "":
-				rules = append(rules, ServiceRule(v.Service, rate))
-			case v.Name != "":
-				rules = append(rules, NameRule(v.Name, rate))
+			if v.Service == "" && v.Name == "" && resource == nil && len(v.Tags) == 0 {
+				continue
 			}
+			rules = append(rules, SamplingRule{
+				exactService: v.Service,
+				exactName:    v.Name,
+				Resource:     resource,
+				Tags:         v.Tags,
+				Rate:         rate,
+			})
 		}
 	}
 	if len(errs) != 0 {
@@ -594,11 +790,14 @@ func unmarshalSamplingRules(b []byte, spanType SamplingRuleType) ([]SamplingRule
 // MarshalJSON implements the json.Marshaler interface.
 func (sr *SamplingRule) MarshalJSON() ([]byte, error) {
 	s := struct {
-		Service      string   `json:"service"`
-		Name         string   `json:"name"`
-		Rate         float64  `json:"sample_rate"`
-		Type         string   `json:"type"`
-		MaxPerSecond *float64 `json:"max_per_second,omitempty"`
+		Service      string            `json:"service"`
+		Name         string            `json:"name"`
+		Resource     string            `json:"resource,omitempty"`
+		Tags         map[string]string `json:"tags,omitempty"`
+		Rate         float64           `json:"sample_rate"`
+		Type         string            `json:"type"`
+		MaxPerSecond *float64          `json:"max_per_second,omitempty"`
+		Provenance   string            `json:"provenance,omitempty"`
 	}{}
 	if sr.exactService != "" {
 		s.Service = sr.exactService
@@ -610,10 +809,15 @@ func (sr *SamplingRule) MarshalJSON() ([]byte, error) {
 	} else if sr.Name != nil {
 		s.Name = fmt.Sprintf("%s", sr.Name)
 	}
+	if sr.Resource != nil {
+		s.Resource = fmt.Sprintf("%s", sr.Resource)
+	}
+	s.Tags = sr.Tags
 	s.Rate = sr.Rate
 	s.Type = fmt.Sprintf("%v(%d)", sr.ruleType.String(), sr.ruleType)
 	if sr.MaxPerSecond != 0 {
 		s.MaxPerSecond = &sr.MaxPerSecond
 	}
+	s.Provenance = sr.provenance.String()
 	return json.Marshal(&s)
 }
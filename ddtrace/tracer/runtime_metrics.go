@@ -0,0 +1,222 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"runtime/metrics"
+	"strings"
+	"time"
+
+	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// runtimeMetricsPrefix prefixes the names of the metrics reported from runtime/metrics.
+const runtimeMetricsPrefix = "runtime.go.metrics."
+
+// supportedRuntimeMetrics lists the runtime/metrics metrics reported by the
+// runtimeMetricsCollector. Metrics which are not supported by the running Go version
+// are skipped. Histograms are reported as percentile summaries over each interval.
+var supportedRuntimeMetrics = []string{
+	// scheduler
+	"/sched/gomaxprocs:threads",
+	"/sched/goroutines:goroutines",
+	"/sched/goroutines/not-in-go:goroutines",
+	"/sched/goroutines/runnable:goroutines",
+	"/sched/goroutines/running:goroutines",
+	"/sched/goroutines/waiting:goroutines",
+	"/sched/threads/total:threads",
+	"/sched/latencies:seconds",
+	// garbage collector
+	"/gc/cycles/total:gc-cycles",
+	"/gc/cycles/forced:gc-cycles",
+	"/gc/gogc:percent",
+	"/gc/gomemlimit:bytes",
+	"/gc/heap/goal:bytes",
+	"/gc/heap/live:bytes",
+	"/gc/heap/objects:objects",
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/frees:bytes",
+	"/gc/pauses:seconds",
+	"/sched/pauses/total/gc:seconds",
+	"/cpu/classes/gc/total:cpu-seconds",
+	// memory
+	"/memory/classes/total:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/memory/classes/heap/stacks:bytes",
+	"/memory/classes/heap/unused:bytes",
+	// synchronization
+	"/sync/mutex/wait/total:seconds",
+	// cgo
+	"/cgo/go-to-c-calls:calls",
+}
+
+// supersededRuntimeMetrics maps deprecated metrics to the metrics replacing them. When
+// both are supported, only the replacement is reported.
+var supersededRuntimeMetrics = map[string]string{
+	"/gc/pauses:seconds": "/sched/pauses/total/gc:seconds",
+}
+
+// runtimeMetricsQuantiles holds the quantiles reported for histograms, along with
+// their metric name suffixes.
+var runtimeMetricsQuantiles = []struct {
+	suffix string
+	q      float64
+}{
+	{"median", 0.5},
+	{"p95", 0.95},
+	{"p99", 0.99},
+}
+
+// runtimeMetricsCollector reports runtime metrics read from the runtime/metrics
+// package, which unlike runtime.ReadMemStats does not stop the world.
+type runtimeMetricsCollector struct {
+	samples []metrics.Sample
+	names   []string   // statsd metric names, by sample
+	prev    [][]uint64 // histogram bucket counts at the previous report, by sample
+}
+
+// newRuntimeMetricsCollector returns a collector for the supported runtime metrics
+// available in the running Go version.
+func newRuntimeMetricsCollector() *runtimeMetricsCollector {
+	available := make(map[string]struct{})
+	for _, d := range metrics.All() {
+		available[d.Name] = struct{}{}
+	}
+	c := new(runtimeMetricsCollector)
+	for _, name := range supportedRuntimeMetrics {
+		if _, ok := available[name]; !ok {
+			continue
+		}
+		if by, ok := supersededRuntimeMetrics[name]; ok {
+			if _, ok := available[by]; ok {
+				continue
+			}
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: name})
+		c.names = append(c.names, runtimeMetricName(name))
+	}
+	c.prev = make([][]uint64, len(c.samples))
+	return c
+}
+
+// runtimeMetricName returns the statsd name of the runtime/metrics metric name,
+// e.g. runtime.go.metrics.gc_heap_goal.bytes for /gc/heap/goal:bytes.
+func runtimeMetricName(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	name = strings.NewReplacer("/", "_", "-", "_", ":", ".").Replace(name)
+	return runtimeMetricsPrefix + name
+}
+
+// report reads the runtime metrics and reports them to statsd. Histograms are reported
+// as an average, percentiles and maximum of the values observed since the previous report.
+func (c *runtimeMetricsCollector) report(statsd globalinternal.StatsdClient) {
+	metrics.Read(c.samples)
+	for i, s := range c.samples {
+		name := c.names[i]
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			statsd.Gauge(name, float64(s.Value.Uint64()), nil, 1)
+		case metrics.KindFloat64:
+			statsd.Gauge(name, s.Value.Float64(), nil, 1)
+		case metrics.KindFloat64Histogram:
+			h := s.Value.Float64Histogram()
+			counts := histogramDelta(h.Counts, c.prev[i])
+			c.prev[i] = append(c.prev[i][:0], h.Counts...)
+			summary, ok := summarizeHistogram(counts, h.Buckets)
+			if !ok {
+				// no values were observed during the interval
+				continue
+			}
+			statsd.Gauge(name+".avg", summary.avg, nil, 1)
+			for j, q := range runtimeMetricsQuantiles {
+				statsd.Gauge(name+"."+q.suffix, summary.quantiles[j], nil, 1)
+			}
+			statsd.Gauge(name+".max", summary.max, nil, 1)
+		}
+	}
+}
+
+// histogramDelta returns the bucket counts observed since prev was read.
+func histogramDelta(counts, prev []uint64) []uint64 {
+	delta := make([]uint64, len(counts))
+	for i, n := range counts {
+		if i < len(prev) && prev[i] <= n {
+			n -= prev[i]
+		}
+		delta[i] = n
+	}
+	return delta
+}
+
+// histogramSummary summarizes the values of a histogram.
+type histogramSummary struct {
+	avg       float64
+	quantiles []float64 // by runtimeMetricsQuantiles
+	max       float64
+}
+
+// summarizeHistogram summarizes the histogram having the given bucket counts and
+// boundaries, as described by metrics.Float64Histogram. Values are approximated by
+// the boundaries of their bucket, using the upper one unless it is infinite. It
+// returns false if the histogram is empty.
+func summarizeHistogram(counts []uint64, buckets []float64) (histogramSummary, bool) {
+	var total uint64
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 || len(buckets) != len(counts)+1 {
+		return histogramSummary{}, false
+	}
+	value := func(i int) float64 {
+		if v := buckets[i+1]; !math.IsInf(v, 0) {
+			return v
+		}
+		if v := buckets[i]; !math.IsInf(v, 0) {
+			return v
+		}
+		return 0
+	}
+	summary := histogramSummary{quantiles: make([]float64, len(runtimeMetricsQuantiles))}
+	var (
+		sum  float64
+		seen uint64
+		q    int
+	)
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+		v := value(i)
+		sum += v * float64(n)
+		seen += n
+		for ; q < len(runtimeMetricsQuantiles) && float64(seen) >= runtimeMetricsQuantiles[q].q*float64(total); q++ {
+			summary.quantiles[q] = v
+		}
+		summary.max = v
+	}
+	summary.avg = sum / float64(total)
+	return summary, true
+}
+
+// reportRuntimeMetricsV2 periodically reports go runtime metrics read from the
+// runtime/metrics package at the given interval.
+func (t *tracer) reportRuntimeMetricsV2(interval time.Duration) {
+	c := newRuntimeMetricsCollector()
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			log.Debug("Reporting runtime metrics...")
+			c.report(t.statsd)
+		case <-t.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeMetricName(t *testing.T) {
+	assert.Equal(t, "runtime.go.metrics.gc_heap_goal.bytes", runtimeMetricName("/gc/heap/goal:bytes"))
+	assert.Equal(t, "runtime.go.metrics.sched_goroutines_not_in_go.goroutines", runtimeMetricName("/sched/goroutines/not-in-go:goroutines"))
+	assert.Equal(t, "runtime.go.metrics.gc_cycles_total.gc_cycles", runtimeMetricName("/gc/cycles/total:gc-cycles"))
+}
+
+func TestSummarizeHistogram(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		_, ok := summarizeHistogram([]uint64{0, 0}, []float64{0, 1, 2})
+		assert.False(t, ok)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		assert := assert.New(t)
+		// 90 values in (0, 1], 9 in (1, 2] and 1 in (2, +Inf)
+		s, ok := summarizeHistogram([]uint64{90, 9, 1}, []float64{0, 1, 2, math.Inf(1)})
+		assert.True(ok)
+		assert.Equal([]float64{1, 2, 2}, s.quantiles)
+		assert.Equal(2., s.max)
+		assert.InDelta((90*1+9*2+1*2)/100., s.avg, 1e-9)
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		assert := assert.New(t)
+		counts := histogramDelta([]uint64{10, 5, 3}, []uint64{10, 1, 0})
+		assert.Equal([]uint64{0, 4, 3}, counts)
+		s, ok := summarizeHistogram(counts, []float64{math.Inf(-1), 1, 2, 4})
+		assert.True(ok)
+		assert.Equal(2., s.quantiles[0])
+		assert.Equal(4., s.max)
+	})
+}
+
+func TestReportRuntimeMetricsV2(t *testing.T) {
+	assert := assert.New(t)
+	var tg testStatsdClient
+	c := newRuntimeMetricsCollector()
+	assert.NotEmpty(c.samples)
+	c.report(&tg)
+
+	// generate some scheduling and GC activity for the next interval
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	runtime.GC()
+	tg.Reset()
+	c.report(&tg)
+
+	calls := tg.CallNames()
+	assert.Contains(calls, "runtime.go.metrics.gc_heap_goal.bytes")
+	assert.Contains(calls, "runtime.go.metrics.sched_goroutines.goroutines")
+	assert.Contains(calls, "runtime.go.metrics.sync_mutex_wait_total.seconds")
+	assert.Contains(calls, "runtime.go.metrics.sched_latencies.seconds.p99")
+	assert.Contains(calls, "runtime.go.metrics.sched_latencies.seconds.max")
+	assert.Contains(calls, "runtime.go.metrics.sched_pauses_total_gc.seconds.median")
+	assert.NotContains(calls, "runtime.go.metrics.gc_pauses.seconds.median")
+	assert.NotContains(calls, "runtime.go.mem_stats.alloc")
+}
+
+func TestRuntimeMetricsV2Config(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		c := newConfig()
+		assert.False(t, c.runtimeMetricsV2)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_RUNTIME_METRICS_V2_ENABLED", "true")
+		c := newConfig()
+		assert.True(t, c.runtimeMetrics)
+		assert.True(t, c.runtimeMetricsV2)
+	})
+
+	t.Run("env-disabled", func(t *testing.T) {
+		t.Setenv("DD_RUNTIME_METRICS_V2_ENABLED", "true")
+		t.Setenv("DD_RUNTIME_METRICS_ENABLED", "false")
+		c := newConfig()
+		assert.False(t, c.runtimeMetrics)
+	})
+
+	t.Run("option", func(t *testing.T) {
+		c := newConfig(WithRuntimeMetricsV2())
+		assert.True(t, c.runtimeMetrics)
+		assert.True(t, c.runtimeMetricsV2)
+	})
+}
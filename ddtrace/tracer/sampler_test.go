@@ -253,7 +253,7 @@ func TestRuleEnvVars(t *testing.T) {
 				// invalid rule ignored
 				value:  `[{"service": "abcd", "sample_rate": 42.0}, {"service": "abcd", "sample_rate": 0.2}]`,
 				ruleN:  1,
-				errStr: "\n\tat index 0: ignoring rule {Service:abcd Name: Rate:42.0 MaxPerSecond:0}: rate is out of [0.0, 1.0] range",
+				errStr: "\n\tat index 0: ignoring rule {Service:abcd Name: Resource: Tags:map[] Rate:42.0 MaxPerSecond:0}: rate is out of [0.0, 1.0] range",
 			}, {
 				value:  `not JSON at all`,
 				errStr: "\n\terror unmarshalling JSON: invalid character 'o' in literal null (expecting 'u')",
@@ -303,7 +303,7 @@ func TestRuleEnvVars(t *testing.T) {
 				// invalid rule ignored
 				value:  `[{"service": "abcd", "sample_rate": 42.0}, {"service": "abcd", "sample_rate": 0.2}]`,
 				ruleN:  1,
-				errStr: "\n\tat index 0: ignoring rule {Service:abcd Name: Rate:42.0 MaxPerSecond:0}: rate is out of [0.0, 1.0] range",
+				errStr: "\n\tat index 0: ignoring rule {Service:abcd Name: Resource: Tags:map[] Rate:42.0 MaxPerSecond:0}: rate is out of [0.0, 1.0] range",
 			}, {
 				value:  `not JSON at all`,
 				errStr: "\n\terror unmarshalling JSON: invalid character 'o' in literal null (expecting 'u')",
@@ -442,6 +442,102 @@ func TestRulesSampler(t *testing.T) {
 		}
 	})
 
+	t.Run("resource-and-tags", func(t *testing.T) {
+		defer os.Unsetenv("DD_TRACE_SAMPLING_RULES")
+		for _, tt := range []struct {
+			rules string
+			match bool
+		}{
+			{rules: `[{"resource": "GET /users/*", "sample_rate": 1.0}]`, match: true},
+			{rules: `[{"resource": "POST *", "sample_rate": 1.0}]`, match: false},
+			{rules: `[{"service": "test-service", "resource": "GET /users/?", "sample_rate": 1.0}]`, match: true},
+			{rules: `[{"service": "toast-service", "resource": "GET /users/?", "sample_rate": 1.0}]`, match: false},
+			{rules: `[{"tags": {"customer.tier": "gold"}, "sample_rate": 1.0}]`, match: true},
+			{rules: `[{"tags": {"customer.tier": "g*", "http.status_code": "5??"}, "sample_rate": 1.0}]`, match: true},
+			{rules: `[{"tags": {"http.status_code": "4??"}, "sample_rate": 1.0}]`, match: false},
+			{rules: `[{"tags": {"missing": "*"}, "sample_rate": 1.0}]`, match: false},
+			{rules: `[{"resource": "GET /users/1", "tags": {"customer.tier": "silver"}, "sample_rate": 1.0}]`, match: false},
+		} {
+			t.Run("", func(t *testing.T) {
+				assert := assert.New(t)
+				os.Setenv("DD_TRACE_SAMPLING_RULES", tt.rules)
+				rules, _, err := samplingRulesFromEnv()
+				assert.NoError(err)
+				assert.Len(rules, 1)
+				rs := newRulesSampler(rules, nil, globalSampleRate())
+
+				span := makeSpan("http.request", "test-service")
+				span.Resource = "GET /users/1"
+				span.SetTag("customer.tier", "gold")
+				span.SetTag("http.status_code", 503)
+				assert.Equal(tt.match, rs.SampleTrace(span))
+			})
+		}
+
+		span := makeSpan("http.request", "test-service")
+		span.Resource = "GET /users/1"
+		span.SetTag("http.status_code", 503)
+		span.finished = true
+		rs := newRulesSampler(nil, []SamplingRule{{
+			Service:  globMatch("*"),
+			Name:     globMatch("*"),
+			Resource: regexp.MustCompile("^GET "),
+			Tags:     map[string]string{"http.status_code": "50?"},
+			Rate:     1.0,
+			ruleType: SamplingRuleSpan,
+			limiter:  newSingleSpanRateLimiter(0),
+		}}, globalSampleRate())
+		assert.True(t, rs.SampleSpan(span))
+	})
+
+	t.Run("tags-set-after-start", func(t *testing.T) {
+		rules := []SamplingRule{{Tags: map[string]string{"http.status_code": "5??"}, Rate: 0}}
+		tracer, _, _, stop := startTestTracer(t, WithSamplingRules(rules))
+		defer stop()
+
+		t.Run("matched", func(t *testing.T) {
+			assert := assert.New(t)
+			span := tracer.StartSpan("http.request").(*span)
+			assert.EqualValues(ext.PriorityAutoKeep, span.Metrics[keySamplingPriority])
+			span.SetTag("http.status_code", 503)
+			span.Finish()
+			assert.EqualValues(ext.PriorityUserReject, span.Metrics[keySamplingPriority])
+			assert.EqualValues(0, span.Metrics[keyRulesSamplerAppliedRate])
+		})
+
+		t.Run("unmatched", func(t *testing.T) {
+			assert := assert.New(t)
+			span := tracer.StartSpan("http.request").(*span)
+			span.SetTag("http.status_code", 200)
+			span.Finish()
+			assert.EqualValues(ext.PriorityAutoKeep, span.Metrics[keySamplingPriority])
+			assert.NotContains(span.Metrics, keyRulesSamplerAppliedRate)
+		})
+
+		t.Run("manual", func(t *testing.T) {
+			assert := assert.New(t)
+			span := tracer.StartSpan("http.request").(*span)
+			span.SetTag(ext.ManualKeep, true)
+			span.SetTag("http.status_code", 503)
+			span.Finish()
+			assert.EqualValues(ext.PriorityUserKeep, span.Metrics[keySamplingPriority])
+			assert.NotContains(span.Metrics, keyRulesSamplerAppliedRate)
+		})
+
+		t.Run("injected", func(t *testing.T) {
+			assert := assert.New(t)
+			span := tracer.StartSpan("http.request").(*span)
+			carrier := TextMapCarrier{}
+			assert.NoError(tracer.Inject(span.Context(), carrier))
+			span.SetTag("http.status_code", 503)
+			span.Finish()
+			// the decision propagated downstream is kept
+			assert.Equal("1", carrier[DefaultPriorityHeader])
+			assert.EqualValues(ext.PriorityAutoKeep, span.Metrics[keySamplingPriority])
+			assert.NotContains(span.Metrics, keyRulesSamplerAppliedRate)
+		})
+	})
+
 	t.Run("matching-span-rules-from-env", func(t *testing.T) {
 		defer os.Unsetenv("DD_SPAN_SAMPLING_RULES")
 		for _, tt := range []struct {
@@ -934,18 +1030,22 @@ func TestSamplingRuleMarshall(t *testing.T) {
 		in  SamplingRule
 		out string
 	}{
-		{SamplingRule{nil, nil, 0, 0, 0, "srv", "ops", nil},
+		{SamplingRule{nil, nil, nil, nil, 0, 0, 0, "srv", "ops", nil, nil, provenanceLocal},
 			`{"service":"srv","name":"ops","sample_rate":0,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), nil, 0, 0, 0, "srv", "ops", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), nil, nil, nil, 0, 0, 0, "srv", "ops", nil, nil, provenanceLocal},
 			`{"service":"srv","name":"ops","sample_rate":0,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.*"), regexp.MustCompile("ops.[0-9]+]"), 0, 0, 0, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.*"), regexp.MustCompile("ops.[0-9]+]"), nil, nil, 0, 0, 0, "", "", nil, nil, provenanceLocal},
 			`{"service":"srv.*","name":"ops.[0-9]+]","sample_rate":0,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 0, 0, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), nil, nil, 0.55, 0, 0, "", "", nil, nil, provenanceLocal},
 			`{"service":"srv.[0-9]+]","name":"ops.[0-9]+]","sample_rate":0.55,"type":"trace(0)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 0, 1, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), nil, nil, 0.55, 0, 1, "", "", nil, nil, provenanceLocal},
 			`{"service":"srv.[0-9]+]","name":"ops.[0-9]+]","sample_rate":0.55,"type":"span(1)"}`},
-		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), 0.55, 1000, 1, "", "", nil},
+		{SamplingRule{regexp.MustCompile("srv.[0-9]+]"), regexp.MustCompile("ops.[0-9]+]"), nil, nil, 0.55, 1000, 1, "", "", nil, nil, provenanceLocal},
 			`{"service":"srv.[0-9]+]","name":"ops.[0-9]+]","sample_rate":0.55,"type":"span(1)","max_per_second":1000}`},
+		{SamplingRule{Resource: regexp.MustCompile("GET /users"), Tags: map[string]string{"http.status_code": "5??"}, Rate: 1},
+			`{"service":"","name":"","resource":"GET /users","tags":{"http.status_code":"5??"},"sample_rate":1,"type":"trace(0)"}`},
+		{SamplingRule{exactService: "srv", Rate: 0.5, provenance: provenanceCustomer},
+			`{"service":"srv","name":"","sample_rate":0.5,"type":"trace(0)","provenance":"customer"}`},
 	} {
 		m, err := tt.in.MarshalJSON()
 		assert.Nil(t, err)
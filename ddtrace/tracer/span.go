@@ -10,11 +10,11 @@ package tracer
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
 	"reflect"
-	"runtime"
 	"runtime/pprof"
 	rt "runtime/trace"
 	"strconv"
@@ -42,14 +42,16 @@ type (
 	spanList []*span
 
 	// spanLists implements msgp.Decodable on top of a slice of spanList.
-	// This type is only used in tests.
+	// It is used to decode payloads, e.g. by the OTLP transport and in tests.
 	spanLists []spanList
 )
 
 var (
-	_ ddtrace.Span   = (*span)(nil)
-	_ msgp.Encodable = (*spanList)(nil)
-	_ msgp.Decodable = (*spanLists)(nil)
+	_ ddtrace.Span           = (*span)(nil)
+	_ ddtrace.SpanWithLinks  = (*span)(nil)
+	_ ddtrace.SpanWithEvents = (*span)(nil)
+	_ msgp.Encodable         = (*spanList)(nil)
+	_ msgp.Decodable         = (*spanLists)(nil)
 )
 
 // errorConfig holds customization options for setting error tags.
@@ -77,10 +79,16 @@ type span struct {
 	ParentID uint64             `msg:"parent_id"`         // identifier of the span's direct parent
 	Error    int32              `msg:"error"`             // error status of the span; 0 means no errors
 
-	goExecTraced bool         `msg:"-"`
-	noDebugStack bool         `msg:"-"` // disables debug stack traces
-	finished     bool         `msg:"-"` // true if the span has been submitted to a tracer. Can only be read/modified if the trace is locked.
-	context      *spanContext `msg:"-"` // span propagation context
+	SpanLinks []ddtrace.SpanLink `msg:"span_links,omitempty"` // links to spans in other traces
+
+	SpanEvents    []ddtrace.SpanEvent `msg:"-"` // timestamped events, encoded into the "events" tag when the span finishes
+	droppedEvents int                 `msg:"-"` // number of events dropped because of ddtrace.MaxSpanEvents
+
+	goExecTraced bool          `msg:"-"`
+	noDebugStack bool          `msg:"-"` // disables debug stack traces
+	finished     bool          `msg:"-"` // true if the span has been submitted to a tracer. Can only be read/modified if the trace is locked.
+	samplingRule *SamplingRule `msg:"-"` // the trace sampling rule which set the sampling priority, if any
+	context      *spanContext  `msg:"-"` // span propagation context
 
 	pprofCtxActive  context.Context `msg:"-"` // contains pprof.WithLabel labels to tell the profiler more about this span
 	pprofCtxRestore context.Context `msg:"-"` // contains pprof.WithLabel labels of the parent span (if any) that need to be restored when this span finishes
@@ -116,6 +124,12 @@ func (s *span) SetTag(key string, value interface{}) {
 	if s.finished {
 		return
 	}
+	s.setTag(key, value)
+}
+
+// setTag sets a key/value pair as metadata on the span. It must be called with the
+// span locked.
+func (s *span) setTag(key string, value interface{}) {
 	switch key {
 	case ext.Error:
 		s.setTagError(value, errorConfig{
@@ -184,6 +198,78 @@ func (s *span) SetTag(key string, value interface{}) {
 	s.setMeta(key, fmt.Sprint(value))
 }
 
+// AddLink appends the given link to the span. Links are used to reference
+// spans in other traces, such as the producer spans of a batch of consumed
+// messages.
+func (s *span) AddLink(link ddtrace.SpanLink) {
+	s.Lock()
+	defer s.Unlock()
+	// We don't lock spans when flushing, so we could have a data race when
+	// modifying a span as it's being flushed. This protects us against that
+	// race, since spans are marked `finished` before we flush them.
+	if s.finished {
+		return
+	}
+	s.SpanLinks = append(s.SpanLinks, link)
+}
+
+// Links returns a copy of the links attached to the span.
+func (s *span) Links() []ddtrace.SpanLink {
+	s.RLock()
+	defer s.RUnlock()
+	if len(s.SpanLinks) == 0 {
+		return nil
+	}
+	links := make([]ddtrace.SpanLink, len(s.SpanLinks))
+	copy(links, s.SpanLinks)
+	return links
+}
+
+// AddEvent records an event with the given name and attributes which occurred at ts,
+// or now if ts is zero. Events added after the span has finished, or beyond
+// ddtrace.MaxSpanEvents, are ignored.
+func (s *span) AddEvent(name string, ts time.Time, attrs map[string]interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	if len(s.SpanEvents) >= ddtrace.MaxSpanEvents {
+		s.droppedEvents++
+		return
+	}
+	s.SpanEvents = append(s.SpanEvents, ddtrace.NewSpanEvent(name, ts, attrs))
+}
+
+// Events returns a copy of the events recorded on the span.
+func (s *span) Events() []ddtrace.SpanEvent {
+	s.RLock()
+	defer s.RUnlock()
+	if len(s.SpanEvents) == 0 {
+		return nil
+	}
+	events := make([]ddtrace.SpanEvent, len(s.SpanEvents))
+	copy(events, s.SpanEvents)
+	return events
+}
+
+// encodeEvents JSON encodes the events of the span into the keySpanEvents tag.
+// It must be called with the span locked.
+func (s *span) encodeEvents() {
+	if s.droppedEvents > 0 {
+		s.setMetric(keySpanEventsDropped, float64(s.droppedEvents))
+	}
+	if len(s.SpanEvents) == 0 {
+		return
+	}
+	b, err := json.Marshal(s.SpanEvents)
+	if err != nil {
+		log.Debug("Unable to encode span events, dropping them: %v", err)
+		return
+	}
+	s.setMeta(keySpanEvents, string(b))
+}
+
 // setSamplingPriority locks then span, then updates the sampling priority.
 // It also updates the trace's sampling priority.
 func (s *span) setSamplingPriority(priority int, sampler samplernames.SamplerName) {
@@ -307,15 +393,37 @@ func (s *span) setTagError(value interface{}, cfg errorConfig) {
 	case bool:
 		// bool value as per Opentracing spec.
 		setError(v)
+	case *PanicError:
+		setError(true)
+		s.setMeta(ext.ErrorMsg, v.Error())
+		s.setMeta(ext.ErrorType, panicErrorType)
+		if !cfg.noDebugStack {
+			s.setMeta(ext.ErrorStack, string(v.Stack))
+		}
+		s.setMeta(ext.ErrorFingerprint, errorFingerprint([]string{panicErrorType, fmt.Sprintf("%T", v.Value)}, nil))
 	case error:
 		// if anyone sets an error value as the tag, be nice here
 		// and provide all the benefits.
 		setError(true)
 		s.setMeta(ext.ErrorMsg, v.Error())
 		s.setMeta(ext.ErrorType, reflect.TypeOf(v).String())
+		info := inspectError(v)
+		s.setMeta(ext.ErrorCauseType, reflect.TypeOf(info.cause).String())
+		var stack string
+		var funcs []string
+		if pcs := info.stack; len(pcs) > 0 {
+			// prefer the stack trace recorded where the error was created
+			if cfg.stackFrames > 0 && uint(len(pcs)) > cfg.stackFrames {
+				pcs = pcs[:cfg.stackFrames]
+			}
+			stack, funcs = formatStack(pcs)
+		} else if !cfg.noDebugStack {
+			stack, funcs = formatStack(callerPCs(cfg.stackFrames, cfg.stackSkip))
+		}
 		if !cfg.noDebugStack {
-			s.setMeta(ext.ErrorStack, takeStacktrace(cfg.stackFrames, cfg.stackSkip))
+			s.setMeta(ext.ErrorStack, stack)
 		}
+		s.setMeta(ext.ErrorFingerprint, errorFingerprint(info.types, funcs))
 		switch v.(type) {
 		case xerrors.Formatter:
 			s.setMeta(ext.ErrorDetails, fmt.Sprintf("%+v", v))
@@ -339,34 +447,9 @@ const defaultStackLength = 32
 // takeStacktrace takes a stack trace of maximum n entries, skipping the first skip entries.
 // If n is 0, up to 20 entries are retrieved.
 func takeStacktrace(n, skip uint) string {
-	if n == 0 {
-		n = defaultStackLength
-	}
-	var builder strings.Builder
-	pcs := make([]uintptr, n)
-
-	// +2 to exclude runtime.Callers and takeStacktrace
-	numFrames := runtime.Callers(2+int(skip), pcs)
-	if numFrames == 0 {
-		return ""
-	}
-	frames := runtime.CallersFrames(pcs[:numFrames])
-	for i := 0; ; i++ {
-		frame, more := frames.Next()
-		if i != 0 {
-			builder.WriteByte('\n')
-		}
-		builder.WriteString(frame.Function)
-		builder.WriteByte('\n')
-		builder.WriteByte('\t')
-		builder.WriteString(frame.File)
-		builder.WriteByte(':')
-		builder.WriteString(strconv.Itoa(frame.Line))
-		if !more {
-			break
-		}
-	}
-	return builder.String()
+	// +1 to exclude takeStacktrace
+	stack, _ := formatStack(callerPCs(n, skip+1))
+	return stack
 }
 
 // setMeta sets a string tag. This method is not safe for concurrent use.
@@ -518,18 +601,24 @@ func (s *span) finish(finishTime int64) {
 	if s.Duration < 0 {
 		s.Duration = 0
 	}
+	s.encodeEvents()
 
 	keep := true
 	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
 		// we have an active tracer
-		if t.config.canComputeStats() && shouldComputeStats(s) {
-			// the agent supports computed stats
-			select {
-			case t.stats.In <- newAggregableSpan(s, t.obfuscator):
-				// ok
-			default:
-				log.Error("Stats channel full, disregarding span.")
-			}
+		t.processFinish(s)
+		t.resample(s)
+		// the tags below depend on the span service and tags, which processors may change
+		setPeerService(s, t.config)
+		// attach the _dd.base_service tag only when the globally configured service name is different from the
+		// span service name.
+		if s.Service != "" && !strings.EqualFold(s.Service, t.config.serviceName) {
+			s.setMeta(keyBaseService, t.config.serviceName)
+		}
+		if len(t.config.spanProcessors) == 0 {
+			// with span processors, stats are computed once the chunk
+			// has been processed, see (*trace).finishChunk
+			t.computeStats(s)
 		}
 		if t.config.canDropP0s() {
 			// the agent supports dropping p0's in the client
@@ -557,6 +646,20 @@ func (s *span) finish(finishTime int64) {
 	s.context.finish()
 }
 
+// computeStats submits the finished span s to the stats concentrator, if the agent
+// supports computed stats or RED metrics are enabled.
+func (t *tracer) computeStats(s *span) {
+	if !(t.config.canComputeStats() || t.config.redMetrics) || !shouldComputeStats(s) {
+		return
+	}
+	select {
+	case t.stats.In <- newAggregableSpan(s, t.obfuscator):
+		// ok
+	default:
+		log.Error("Stats channel full, disregarding span.")
+	}
+}
+
 // newAggregableSpan creates a new summary for the span s, within an application
 // version version.
 func newAggregableSpan(s *span, obfuscator *obfuscate.Obfuscator) *aggregableSpan {
@@ -566,13 +669,17 @@ func newAggregableSpan(s *span, obfuscator *obfuscate.Obfuscator) *aggregableSpa
 			statusCode = uint32(c)
 		}
 	}
+	peerTags, peerTagsHash := peerTags(s)
 	key := aggregation{
-		Name:       s.Name,
-		Resource:   obfuscatedResource(obfuscator, s.Type, s.Resource),
-		Service:    s.Service,
-		Type:       s.Type,
-		Synthetics: strings.HasPrefix(s.Meta[keyOrigin], "synthetics"),
-		StatusCode: statusCode,
+		Name:           s.Name,
+		Resource:       obfuscatedResource(obfuscator, s.Type, s.Resource),
+		Service:        s.Service,
+		Type:           s.Type,
+		Synthetics:     strings.HasPrefix(s.Meta[keyOrigin], "synthetics"),
+		StatusCode:     statusCode,
+		SpanKind:       s.Meta[ext.SpanKind],
+		GRPCStatusCode: grpcStatusCode(s),
+		PeerTagsHash:   peerTagsHash,
 	}
 	return &aggregableSpan{
 		key:      key,
@@ -580,6 +687,7 @@ func newAggregableSpan(s *span, obfuscator *obfuscate.Obfuscator) *aggregableSpa
 		Duration: s.Duration,
 		TopLevel: s.Metrics[keyTopLevel] == 1,
 		Error:    s.Error,
+		PeerTags: peerTags,
 	}
 }
 
@@ -741,6 +849,12 @@ const (
 	keyPeerServiceRemappedFrom = "_dd.peer.service.remapped_from"
 	// keyBaseService contains the globally configured tracer service name. It is only set for spans that override it.
 	keyBaseService = "_dd.base_service"
+	// keySpanLinks holds the JSON encoded span links of a span, for encodings which can not carry them natively.
+	keySpanLinks = "_dd.span_links"
+	// keySpanEvents holds the JSON encoded span events of a span.
+	keySpanEvents = "events"
+	// keySpanEventsDropped holds the number of span events dropped because the span had too many.
+	keySpanEventsDropped = "_dd.span_events.dropped"
 )
 
 // The following set of tags is used for user monitoring and set through calls to span.SetUser().
@@ -16,6 +16,7 @@ import (
 	"testing"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
@@ -113,6 +114,114 @@ func TestSpanFinishTwice(t *testing.T) {
 	tracer.awaitPayload(t, 1)
 }
 
+func TestSpanLinks(t *testing.T) {
+	link := ddtrace.SpanLink{
+		TraceID:     1,
+		TraceIDHigh: 2,
+		SpanID:      3,
+		Attributes:  map[string]string{"message.id": "42"},
+		Tracestate:  "dd=s:1",
+		Flags:       1,
+	}
+
+	t.Run("start-option", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		sp := tracer.StartSpan("kafka.consume", WithSpanLinks([]ddtrace.SpanLink{link}))
+		assert.Equal(t, []ddtrace.SpanLink{link}, sp.(*span).Links())
+	})
+
+	t.Run("add", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		sp := tracer.StartSpan("kafka.consume").(*span)
+		sp.AddLink(link)
+		sp.AddLink(ddtrace.SpanLink{TraceID: 4, SpanID: 5})
+		sp.Finish()
+		sp.AddLink(ddtrace.SpanLink{TraceID: 6, SpanID: 7})
+		assert.Equal(t, []ddtrace.SpanLink{link, {TraceID: 4, SpanID: 5}}, sp.Links())
+	})
+
+	t.Run("encoding", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, flush, stop := startTestTracer(t)
+		defer stop()
+
+		sp := tracer.StartSpan("kafka.consume", WithSpanLinks([]ddtrace.SpanLink{link}))
+		sp.Finish()
+		nolinks := tracer.StartSpan("kafka.produce")
+		nolinks.Finish()
+		flush(2)
+
+		traces := transport.Traces()
+		assert.Len(traces, 2)
+		assert.Equal([]ddtrace.SpanLink{link}, traces[0][0].SpanLinks)
+		assert.Nil(traces[1][0].SpanLinks)
+	})
+}
+
+func TestSpanEvents(t *testing.T) {
+	t.Run("add", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		ts := time.Unix(1700000000, 0)
+		attrs := map[string]interface{}{"cache.key": "user:42"}
+		sp := tracer.StartSpan("cache.get").(*span)
+		sp.AddEvent("cache.miss", ts, attrs)
+		attrs["cache.key"] = "changed"
+		sp.AddEvent("retry", time.Time{}, nil)
+		sp.Finish()
+		sp.AddEvent("late", ts, nil)
+
+		events := sp.Events()
+		require.Len(t, events, 2)
+		assert.Equal(ddtrace.SpanEvent{
+			Name:         "cache.miss",
+			TimeUnixNano: ts.UnixNano(),
+			Attributes:   map[string]interface{}{"cache.key": "user:42"},
+		}, events[0])
+		assert.Equal("retry", events[1].Name)
+		assert.NotZero(events[1].TimeUnixNano)
+		assert.Nil(events[1].Attributes)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		sp := tracer.StartSpan("cache.get").(*span)
+		for i := 0; i < ddtrace.MaxSpanEvents+3; i++ {
+			sp.AddEvent("retry", time.Time{}, nil)
+		}
+		sp.Finish()
+		assert.Len(sp.Events(), ddtrace.MaxSpanEvents)
+		assert.Equal(float64(3), sp.Metrics[keySpanEventsDropped])
+	})
+
+	t.Run("encoding", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, flush, stop := startTestTracer(t)
+		defer stop()
+
+		sp := tracer.StartSpan("cache.get")
+		sp.(ddtrace.SpanWithEvents).AddEvent("cache.miss", time.Unix(0, 42), map[string]interface{}{"attempt": 2})
+		sp.Finish()
+		noevents := tracer.StartSpan("cache.set")
+		noevents.Finish()
+		flush(2)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 2)
+		assert.Equal(`[{"name":"cache.miss","time_unix_nano":42,"attributes":{"attempt":2}}]`, traces[0][0].Meta[keySpanEvents])
+		assert.NotContains(traces[1][0].Meta, keySpanEvents)
+	})
+}
+
 func TestShouldDrop(t *testing.T) {
 	for _, tt := range []struct {
 		prio   int
@@ -260,6 +369,112 @@ func TestSpanFinishWithErrorStackFrames(t *testing.T) {
 	assert.Equal(strings.Count(span.Meta[ext.ErrorStack], "\n\t"), 2)
 }
 
+// stackFrame mimics the Frame type of github.com/pkg/errors.
+type stackFrame uintptr
+
+// stackError mimics the errors of github.com/pkg/errors, which record the stack trace
+// of the place where they were created.
+type stackError struct {
+	msg   string
+	stack []stackFrame
+}
+
+func newStackError(msg string) error {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	stack := make([]stackFrame, n)
+	for i := range stack {
+		stack[i] = stackFrame(pcs[i])
+	}
+	return &stackError{msg: msg, stack: stack}
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() []stackFrame { return e.stack }
+
+// joinError mimics the errors returned by errors.Join.
+type joinError []error
+
+func (e joinError) Error() string { return "joined" }
+
+func (e joinError) Unwrap() []error { return e }
+
+func createStackError() error {
+	return newStackError("boom")
+}
+
+func TestSpanErrorChain(t *testing.T) {
+	t.Run("unwrapped", func(t *testing.T) {
+		assert := assert.New(t)
+		span := newBasicSpan("web.request")
+		span.Finish(WithError(errors.New("test error")))
+
+		assert.Equal("*errors.errorString", span.Meta[ext.ErrorCauseType])
+		assert.NotEmpty(span.Meta[ext.ErrorFingerprint])
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		assert := assert.New(t)
+		_, cause := os.Open("/does/not/exist")
+		err := fmt.Errorf("loading config: %w", cause)
+		span := newBasicSpan("web.request")
+		span.SetTag(ext.Error, err)
+
+		assert.Equal(err.Error(), span.Meta[ext.ErrorMsg])
+		assert.Equal("*fmt.wrapError", span.Meta[ext.ErrorType])
+		assert.Equal("syscall.Errno", span.Meta[ext.ErrorCauseType])
+	})
+
+	t.Run("joined", func(t *testing.T) {
+		assert := assert.New(t)
+		err := joinError{nil, fmt.Errorf("wrap: %w", os.ErrNotExist), os.ErrClosed}
+		info := inspectError(err)
+
+		assert.Equal(os.ErrNotExist, info.cause)
+		assert.Equal([]string{"tracer.joinError", "*fmt.wrapError", "*errors.errorString", "*errors.errorString"}, info.types)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		err := joinError{nil}
+		err[0] = err
+		assert.Len(t, inspectError(err).types, maxErrorChainLength)
+	})
+
+	t.Run("stack", func(t *testing.T) {
+		assert := assert.New(t)
+		err := fmt.Errorf("wrap: %w", createStackError())
+		span := newBasicSpan("web.request")
+		span.Finish(WithError(err))
+
+		assert.Equal("*tracer.stackError", span.Meta[ext.ErrorCauseType])
+		stack := span.Meta[ext.ErrorStack]
+		assert.True(strings.HasPrefix(stack, "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer.createStackError\n"), stack)
+		assert.NotContains(stack, "(*span).Finish")
+	})
+
+	t.Run("stack-frames", func(t *testing.T) {
+		span := newBasicSpan("web.request")
+		span.Finish(WithError(createStackError()), StackFrames(2, 0))
+		assert.Equal(t, 2, strings.Count(span.Meta[ext.ErrorStack], "\n\t"))
+	})
+
+	t.Run("fingerprint", func(t *testing.T) {
+		assert := assert.New(t)
+		fingerprint := func(err error) string {
+			span := newBasicSpan("web.request")
+			span.Finish(WithError(err))
+			return span.Meta[ext.ErrorFingerprint]
+		}
+		// the fingerprint does not depend on the error message
+		a := fingerprint(fmt.Errorf("user %d: %w", 1, createStackError()))
+		b := fingerprint(fmt.Errorf("user %d: %w", 2, createStackError()))
+		assert.Equal(a, b)
+		assert.NotEqual(a, fingerprint(createStackError()))
+		assert.NotEqual(a, fingerprint(fmt.Errorf("user %d: %w", 1, newStackError("boom"))))
+	})
+}
+
 // nilStringer is used to test nil detection when setting tags.
 type nilStringer struct {
 	s string
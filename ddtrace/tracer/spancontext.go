@@ -10,7 +10,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -85,6 +84,15 @@ func (t *traceID) UpperHex() string {
 type spanContext struct {
 	updated bool // updated is tracking changes for priority / origin / x-datadog-tags
 
+	// baggageOnly reports whether this context was extracted from a W3C baggage header
+	// alone, or with the restart propagation behavior, without any trace to continue.
+	// Spans started from it are root spans.
+	baggageOnly bool
+
+	// links holds the span links added to the root spans started from a baggageOnly
+	// context, referencing the trace which was not continued.
+	links []ddtrace.SpanLink
+
 	// the below group should propagate only locally
 
 	trace  *trace // reference to the trace that this span belongs too
@@ -135,10 +143,6 @@ func newSpanContext(span *span, parent *spanContext) *spanContext {
 	if context.trace == nil {
 		context.trace = newTrace()
 	}
-	if context.trace.root == nil {
-		// first span in the trace can safely be assumed to be the root
-		context.trace.root = span
-	}
 	// put span in context's trace
 	context.trace.push(span)
 	// setting context.updated to false here is necessary to distinguish
@@ -241,15 +245,18 @@ const (
 // priority, the root reference and a buffer of the spans which are part of the
 // trace, if these exist.
 type trace struct {
-	mu               sync.RWMutex      // guards below fields
-	spans            []*span           // all the spans that are part of this trace
-	tags             map[string]string // trace level tags
-	propagatingTags  map[string]string // trace level tags that will be propagated across service boundaries
-	finished         int               // the number of finished spans
-	full             bool              // signifies that the span buffer is full
-	priority         *float64          // sampling priority
-	locked           bool              // specifies if the sampling priority can be altered
-	samplingDecision samplingDecision  // samplingDecision indicates whether to send the trace to the agent.
+	mu               sync.RWMutex             // guards below fields
+	spans            []*span                  // all the spans that are part of this trace
+	tags             map[string]string        // trace level tags
+	propagatingTags  map[string]string        // trace level tags that will be propagated across service boundaries
+	finished         int                      // the number of finished spans
+	full             bool                     // signifies that the span buffer is full
+	priority         *float64                 // sampling priority
+	locked           bool                     // specifies if the sampling priority can be altered
+	sampler          samplernames.SamplerName // the mechanism which set the sampling priority
+	samplingDecision samplingDecision         // samplingDecision indicates whether to send the trace to the agent.
+	tail             tailState                // tail reports whether the sampling decision is deferred until the trace completes
+	injected         bool                     // injected reports whether the trace context was propagated downstream
 
 	// root specifies the root of the trace, if known; it is nil when a span
 	// context is extracted from a carrier, at which point there are no spans in
@@ -329,6 +336,7 @@ func (t *trace) setSamplingPriorityLocked(p int, sampler samplernames.SamplerNam
 		t.priority = new(float64)
 	}
 	*t.priority = float64(p)
+	t.sampler = sampler
 	_, ok := t.propagatingTags[keyDecisionMaker]
 	if p > 0 && !ok && sampler != samplernames.Unknown {
 		// We have a positive priority and the sampling mechanism isn't set.
@@ -347,6 +355,10 @@ func (t *trace) setSamplingPriorityLocked(p int, sampler samplernames.SamplerNam
 func (t *trace) push(sp *span) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	if t.root == nil {
+		// first span in the trace can safely be assumed to be the root
+		t.root = sp
+	}
 	if t.full {
 		return
 	}
@@ -358,12 +370,22 @@ func (t *trace) push(sp *span) {
 		log.Error("trace buffer full (%d), dropping trace", traceMaxSize)
 		if haveTracer {
 			atomic.AddUint32(&tr.tracesDropped, 1)
+			if t.tail == tailPending {
+				tr.tailSampler.untrack(t)
+				t.tail = tailDone
+			}
 		}
 		return
 	}
 	if v, ok := sp.Metrics[keySamplingPriority]; ok {
 		t.setSamplingPriorityLocked(int(v), samplernames.Unknown)
 	}
+	if t.tail == tailNone && haveTracer && tr.tailSampler != nil {
+		t.tail = tailDone
+		if tr.tailSampler.track(t) {
+			t.tail = tailPending
+		}
+	}
 	t.spans = append(t.spans, sp)
 	if haveTracer {
 		atomic.AddUint32(&tr.spansStarted, 1)
@@ -396,8 +418,19 @@ func (t *trace) setTraceTags(s *span, tr *tracer) {
 // if enabled and the total number of finished spans is greater than or equal to the partial flush limit.
 // The provided span must be locked.
 func (t *trace) finishedOne(s *span) {
+	var (
+		tr *tracer
+		ch *chunk
+	)
 	t.mu.Lock()
-	defer t.mu.Unlock()
+	defer func() {
+		t.mu.Unlock()
+		if ch != nil {
+			// span processors may call back into the trace, so the chunk
+			// is only processed once the lock has been released.
+			t.finishChunk(tr, ch)
+		}
+	}()
 	s.finished = true
 	if t.full {
 		// capacity has been reached, the buffer is no longer tracking
@@ -406,6 +439,10 @@ func (t *trace) finishedOne(s *span) {
 		// to a race condition where spans can be modified while flushing.
 		//
 		// TODO(partialFlush): should we do a partial flush in this scenario?
+		if tr, ok := internal.GetGlobalTracer().(*tracer); ok && len(tr.config.spanProcessors) > 0 {
+			// the span won't reach finishChunk, compute its stats here
+			tr.computeStats(s)
+		}
 		return
 	}
 	t.finished++
@@ -413,12 +450,11 @@ func (t *trace) finishedOne(s *span) {
 	if !ok {
 		return
 	}
-	setPeerService(s, tr.config)
-
-	// attach the _dd.base_service tag only when the globally configured service name is different from the
-	// span service name.
-	if s.Service != "" && !strings.EqualFold(s.Service, tr.config.serviceName) {
-		s.Meta[keyBaseService] = tr.config.serviceName
+	if t.tail == tailPending && len(t.spans) == t.finished {
+		// the trace is complete, make the deferred sampling decision before the
+		// priority is locked down and the trace tags are set
+		tr.tailSampler.untrack(t)
+		t.finishTailSampling(tr, t.spans)
 	}
 	if s == t.root && t.priority != nil {
 		// after the root has finished we lock down the priority;
@@ -437,15 +473,17 @@ func (t *trace) finishedOne(s *span) {
 	}
 
 	if len(t.spans) == t.finished { // perform a full flush of all spans
-		t.finishChunk(tr, &chunk{
+		t.finished = 0
+		ch = &chunk{
 			spans:    t.spans,
 			willSend: decisionKeep == samplingDecision(atomic.LoadUint32((*uint32)(&t.samplingDecision))),
-		})
+		}
 		t.spans = nil
 		return
 	}
 
-	doPartialFlush := tr.config.partialFlushEnabled && t.finished >= tr.config.partialFlushMinSpans
+	// partial flushes are disabled while the sampling decision is deferred
+	doPartialFlush := tr.config.partialFlushEnabled && t.finished >= tr.config.partialFlushMinSpans && t.tail != tailPending
 	if !doPartialFlush {
 		return // The trace hasn't completed and partial flushing will not occur
 	}
@@ -468,17 +506,38 @@ func (t *trace) finishedOne(s *span) {
 		// Make sure the first span in the chunk has the trace-level tags
 		t.setTraceTags(finishedSpans[0], tr)
 	}
-	t.finishChunk(tr, &chunk{
+	t.finished = 0 // important, because a buffer can be used for several flushes
+	ch = &chunk{
 		spans:    finishedSpans,
 		willSend: decisionKeep == samplingDecision(atomic.LoadUint32((*uint32)(&t.samplingDecision))),
-	})
+	}
 	t.spans = leftoverSpans
 }
 
+// finishChunk runs the span processors on the chunk and sends it. It must be called
+// without t.mu held, as processors may access the trace through the spans.
 func (t *trace) finishChunk(tr *tracer, ch *chunk) {
+	if len(tr.config.spanProcessors) > 0 {
+		spans := tr.processChunk(ch.spans)
+		if len(spans) > 0 && spans[0] != ch.spans[0] {
+			// the first span of the chunk was dropped, move the trace-level tags
+			t.mu.RLock()
+			if t.priority != nil {
+				spans[0].setMetric(keySamplingPriority, *t.priority)
+			}
+			t.setTraceTags(spans[0], tr)
+			t.mu.RUnlock()
+		}
+		for _, s := range spans {
+			tr.computeStats(s)
+		}
+		if len(spans) == 0 {
+			return
+		}
+		ch.spans = spans
+	}
 	atomic.AddUint32(&tr.spansFinished, uint32(len(ch.spans)))
 	tr.pushChunk(ch)
-	t.finished = 0 // important, because a buffer can be used for several flushes
 }
 
 // setPeerService sets the peer.service, _dd.peer.service.source, and _dd.peer.service.remapped_from
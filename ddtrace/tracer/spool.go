@@ -0,0 +1,259 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// spoolFileExt is the extension of the files holding spooled payloads.
+const spoolFileExt = ".ddpayload"
+
+// spoolTmpExt is the extension of the files holding spooled payloads being written.
+const spoolTmpExt = ".tmp"
+
+// defaultSpoolMaxBytes is the size limit of the spool when none is given.
+const defaultSpoolMaxBytes = 64 << 20
+
+// errSpoolTooLarge is returned when a payload does not fit in the spool, even
+// after evicting all other payloads.
+var errSpoolTooLarge = errors.New("payload exceeds the spool size limit")
+
+// payloadSpool persists trace payloads which could not be delivered to the agent
+// into a directory, so that they can be replayed once the agent is reachable
+// again. The total size of the spool is bounded; when it is reached, the oldest
+// payloads are evicted first.
+//
+// payloadSpool is safe for concurrent use.
+type payloadSpool struct {
+	// dir is the directory holding the spooled payloads.
+	dir string
+
+	// maxBytes is the maximum total size of all spooled payloads.
+	maxBytes int64
+
+	// replaying is set to 1 while spooled payloads are being replayed.
+	replaying uint32
+
+	mu      sync.Mutex   // guards below fields
+	entries []spoolEntry // spooled payloads, oldest first
+	size    int64        // total size of entries, in bytes
+	seq     uint64       // sequence number of the newest spooled payload
+}
+
+// spoolEntry describes a payload stored in the spool.
+type spoolEntry struct {
+	path  string // path to the file holding the payload
+	size  int64  // size of the file, in bytes
+	count int    // number of traces in the payload
+}
+
+// newPayloadSpool returns a spool storing payloads in dir, creating the directory
+// if needed. Payloads left over in dir by a previous run are kept and will be
+// replayed, while partially written ones are removed. If maxBytes is not positive,
+// defaultSpoolMaxBytes is used.
+func newPayloadSpool(dir string, maxBytes int64) (*payloadSpool, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultSpoolMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &payloadSpool{dir: dir, maxBytes: maxBytes}
+	// os.ReadDir returns files sorted by name, which is the order in which they were spooled
+	for _, f := range files {
+		var seq uint64
+		var count int
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(f.Name(), spoolFileExt+spoolTmpExt) {
+			// left over by a run which stopped while writing it
+			os.Remove(filepath.Join(dir, f.Name()))
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), spoolFileExt) {
+			continue
+		}
+		if _, err := fmt.Sscanf(strings.TrimSuffix(f.Name(), spoolFileExt), "%d-%d", &seq, &count); err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		s.entries = append(s.entries, spoolEntry{
+			path:  filepath.Join(dir, f.Name()),
+			size:  info.Size(),
+			count: count,
+		})
+		s.size += info.Size()
+		if seq > s.seq {
+			s.seq = seq
+		}
+	}
+	return s, nil
+}
+
+// store writes p to the spool, evicting the oldest payloads if needed to stay within
+// the size limit. It returns the number of traces which were evicted.
+func (s *payloadSpool) store(p *payload) (evicted int, err error) {
+	b := encodeSpooledPayload(p)
+	n := int64(len(b))
+	if n > s.maxBytes {
+		return 0, errSpoolTooLarge
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.entries) > 0 && s.size+n > s.maxBytes {
+		e := s.entries[0]
+		os.Remove(e.path)
+		s.entries = s.entries[1:]
+		s.size -= e.size
+		evicted += e.count
+	}
+	s.seq++
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d-%d%s", s.seq, p.itemCount(), spoolFileExt))
+	// write to a temporary file first, so that a crash never leaves a partial payload behind
+	tmp := path + spoolTmpExt
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		os.Remove(tmp)
+		return evicted, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return evicted, err
+	}
+	s.entries = append(s.entries, spoolEntry{path: path, size: n, count: p.itemCount()})
+	s.size += n
+	return evicted, nil
+}
+
+// oldest returns the oldest payload in the spool, if any.
+func (s *payloadSpool) oldest() (e spoolEntry, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) == 0 {
+		return e, false
+	}
+	return s.entries[0], true
+}
+
+// load reads the payload described by e from disk.
+func (s *payloadSpool) load(e spoolEntry) (*payload, error) {
+	b, err := os.ReadFile(e.path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSpooledPayload(b)
+}
+
+// remove deletes the payload described by e from the spool. It is a no-op if the
+// payload was already evicted.
+func (s *payloadSpool) remove(e spoolEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.entries {
+		if v.path == e.path {
+			os.Remove(e.path)
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			s.size -= e.size
+			return
+		}
+	}
+}
+
+// depth returns the number of payloads in the spool and their total size in bytes.
+func (s *payloadSpool) depth() (payloads int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries), s.size
+}
+
+// encodeSpooledPayload encodes p for storage in the spool as a msgpack array holding
+// the payload's protocol, its number of traces, its string table (only for v0.5
+// payloads) and its encoded traces.
+func encodeSpooledPayload(p *payload) []byte {
+	var strs []string
+	if p.strings != nil {
+		strs = p.strings.list
+	}
+	b := make([]byte, 0, p.size()+32)
+	b = msgp.AppendArrayHeader(b, 4)
+	b = msgp.AppendFloat64(b, p.protocol)
+	b = msgp.AppendUint32(b, uint32(p.itemCount()))
+	b = msgp.AppendArrayHeader(b, uint32(len(strs)))
+	for _, str := range strs {
+		b = msgp.AppendString(b, str)
+	}
+	return msgp.AppendBytes(b, p.buf.Bytes())
+}
+
+// decodeSpooledPayload returns the payload encoded in b by encodeSpooledPayload.
+func decodeSpooledPayload(b []byte) (*payload, error) {
+	n, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if n != 4 {
+		return nil, fmt.Errorf("invalid spooled payload: expected 4 fields, got %d", n)
+	}
+	protocol, b, err := msgp.ReadFloat64Bytes(b)
+	if err != nil {
+		return nil, err
+	}
+	count, b, err := msgp.ReadUint32Bytes(b)
+	if err != nil {
+		return nil, err
+	}
+	var p *payload
+	switch protocol {
+	case traceProtocolV04:
+		p = newPayload()
+	case traceProtocolV05:
+		p = newPayloadV05()
+	default:
+		return nil, fmt.Errorf("invalid spooled payload: unknown protocol %v", protocol)
+	}
+	n, b, err = msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && p.strings == nil {
+		return nil, errors.New("invalid spooled payload: unexpected string table")
+	}
+	for i := uint32(0); i < n; i++ {
+		var str string
+		str, b, err = msgp.ReadStringBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			// the empty string at index 0 is already part of a new string table
+			p.strings.add(str)
+		}
+	}
+	body, _, err := msgp.ReadBytesZC(b)
+	if err != nil {
+		return nil, err
+	}
+	p.buf.Write(body)
+	atomic.StoreUint32(&p.count, count)
+	p.updateHeader()
+	return p, nil
+}
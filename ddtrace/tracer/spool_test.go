@@ -0,0 +1,222 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadSpool(t *testing.T) {
+	t.Run("roundtrip", func(t *testing.T) {
+		for name, newp := range map[string]func() *payload{
+			"v0.4": newPayload,
+			"v0.5": newPayloadV05,
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert := assert.New(t)
+				s, err := newPayloadSpool(t.TempDir(), 1<<20)
+				assert.NoError(err)
+				p := newp()
+				for i := 0; i < 3; i++ {
+					p.push(newSpanList(i + 1))
+				}
+				want, err := io.ReadAll(p)
+				assert.NoError(err)
+
+				evicted, err := s.store(p)
+				assert.NoError(err)
+				assert.Zero(evicted)
+				e, ok := s.oldest()
+				assert.True(ok)
+				assert.Equal(3, e.count)
+
+				got, err := s.load(e)
+				assert.NoError(err)
+				assert.Equal(p.protocol, got.protocol)
+				assert.Equal(3, got.itemCount())
+				assert.Equal(len(want), got.size())
+				b, err := io.ReadAll(got)
+				assert.NoError(err)
+				assert.Equal(want, b)
+
+				s.remove(e)
+				n, size := s.depth()
+				assert.Zero(n)
+				assert.Zero(size)
+				_, ok = s.oldest()
+				assert.False(ok)
+			})
+		}
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		assert := assert.New(t)
+		p := newPayload()
+		p.push(newSpanList(2))
+		size := int64(len(encodeSpooledPayload(p)))
+
+		s, err := newPayloadSpool(t.TempDir(), 2*size)
+		assert.NoError(err)
+		var evicted []int
+		for i := 0; i < 3; i++ {
+			n, err := s.store(p)
+			assert.NoError(err)
+			evicted = append(evicted, n)
+		}
+		assert.Equal([]int{0, 0, 1}, evicted)
+		n, bytes := s.depth()
+		assert.Equal(2, n)
+		assert.Equal(2*size, bytes)
+		// the first payload was evicted, so the second one is now the oldest
+		e, _ := s.oldest()
+		assert.Contains(e.path, "00000000000000000002-1")
+		_, err = os.Stat(filepath.Join(s.dir, "00000000000000000001-1"+spoolFileExt))
+		assert.True(os.IsNotExist(err))
+
+		small, err := newPayloadSpool(t.TempDir(), size-1)
+		assert.NoError(err)
+		_, err = small.store(p)
+		assert.Equal(errSpoolTooLarge, err)
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		assert := assert.New(t)
+		dir := t.TempDir()
+		s, err := newPayloadSpool(dir, 1<<20)
+		assert.NoError(err)
+		for i := 1; i <= 3; i++ {
+			p := newPayload()
+			for j := 0; j < i; j++ {
+				p.push(newSpanList(1))
+			}
+			_, err := s.store(p)
+			assert.NoError(err)
+		}
+		// unrelated files are ignored, and partially written payloads removed
+		assert.NoError(os.WriteFile(filepath.Join(dir, "other.txt"), []byte("x"), 0600))
+		tmp := filepath.Join(dir, "00000000000000000009-1"+spoolFileExt+spoolTmpExt)
+		assert.NoError(os.WriteFile(tmp, []byte("x"), 0600))
+
+		s2, err := newPayloadSpool(dir, 1<<20)
+		assert.NoError(err)
+		n, bytes := s.depth()
+		n2, bytes2 := s2.depth()
+		assert.Equal(n, n2)
+		assert.Equal(bytes, bytes2)
+		for i := 1; i <= 3; i++ {
+			e, ok := s2.oldest()
+			assert.True(ok)
+			assert.Equal(i, e.count)
+			s2.remove(e)
+		}
+		_, err = s2.store(newPayload())
+		assert.NoError(err)
+		e, _ := s2.oldest()
+		assert.Contains(e.path, "00000000000000000004-0")
+		_, err = os.Stat(tmp)
+		assert.True(os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, "other.txt"))
+		assert.NoError(err)
+	})
+
+	t.Run("default-size", func(t *testing.T) {
+		s, err := newPayloadSpool(t.TempDir(), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(defaultSpoolMaxBytes), s.maxBytes)
+		_, err = s.store(newPayload())
+		assert.NoError(t, err)
+	})
+}
+
+func TestTraceWriterSpool(t *testing.T) {
+	assert := assert.New(t)
+	p := &failingTransport{
+		failCount: 2,
+		assert:    assert,
+	}
+	c := newConfig(func(c *config) {
+		c.transport = p
+	}, WithPayloadSpool(t.TempDir(), 1<<20))
+	var statsd testStatsdClient
+	h := newAgentTraceWriter(c, nil, &statsd)
+	assert.NotNil(h.spool)
+
+	// the first two payloads fail to send and are spooled
+	ss := []*span{makeSpan(0)}
+	for i := 0; i < 2; i++ {
+		h.add(ss)
+		h.flush()
+		h.wg.Wait()
+	}
+	n, _ := h.spool.depth()
+	assert.Equal(2, n)
+	assert.False(p.tracesSent)
+
+	// once sending succeeds, the spooled payloads are replayed
+	h.add(ss)
+	h.flush()
+	h.wg.Wait()
+	h.replayWg.Wait()
+	n, bytes := h.spool.depth()
+	assert.Zero(n)
+	assert.Zero(bytes)
+	assert.Equal(5, p.sendAttempts)
+
+	counts := statsd.Counts()
+	assert.Equal(int64(2), counts["datadog.tracer.traces_spooled"])
+	assert.Equal(int64(3), counts["datadog.tracer.flush_traces"])
+	assert.Zero(counts["datadog.tracer.traces_dropped"])
+}
+
+// slowTransport is a transport whose uploads succeed after the given delay.
+type slowTransport struct {
+	dummyTransport
+	delay time.Duration
+	sends int32
+}
+
+func (t *slowTransport) send(p *payload) (io.ReadCloser, error) {
+	atomic.AddInt32(&t.sends, 1)
+	time.Sleep(t.delay)
+	return io.NopCloser(strings.NewReader("OK")), nil
+}
+
+func TestTraceWriterSpoolStop(t *testing.T) {
+	assert := assert.New(t)
+	transport := &slowTransport{delay: 20 * time.Millisecond}
+	c := newConfig(func(c *config) {
+		c.transport = transport
+	}, WithPayloadSpool(t.TempDir(), 1<<20))
+	h := newAgentTraceWriter(c, nil, &testStatsdClient{})
+	for i := 0; i < 50; i++ {
+		p := newPayload()
+		p.push(newSpanList(1))
+		_, err := h.spool.store(p)
+		assert.NoError(err)
+	}
+
+	// the replay runs in the background, without holding up the flush
+	h.add([]*span{makeSpan(0)})
+	h.flush()
+	h.wg.Wait()
+	assert.Eventually(func() bool { return atomic.LoadInt32(&transport.sends) > 1 }, time.Second, time.Millisecond)
+
+	// stopping the writer interrupts the replay, leaving the remaining payloads spooled
+	start := time.Now()
+	h.stop()
+	assert.Less(time.Since(start), time.Second)
+	n, _ := h.spool.depth()
+	assert.NotZero(n)
+	assert.Less(n, 50)
+}
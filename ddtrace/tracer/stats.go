@@ -8,10 +8,15 @@
 package tracer
 
 import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
@@ -30,6 +35,10 @@ type aggregableSpan struct {
 	Start, Duration int64
 	Error           int32
 	TopLevel        bool
+
+	// PeerTags holds the peer tags of the span, as "key:value" pairs sorted by key.
+	// Their hash is part of the aggregation key.
+	PeerTags []string
 }
 
 // defaultStatsBucketSize specifies the default span of time that will be
@@ -60,6 +69,7 @@ type concentrator struct {
 	stop         chan struct{}         // closing this channel triggers shutdown
 	cfg          *config               // tracer startup configuration
 	statsdClient internal.StatsdClient // statsd client for sending metrics.
+	red          *redMetrics           // exports flushed buckets as RED metrics, when enabled
 }
 
 // newConcentrator creates a new concentrator using the given tracer
@@ -177,7 +187,7 @@ const (
 // flushAndSend flushes all the stats buckets with the given timestamp and sends them using the transport specified in
 // the concentrator config. The current bucket is only included if includeCurrent is true, such as during shutdown.
 func (c *concentrator) flushAndSend(timenow time.Time, includeCurrent bool) {
-	sp := func() statsPayload {
+	sp, flushed := func() (statsPayload, []*rawBucket) {
 		c.mu.Lock()
 		defer c.mu.Unlock()
 		now := timenow.UnixNano()
@@ -187,6 +197,7 @@ func (c *concentrator) flushAndSend(timenow time.Time, includeCurrent bool) {
 			Version:  c.cfg.version,
 			Stats:    make([]statsBucket, 0, len(c.buckets)),
 		}
+		var flushed []*rawBucket
 		for ts, srb := range c.buckets {
 			if !includeCurrent && ts > now-c.bucketSize {
 				// do not flush the current bucket
@@ -194,32 +205,53 @@ func (c *concentrator) flushAndSend(timenow time.Time, includeCurrent bool) {
 			}
 			log.Debug("Flushing bucket %d", ts)
 			sp.Stats = append(sp.Stats, srb.Export())
+			flushed = append(flushed, srb)
 			delete(c.buckets, ts)
 		}
-		return sp
+		return sp, flushed
 	}()
 
+	if c.red != nil {
+		for _, srb := range flushed {
+			c.red.export(srb)
+		}
+		if !c.cfg.canComputeStats() {
+			// stats are only computed for RED metrics
+			return
+		}
+	}
 	if len(sp.Stats) == 0 {
 		// nothing to flush
 		return
 	}
+	if !c.cfg.agentBreaker.allow() {
+		c.statsd().Incr("datadog.tracer.stats.flush_errors", nil, 1)
+		log.Error("Error sending stats payload: %v", errAgentOverloaded)
+		return
+	}
 	c.statsd().Incr("datadog.tracer.stats.flush_payloads", nil, 1)
 	c.statsd().Incr("datadog.tracer.stats.flush_buckets", nil, float64(len(sp.Stats)))
 	if err := c.cfg.transport.sendStats(&sp); err != nil {
+		c.cfg.agentBreaker.failure(err)
 		c.statsd().Incr("datadog.tracer.stats.flush_errors", nil, 1)
 		log.Error("Error sending stats payload: %v", err)
+	} else {
+		c.cfg.agentBreaker.success()
 	}
 }
 
 // aggregation specifies a uniquely identifiable key under which a certain set
 // of stats are grouped inside a bucket.
 type aggregation struct {
-	Name       string
-	Type       string
-	Resource   string
-	Service    string
-	StatusCode uint32
-	Synthetics bool
+	Name           string
+	Type           string
+	Resource       string
+	Service        string
+	StatusCode     uint32
+	Synthetics     bool
+	SpanKind       string
+	GRPCStatusCode string
+	PeerTagsHash   uint64
 }
 
 type rawBucket struct {
@@ -239,6 +271,7 @@ func (sb *rawBucket) handleSpan(s *aggregableSpan) {
 	gs, ok := sb.data[s.key]
 	if !ok {
 		gs = newRawGroupedStats()
+		gs.peerTags = s.PeerTags
 		sb.data[s.key] = gs
 	}
 	if s.TopLevel {
@@ -285,6 +318,7 @@ type rawGroupedStats struct {
 	duration        uint64
 	okDistribution  *ddsketch.DDSketch
 	errDistribution *ddsketch.DDSketch
+	peerTags        []string // peer tags of the aggregation, whose key only holds a hash
 }
 
 func newRawGroupedStats() *rawGroupedStats {
@@ -335,9 +369,119 @@ func (s *rawGroupedStats) export(k aggregation) (groupedStats, error) {
 		OkSummary:      okSummary,
 		ErrorSummary:   errSummary,
 		Synthetics:     k.Synthetics,
+		SpanKind:       k.SpanKind,
+		PeerTags:       s.peerTags,
+		GRPCStatusCode: k.GRPCStatusCode,
 	}, nil
 }
 
+// statsPeerTags lists the tags identifying the destination of outbound calls, by which
+// client-computed stats are broken down. It holds peer.service and the tags it may be
+// derived from.
+var statsPeerTags = []string{
+	keyBaseService,
+	ext.PeerService,
+	ext.PeerHostname,
+	ext.TargetHost,
+	ext.NetworkDestinationName,
+	ext.DBInstance,
+	ext.DBName,
+	ext.DBSystem,
+	ext.CassandraContactPoints,
+	ext.KafkaBootstrapServers,
+	ext.RPCService,
+	ext.RPCSystem,
+	"queuename",
+	"topicname",
+	"streamname",
+	"tablename",
+	"bucketname",
+}
+
+func init() {
+	sort.Strings(statsPeerTags)
+}
+
+// peerTags returns the peer tags of the span s as "key:value" pairs sorted by key, along
+// with their hash. Peer tags are only collected for client, producer and consumer spans.
+func peerTags(s *span) ([]string, uint64) {
+	switch s.Meta[ext.SpanKind] {
+	case ext.SpanKindClient, ext.SpanKindProducer, ext.SpanKindConsumer:
+	default:
+		return nil, 0
+	}
+	var tags []string
+	h := fnv.New64a()
+	for _, k := range statsPeerTags {
+		v, ok := s.Meta[k]
+		if !ok || v == "" {
+			continue
+		}
+		t := k + ":" + v
+		tags = append(tags, t)
+		h.Write([]byte(t))
+		h.Write([]byte{0})
+	}
+	if len(tags) == 0 {
+		return nil, 0
+	}
+	return tags, h.Sum64()
+}
+
+// grpcStatusCodeTags lists the tags which may hold the gRPC status code of a span, by priority.
+var grpcStatusCodeTags = []string{
+	"rpc.grpc.status_code",
+	"grpc.code",
+	"rpc.grpc.status.code",
+	"grpc.status.code",
+}
+
+// grpcStatusCodes maps the names of the gRPC status codes, in upper case and without
+// underscores, to their numeric values.
+var grpcStatusCodes = map[string]string{
+	"OK":                 "0",
+	"CANCELED":           "1",
+	"CANCELLED":          "1",
+	"UNKNOWN":            "2",
+	"INVALIDARGUMENT":    "3",
+	"DEADLINEEXCEEDED":   "4",
+	"NOTFOUND":           "5",
+	"ALREADYEXISTS":      "6",
+	"PERMISSIONDENIED":   "7",
+	"RESOURCEEXHAUSTED":  "8",
+	"FAILEDPRECONDITION": "9",
+	"ABORTED":            "10",
+	"OUTOFRANGE":         "11",
+	"UNIMPLEMENTED":      "12",
+	"INTERNAL":           "13",
+	"UNAVAILABLE":        "14",
+	"DATALOSS":           "15",
+	"UNAUTHENTICATED":    "16",
+}
+
+// grpcStatusCode returns the numeric gRPC status code of the span s, or an empty string
+// if it has none. Codes may be set either as numbers or as names, such as "NotFound".
+func grpcStatusCode(s *span) string {
+	for _, k := range grpcStatusCodeTags {
+		v, ok := s.Meta[k]
+		if !ok {
+			if f, ok := s.Metrics[k]; ok {
+				return strconv.Itoa(int(f))
+			}
+			continue
+		}
+		if _, err := strconv.ParseUint(v, 10, 32); err == nil {
+			return v
+		}
+		name := strings.ToUpper(strings.ReplaceAll(strings.TrimPrefix(v, "StatusCode."), "_", ""))
+		if code, ok := grpcStatusCodes[name]; ok {
+			return code
+		}
+		return ""
+	}
+	return ""
+}
+
 // nsTimestampToFloat converts a nanosec timestamp into a float nanosecond timestamp truncated to a fixed precision
 func nsTimestampToFloat(ns int64) float64 {
 	// 10 bits precision (any value will be +/- 1/1024)
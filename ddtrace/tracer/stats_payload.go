@@ -53,4 +53,10 @@ type groupedStats struct {
 	ErrorSummary []byte `json:"errorSummary,omitempty"`
 	Synthetics   bool   `json:"synthetics,omitempty"`
 	TopLevelHits uint64 `json:"topLevelHits,omitempty"`
+
+	// These fields are additional aggregation properties, used to break down the
+	// stats of outbound calls by destination.
+	SpanKind       string   `json:"span_kind,omitempty"`
+	PeerTags       []string `json:"peer_tags,omitempty"`
+	GRPCStatusCode string   `json:"GRPC_status_code,omitempty"`
 }
@@ -0,0 +1,298 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/DataDog/sketches-go/ddsketch"
+)
+
+const (
+	// redMetricsPrefix prefixes the names of the DogStatsD RED (rate, errors, duration)
+	// metrics exported from the stats concentrator.
+	redMetricsPrefix = "trace.red."
+
+	// redPrometheusPrefix prefixes the names of the RED metrics in the Prometheus
+	// text format.
+	redPrometheusPrefix = "trace_red_"
+
+	// maxREDSeries bounds the number of aggregations for which cumulative metrics are
+	// kept to be exposed by REDMetricsHandler. Further aggregations are only exported
+	// to DogStatsD.
+	maxREDSeries = 5000
+)
+
+// redMetricsQuantiles holds the quantiles of the span durations exposed by
+// REDMetricsHandler.
+var redMetricsQuantiles = []float64{0.5, 0.95, 0.99}
+
+// redMetrics exports the stats buckets flushed by the concentrator as local RED metrics.
+// Hits, errors and durations are sent to DogStatsD, and accumulated since the tracer
+// started to be exposed in the Prometheus text format by REDMetricsHandler.
+type redMetrics struct {
+	statsd globalinternal.StatsdClient
+
+	mu      sync.Mutex                       // guards below fields
+	series  map[aggregation]*rawGroupedStats // cumulative stats, by aggregation
+	dropped bool                             // reports whether aggregations were dropped due to maxREDSeries
+}
+
+func newREDMetrics(statsd globalinternal.StatsdClient) *redMetrics {
+	return &redMetrics{
+		statsd: statsd,
+		series: make(map[aggregation]*rawGroupedStats),
+	}
+}
+
+// export exports the stats of the flushed bucket b. Span durations are sent as
+// distributions of the values held by the bucket's sketches: each bin is sent once,
+// with a sample rate of 1/count so that it is weighted by the number of spans it
+// holds, and the number of values sent doesn't depend on the number of spans. As
+// the client samples values sent with a rate below 1, the counts are only preserved
+// on average.
+func (m *redMetrics) export(b *rawBucket) {
+	for k, gs := range b.data {
+		tags := redTags(k, gs.peerTags)
+		m.statsd.Count(redMetricsPrefix+"hits", int64(gs.hits), tags, 1)
+		m.statsd.Count(redMetricsPrefix+"errors", int64(gs.errors), tags, 1)
+		for _, sketch := range []*ddsketch.DDSketch{gs.okDistribution, gs.errDistribution} {
+			if sketch == nil {
+				continue
+			}
+			sketch.ForEach(func(value, count float64) bool {
+				if count > 0 {
+					m.statsd.Distribution(redMetricsPrefix+"duration", value/1e9, tags, 1/count)
+				}
+				return false
+			})
+		}
+		m.accumulate(k, gs)
+	}
+}
+
+// durationQuantiles returns the redMetricsQuantiles of the durations of the spans
+// aggregated in gs, in seconds, or nil if there are none.
+func durationQuantiles(gs *rawGroupedStats) []float64 {
+	if gs.okDistribution == nil || gs.errDistribution == nil {
+		return nil
+	}
+	sketch := gs.okDistribution.Copy()
+	mergeSketch(sketch, gs.errDistribution)
+	if sketch.IsEmpty() {
+		return nil
+	}
+	qs, err := sketch.GetValuesAtQuantiles(redMetricsQuantiles)
+	if err != nil {
+		return nil
+	}
+	for i := range qs {
+		qs[i] /= 1e9
+	}
+	return qs
+}
+
+// accumulate adds the stats gs of the aggregation k to the cumulative ones.
+func (m *redMetrics) accumulate(k aggregation, gs *rawGroupedStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[k]
+	if !ok {
+		if len(m.series) >= maxREDSeries {
+			if !m.dropped {
+				log.Warn("RED metrics: more than %d aggregations, further ones are not exposed in the Prometheus format.", maxREDSeries)
+				m.dropped = true
+			}
+			return
+		}
+		s = newRawGroupedStats()
+		s.peerTags = gs.peerTags
+		m.series[k] = s
+	}
+	s.hits += gs.hits
+	s.topLevelHits += gs.topLevelHits
+	s.errors += gs.errors
+	s.duration += gs.duration
+	mergeSketch(s.okDistribution, gs.okDistribution)
+	mergeSketch(s.errDistribution, gs.errDistribution)
+}
+
+// mergeSketch merges the values of the sketch from into the sketch into.
+func mergeSketch(into, from *ddsketch.DDSketch) {
+	if into == nil || from == nil {
+		return
+	}
+	if err := into.MergeWith(from); err != nil {
+		log.Error("RED metrics: could not merge sketches: %v", err)
+	}
+}
+
+// redSample holds the cumulative metrics of an aggregation, as exposed in the
+// Prometheus text format.
+type redSample struct {
+	labels    [][2]string
+	key       string // formatted labels
+	hits      uint64
+	errors    uint64
+	duration  float64   // total duration, in seconds
+	quantiles []float64 // by redMetricsQuantiles, nil when no durations were recorded
+}
+
+// samples returns the cumulative metrics of all aggregations, sorted by labels.
+func (m *redMetrics) samples() []redSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	samples := make([]redSample, 0, len(m.series))
+	for k, s := range m.series {
+		labels := redLabels(k, s.peerTags)
+		samples = append(samples, redSample{
+			labels:    labels,
+			key:       prometheusLabels(labels),
+			hits:      s.hits,
+			errors:    s.errors,
+			duration:  float64(s.duration) / 1e9,
+			quantiles: durationQuantiles(s),
+		})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].key < samples[j].key
+	})
+	return samples
+}
+
+// writePrometheus writes the cumulative metrics of all aggregations to w in the
+// Prometheus text format.
+func (m *redMetrics) writePrometheus(w io.Writer) error {
+	samples := m.samples()
+	var b strings.Builder
+	family := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	family(redPrometheusPrefix+"hits_total", "counter", "Number of spans measured by the tracer.")
+	for _, s := range samples {
+		fmt.Fprintf(&b, "%shits_total%s %d\n", redPrometheusPrefix, s.key, s.hits)
+	}
+	family(redPrometheusPrefix+"errors_total", "counter", "Number of erroneous spans measured by the tracer.")
+	for _, s := range samples {
+		fmt.Fprintf(&b, "%serrors_total%s %d\n", redPrometheusPrefix, s.key, s.errors)
+	}
+	family(redPrometheusPrefix+"duration_seconds", "summary", "Duration of the spans measured by the tracer.")
+	for _, s := range samples {
+		for i, v := range s.quantiles {
+			q := strconv.FormatFloat(redMetricsQuantiles[i], 'g', -1, 64)
+			labels := append(s.labels[:len(s.labels):len(s.labels)], [2]string{"quantile", q})
+			fmt.Fprintf(&b, "%sduration_seconds%s %s\n", redPrometheusPrefix, prometheusLabels(labels), formatFloat(v))
+		}
+		fmt.Fprintf(&b, "%sduration_seconds_sum%s %s\n", redPrometheusPrefix, s.key, formatFloat(s.duration))
+		fmt.Fprintf(&b, "%sduration_seconds_count%s %d\n", redPrometheusPrefix, s.key, s.hits)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// redLabels returns the names and values of the labels identifying the aggregation k
+// having the given peer tags. Empty values are omitted.
+func redLabels(k aggregation, peerTags []string) [][2]string {
+	var labels [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			labels = append(labels, [2]string{name, value})
+		}
+	}
+	add("service", k.Service)
+	add("operation", k.Name)
+	add("resource", k.Resource)
+	add("type", k.Type)
+	add("span.kind", k.SpanKind)
+	if k.StatusCode != 0 {
+		add("http.status_code", strconv.FormatUint(uint64(k.StatusCode), 10))
+	}
+	add("grpc.status_code", k.GRPCStatusCode)
+	if k.Synthetics {
+		add("synthetics", "true")
+	}
+	for _, t := range peerTags {
+		if name, value, ok := strings.Cut(t, ":"); ok {
+			add(name, value)
+		}
+	}
+	return labels
+}
+
+// redTags returns the DogStatsD tags identifying the aggregation k having the given
+// peer tags.
+func redTags(k aggregation, peerTags []string) []string {
+	labels := redLabels(k, peerTags)
+	tags := make([]string, len(labels))
+	for i, l := range labels {
+		tags[i] = l[0] + ":" + l[1]
+	}
+	return tags
+}
+
+var prometheusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusLabels formats the given labels in the Prometheus text format, replacing
+// the characters which are invalid in label names with underscores.
+func prometheusLabels(labels [][2]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		for j, r := range l[0] {
+			switch {
+			case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', j > 0 && '0' <= r && r <= '9':
+				b.WriteRune(r)
+			default:
+				b.WriteByte('_')
+			}
+		}
+		b.WriteString(`="`)
+		prometheusEscaper.WriteString(&b, l[1])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// formatFloat formats v as a Prometheus sample value.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// REDMetricsHandler returns an http.Handler exposing the RED (rate, errors, duration)
+// metrics computed from the spans of the started tracer, in the Prometheus text format.
+// They are cumulated since the tracer started and broken down by service, operation,
+// resource, span kind, status codes and peer tags, like the stats computed for the agent.
+// The metrics are only collected when the tracer is started with WithREDMetrics, and are
+// updated each time the stats buckets are flushed, every 10 seconds.
+func REDMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		t, ok := internal.GetGlobalTracer().(*tracer)
+		if !ok || t.stats.red == nil {
+			return
+		}
+		if err := t.stats.red.writePrometheus(w); err != nil {
+			log.Debug("RED metrics: error writing response: %v", err)
+		}
+	})
+}
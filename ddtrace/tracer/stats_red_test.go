@@ -0,0 +1,142 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestREDMetrics(t *testing.T) {
+	key := aggregation{
+		Service:    "web",
+		Name:       "http.request",
+		Resource:   `GET "/users"`,
+		StatusCode: 200,
+		SpanKind:   ext.SpanKindClient,
+	}
+	newSpan := func(d time.Duration, isError bool) *aggregableSpan {
+		s := &aggregableSpan{
+			key:      key,
+			Start:    time.Now().UnixNano(),
+			Duration: d.Nanoseconds(),
+			PeerTags: []string{"peer.service:users"},
+		}
+		if isError {
+			s.Error = 1
+		}
+		return s
+	}
+
+	t.Run("export", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		transport := newDummyTransport()
+		c := newConcentrator(&config{transport: transport}, defaultStatsBucketSize)
+		c.red = newREDMetrics(&tg)
+		c.add(newSpan(10*time.Millisecond, false))
+		c.add(newSpan(10*time.Millisecond, false))
+		c.add(newSpan(time.Second, true))
+		c.flushAndSend(time.Now(), withCurrentBucket)
+
+		// stats are not sent to an agent which does not support them
+		assert.Empty(transport.Stats())
+		assert.EqualValues(3, tg.Counts()["trace.red.hits"])
+		assert.EqualValues(1, tg.Counts()["trace.red.errors"])
+		// one value is sent per sketch bin, weighted by its count
+		dist := tg.DistributionCalls()
+		require.Len(t, dist, 2)
+		sort.Slice(dist, func(i, j int) bool { return dist[i].floatVal < dist[j].floatVal })
+		for _, d := range dist {
+			assert.Equal("trace.red.duration", d.name)
+			assert.ElementsMatch(
+				[]string{"service:web", "operation:http.request", `resource:GET "/users"`, "span.kind:client", "http.status_code:200", "peer.service:users"},
+				d.tags,
+			)
+		}
+		assert.InDelta(0.01, dist[0].floatVal, 0.001)
+		assert.Equal(0.5, dist[0].rate)
+		assert.InDelta(1, dist[1].floatVal, 0.01)
+		assert.Equal(1., dist[1].rate)
+
+		// metrics are cumulated across flushes
+		c.add(newSpan(10*time.Millisecond, false))
+		c.flushAndSend(time.Now(), withCurrentBucket)
+		var b strings.Builder
+		require.NoError(t, c.red.writePrometheus(&b))
+		out := b.String()
+		labels := `{service="web",operation="http.request",resource="GET \"/users\"",span_kind="client",http_status_code="200",peer_service="users"}`
+		assert.Contains(out, "# TYPE trace_red_hits_total counter\n")
+		assert.Contains(out, "trace_red_hits_total"+labels+" 4\n")
+		assert.Contains(out, "trace_red_errors_total"+labels+" 1\n")
+		assert.Contains(out, "# TYPE trace_red_duration_seconds summary\n")
+		assert.Contains(out, `trace_red_duration_seconds{service="web",operation="http.request",resource="GET \"/users\"",span_kind="client",http_status_code="200",peer_service="users",quantile="0.5"}`)
+		assert.Contains(out, "trace_red_duration_seconds_sum"+labels+" 1.03\n")
+		assert.Contains(out, "trace_red_duration_seconds_count"+labels+" 4\n")
+	})
+
+	t.Run("quantiles", func(t *testing.T) {
+		assert := assert.New(t)
+		gs := newRawGroupedStats()
+		assert.Nil(durationQuantiles(gs))
+		for i := 0; i < 90; i++ {
+			gs.okDistribution.Add(float64(10 * time.Millisecond))
+		}
+		for i := 0; i < 10; i++ {
+			gs.errDistribution.Add(float64(time.Second))
+		}
+		assert.InDeltaSlice([]float64{0.01, 1, 1}, durationQuantiles(gs), 0.01)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		assert := assert.New(t)
+		var tg testStatsdClient
+		tracer, _, _, stop := startTestTracer(t, WithREDMetrics(true), withStatsdClient(&tg))
+		defer stop()
+
+		span := tracer.StartSpan("db.query", ServiceName("users-db"), ResourceName("SELECT 1"), Measured())
+		span.Finish(WithError(errors.New("timeout")))
+		assert.Eventually(func() bool {
+			tracer.stats.flushAndSend(time.Now(), withCurrentBucket)
+			return tg.Counts()["trace.red.hits"] == 1
+		}, time.Second*timeMultiplicator, 10*time.Millisecond)
+
+		rec := httptest.NewRecorder()
+		REDMetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal("text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+		body, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		assert.Contains(string(body), `trace_red_errors_total{service="users-db",operation="db.query",resource="SELECT 1"} 1`)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+		assert.Nil(t, tracer.stats.red)
+
+		rec := httptest.NewRecorder()
+		REDMetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Empty(t, rec.Body.String())
+	})
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	assert.Equal(t, "", prometheusLabels(nil))
+	assert.Equal(t,
+		`{_dd_base_service="a\\b",_x="line\nbreak"}`,
+		prometheusLabels([][2]string{{"_dd.base_service", `a\b`}, {"1x", "line\nbreak"}}),
+	)
+}
@@ -6,11 +6,15 @@
 package tracer
 
 import (
+	"bytes"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/tinylib/msgp/msgp"
 )
 
 // waitForBuckets reports whether concentrator c contains n buckets within a 5ms
@@ -177,3 +181,94 @@ func TestConcentrator(t *testing.T) {
 		})
 	})
 }
+
+func TestStatsAggregationDimensions(t *testing.T) {
+	newSpan := func(meta map[string]string) *span {
+		s := newBasicSpan("client.request")
+		for k, v := range meta {
+			s.setMeta(k, v)
+		}
+		return s
+	}
+
+	t.Run("peer-tags", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newSpan(map[string]string{
+			ext.SpanKind:    ext.SpanKindClient,
+			ext.PeerService: "users-db",
+			ext.DBInstance:  "users",
+			ext.TargetHost:  "10.0.0.1",
+			"http.url":      "ignored",
+		})
+		as := newAggregableSpan(s, nil)
+		assert.Equal(ext.SpanKindClient, as.key.SpanKind)
+		assert.Equal([]string{"db.instance:users", "out.host:10.0.0.1", "peer.service:users-db"}, as.PeerTags)
+		assert.NotZero(as.key.PeerTagsHash)
+
+		other := newAggregableSpan(newSpan(map[string]string{
+			ext.SpanKind:    ext.SpanKindClient,
+			ext.PeerService: "orders-db",
+		}), nil)
+		assert.NotEqual(as.key, other.key)
+	})
+
+	t.Run("server", func(t *testing.T) {
+		s := newSpan(map[string]string{
+			ext.SpanKind:    ext.SpanKindServer,
+			ext.PeerService: "users-db",
+		})
+		as := newAggregableSpan(s, nil)
+		assert.Equal(t, ext.SpanKindServer, as.key.SpanKind)
+		assert.Nil(t, as.PeerTags)
+		assert.Zero(t, as.key.PeerTagsHash)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		for in, want := range map[string]string{
+			"0":                   "0",
+			"14":                  "14",
+			"NotFound":            "5",
+			"DEADLINE_EXCEEDED":   "4",
+			"StatusCode.CANCELED": "1",
+			"bogus":               "",
+		} {
+			s := newSpan(map[string]string{"grpc.code": in})
+			assert.Equal(t, want, newAggregableSpan(s, nil).key.GRPCStatusCode, in)
+		}
+		s := newBasicSpan("grpc.client")
+		s.setMetric("rpc.grpc.status_code", 7)
+		assert.Equal(t, "7", grpcStatusCode(s))
+		assert.Equal(t, "", grpcStatusCode(newBasicSpan("http.request")))
+	})
+
+	t.Run("export", func(t *testing.T) {
+		assert := assert.New(t)
+		s := newSpan(map[string]string{
+			ext.SpanKind:    ext.SpanKindProducer,
+			ext.PeerService: "queue",
+			"grpc.code":     "Unavailable",
+		})
+		b := newRawBucket(0, defaultStatsBucketSize)
+		b.handleSpan(newAggregableSpan(s, nil))
+		b.handleSpan(newAggregableSpan(s, nil))
+		var gs []groupedStats
+		for _, g := range b.Export().Stats {
+			if g.Hits > 0 {
+				gs = append(gs, g)
+			}
+		}
+		assert.Len(gs, 1)
+		assert.Equal(uint64(2), gs[0].Hits)
+		assert.Equal(ext.SpanKindProducer, gs[0].SpanKind)
+		assert.Equal([]string{"peer.service:queue"}, gs[0].PeerTags)
+		assert.Equal("14", gs[0].GRPCStatusCode)
+
+		// the new fields are encoded in the payload
+		var buf bytes.Buffer
+		assert.NoError(msgp.Encode(&buf, &gs[0]))
+		assert.LessOrEqual(buf.Len(), gs[0].Msgsize())
+		var got groupedStats
+		assert.NoError(msgp.Decode(&buf, &got))
+		assert.Equal(gs[0], got)
+	})
+}
@@ -0,0 +1,278 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+const (
+	// defaultTailSamplingMaxTraces is the default maximum number of traces for which
+	// the sampling decision can be deferred at any given time.
+	defaultTailSamplingMaxTraces = 10000
+
+	// defaultTailSamplingTimeout is the default maximum amount of time for which
+	// the sampling decision of a trace can be deferred.
+	defaultTailSamplingTimeout = 30 * time.Second
+)
+
+// TailSamplingPredicate decides whether a trace should be kept once all of its
+// local spans have finished. A trace is kept when any of its spans matches any
+// of the configured predicates. Predicates are created using TailSampleErrors,
+// TailSampleSlowerThan and TailSampleTag.
+type TailSamplingPredicate struct {
+	name  string
+	match func(s *span) bool
+}
+
+// String returns a description of the predicate.
+func (p TailSamplingPredicate) String() string {
+	return p.name
+}
+
+// TailSampleErrors returns a predicate matching traces which contain a span
+// with an error.
+func TailSampleErrors() TailSamplingPredicate {
+	return TailSamplingPredicate{
+		name: "error",
+		match: func(s *span) bool {
+			return s.Error != 0
+		},
+	}
+}
+
+// TailSampleSlowerThan returns a predicate matching traces which contain a span
+// lasting at least d.
+func TailSampleSlowerThan(d time.Duration) TailSamplingPredicate {
+	return TailSamplingPredicate{
+		name: fmt.Sprintf("duration>=%s", d),
+		match: func(s *span) bool {
+			return s.Duration >= int64(d)
+		},
+	}
+}
+
+// TailSampleTag returns a predicate matching traces which contain a span having
+// the tag key set to value. An empty value matches any value of the tag.
+func TailSampleTag(key, value string) TailSamplingPredicate {
+	return TailSamplingPredicate{
+		name: fmt.Sprintf("tag:%s=%s", key, value),
+		match: func(s *span) bool {
+			if v, ok := s.Meta[key]; ok {
+				return value == "" || v == value
+			}
+			if v, ok := s.Metrics[key]; ok {
+				return value == "" || strconv.FormatFloat(v, 'f', -1, 64) == value
+			}
+			return false
+		},
+	}
+}
+
+// tailState reports whether the sampling decision of a trace is deferred.
+type tailState uint8
+
+const (
+	// tailNone means that the trace was not yet considered for tail sampling.
+	tailNone tailState = iota
+	// tailPending means that the sampling decision is deferred until the trace completes.
+	tailPending
+	// tailDone means that the trace is not, or no longer, subject to tail sampling.
+	tailDone
+)
+
+// tailSampler defers the sampling decision of traces until all of their local
+// spans have finished, so that it can be based on their contents. To bound the
+// memory used, at most maxTraces can be pending at once, and the finished spans
+// of traces still pending after timeout are flushed using the head-based
+// sampling decision, unless they match a predicate.
+type tailSampler struct {
+	predicates []TailSamplingPredicate
+	maxTraces  int
+	timeout    time.Duration
+	statsd     internal.StatsdClient
+
+	mu      sync.Mutex           // guards pending
+	pending map[*trace]time.Time // traces with a deferred decision, and when they started
+
+	stop    chan struct{}  // closing stop halts the expiry loop
+	stopped uint32         // stopped is set to 1 once Stop was called
+	wg      sync.WaitGroup // waits for the expiry loop
+}
+
+// newTailSampler returns a tailSampler keeping traces which match any of the
+// given predicates.
+func newTailSampler(predicates []TailSamplingPredicate, maxTraces int, timeout time.Duration, statsd internal.StatsdClient) *tailSampler {
+	if maxTraces <= 0 {
+		maxTraces = defaultTailSamplingMaxTraces
+	}
+	if timeout <= 0 {
+		timeout = defaultTailSamplingTimeout
+	}
+	return &tailSampler{
+		predicates: predicates,
+		maxTraces:  maxTraces,
+		timeout:    timeout,
+		statsd:     statsd,
+		pending:    make(map[*trace]time.Time),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start starts the loop expiring traces which have been pending for too long,
+// using the tracer tr to flush their spans.
+func (ts *tailSampler) Start(tr *tracer) {
+	interval := ts.timeout / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ts.wg.Add(1)
+	go func() {
+		defer ts.wg.Done()
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case now := <-tick.C:
+				ts.expire(tr, now)
+			case <-ts.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the expiry loop. It is safe to call on a nil *tailSampler.
+func (ts *tailSampler) Stop() {
+	if ts == nil || !atomic.CompareAndSwapUint32(&ts.stopped, 0, 1) {
+		return
+	}
+	close(ts.stop)
+	ts.wg.Wait()
+}
+
+// track defers the sampling decision of t until it completes. It reports false
+// if too many traces are already pending.
+func (ts *tailSampler) track(t *trace) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.pending) >= ts.maxTraces {
+		ts.statsd.Incr("datadog.tracer.tail_sampling.overflow", nil, 1)
+		return false
+	}
+	ts.pending[t] = time.Now()
+	return true
+}
+
+// untrack stops tracking t.
+func (ts *tailSampler) untrack(t *trace) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.pending, t)
+}
+
+// keep reports whether any of spans matches any predicate.
+func (ts *tailSampler) keep(spans []*span) bool {
+	for _, s := range spans {
+		for _, p := range ts.predicates {
+			if p.match(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expire flushes the finished spans of all traces which have been pending for
+// longer than the timeout, and stops deferring their sampling decision.
+func (ts *tailSampler) expire(tr *tracer, now time.Time) {
+	var expired []*trace
+	ts.mu.Lock()
+	for t, start := range ts.pending {
+		if now.Sub(start) >= ts.timeout {
+			expired = append(expired, t)
+			delete(ts.pending, t)
+		}
+	}
+	ts.mu.Unlock()
+	for _, t := range expired {
+		ts.statsd.Incr("datadog.tracer.tail_sampling.expired", nil, 1)
+		t.expireTailSampling(tr)
+	}
+}
+
+// finishTailSampling applies the tail sampling decision to the trace once all the
+// given spans have finished, keeping it if they match any predicate. t.mu must be held.
+func (t *trace) finishTailSampling(tr *tracer, spans []*span) {
+	t.tail = tailDone
+	if !tr.tailSampler.keep(spans) {
+		tr.tailSampler.statsd.Incr("datadog.tracer.tail_sampling.decisions", []string{"decision:head"}, 1)
+		return
+	}
+	tr.tailSampler.statsd.Incr("datadog.tracer.tail_sampling.decisions", []string{"decision:keep"}, 1)
+	if t.priority != nil && *t.priority > 0 {
+		// already kept
+		return
+	}
+	locked := t.locked
+	t.locked = false
+	t.setSamplingPriorityLocked(ext.PriorityUserKeep, samplernames.Manual)
+	t.locked = locked
+	atomic.StoreUint32((*uint32)(&t.samplingDecision), uint32(decisionKeep))
+	if locked && t.root != nil {
+		// the root has already finished and had its priority set
+		t.root.setMetric(keySamplingPriority, *t.priority)
+	}
+}
+
+// expireTailSampling stops deferring the sampling decision of the trace, which
+// has been pending for too long, and flushes its finished spans.
+func (t *trace) expireTailSampling(tr *tracer) {
+	t.mu.Lock()
+	if t.tail != tailPending {
+		t.mu.Unlock()
+		return
+	}
+	finished := make([]*span, 0, t.finished)
+	leftover := make([]*span, 0, len(t.spans)-t.finished)
+	for _, s := range t.spans {
+		if s.finished {
+			finished = append(finished, s)
+		} else {
+			leftover = append(leftover, s)
+		}
+	}
+	t.finishTailSampling(tr, finished)
+	if len(finished) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	log.Debug("Tail sampling timed out, flushing %d finished spans", len(finished))
+	if t.priority != nil {
+		finished[0].setMetric(keySamplingPriority, *t.priority)
+	}
+	if finished[0] != t.spans[0] {
+		// make sure the first span in the chunk has the trace-level tags
+		t.setTraceTags(finished[0], tr)
+	}
+	t.finished = 0
+	ch := &chunk{
+		spans:    finished,
+		willSend: decisionKeep == samplingDecision(atomic.LoadUint32((*uint32)(&t.samplingDecision))),
+	}
+	t.spans = leftover
+	t.mu.Unlock()
+	t.finishChunk(tr, ch)
+}
@@ -0,0 +1,164 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailSamplingPredicates(t *testing.T) {
+	assert := assert.New(t)
+	s := newBasicSpan("op")
+	assert.False(TailSampleErrors().match(s))
+	s.Error = 1
+	assert.True(TailSampleErrors().match(s))
+
+	s.Duration = int64(time.Second)
+	assert.True(TailSampleSlowerThan(time.Second).match(s))
+	assert.False(TailSampleSlowerThan(2 * time.Second).match(s))
+
+	s.Meta["customer.tier"] = "gold"
+	s.Metrics["http.status_code"] = 500
+	assert.True(TailSampleTag("customer.tier", "gold").match(s))
+	assert.True(TailSampleTag("customer.tier", "").match(s))
+	assert.False(TailSampleTag("customer.tier", "silver").match(s))
+	assert.True(TailSampleTag("http.status_code", "500").match(s))
+	assert.False(TailSampleTag("missing", "").match(s))
+
+	assert.Equal("duration>=2s", TailSampleSlowerThan(2*time.Second).String())
+}
+
+func TestTailSampling(t *testing.T) {
+	opts := []StartOption{
+		WithSamplingRules([]SamplingRule{RateRule(0)}),
+		WithTailSampling(TailSampleErrors(), TailSampleTag("customer.tier", "gold")),
+	}
+
+	t.Run("keep", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, flush, stop := startTestTracer(t, opts...)
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		child := tracer.StartSpan("child", ChildOf(root.Context()))
+		assert.Len(tracer.tailSampler.pending, 1)
+		child.Finish(WithError(errors.New("oops")))
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 2)
+		assert.Equal(float64(ext.PriorityUserKeep), traces[0][0].Metrics[keySamplingPriority])
+		assert.Equal("-4", traces[0][0].Meta[keyDecisionMaker])
+		assert.Empty(tracer.tailSampler.pending)
+	})
+
+	t.Run("head", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, flush, stop := startTestTracer(t, opts...)
+		defer stop()
+
+		root := tracer.StartSpan("root", Tag("customer.tier", "silver"))
+		tracer.StartSpan("child", ChildOf(root.Context())).Finish()
+		root.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		assert.Equal(float64(ext.PriorityUserReject), traces[0][0].Metrics[keySamplingPriority])
+		assert.Empty(tracer.tailSampler.pending)
+	})
+
+	t.Run("root-first", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, flush, stop := startTestTracer(t, opts...)
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		child := tracer.StartSpan("child", ChildOf(root.Context()), Tag("customer.tier", "gold"))
+		root.Finish()
+		child.Finish()
+		flush(1)
+
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 2)
+		assert.Equal(float64(ext.PriorityUserKeep), traces[0][0].Metrics[keySamplingPriority])
+	})
+
+	t.Run("no-partial-flush", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, flush, stop := startTestTracer(t, append(opts, WithPartialFlushing(1))...)
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		tracer.StartSpan("child", ChildOf(root.Context())).Finish()
+		tracer.StartSpan("child", ChildOf(root.Context())).Finish()
+		assert.Equal(0, transport.Len())
+		root.Finish()
+		flush(1)
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		assert.Len(traces[0], 3)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, transport, flush, stop := startTestTracer(t, append(opts, WithTailSamplingLimits(0, 50*time.Millisecond))...)
+		defer stop()
+
+		root := tracer.StartSpan("root")
+		tracer.StartSpan("child", ChildOf(root.Context()), Tag("customer.tier", "gold")).Finish()
+		assert.Eventually(func() bool {
+			tracer.tailSampler.mu.Lock()
+			defer tracer.tailSampler.mu.Unlock()
+			return len(tracer.tailSampler.pending) == 0
+		}, time.Second, 10*time.Millisecond)
+		flush(1)
+		traces := transport.Traces()
+		require.Len(t, traces, 1)
+		require.Len(t, traces[0], 1)
+		assert.Equal("child", traces[0][0].Name)
+		assert.Equal(float64(ext.PriorityUserKeep), traces[0][0].Metrics[keySamplingPriority])
+
+		root.Finish()
+		flush(1)
+		traces = transport.Traces()
+		require.Len(t, traces, 1)
+		assert.Equal("root", traces[0][0].Name)
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, append(opts, WithTailSamplingLimits(1, 0))...)
+		defer stop()
+
+		a := tracer.StartSpan("a")
+		b := tracer.StartSpan("b")
+		assert.Equal(tailPending, a.(*span).context.trace.tail)
+		assert.Equal(tailDone, b.(*span).context.trace.tail)
+		assert.Equal(defaultTailSamplingTimeout, tracer.tailSampler.timeout)
+		a.Finish()
+		b.Finish()
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+		assert.Nil(t, tracer.tailSampler)
+		s := tracer.StartSpan("root")
+		assert.Equal(t, tailNone, s.(*span).context.trace.tail)
+		s.Finish()
+	})
+}
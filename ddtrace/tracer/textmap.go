@@ -6,10 +6,13 @@
 package tracer
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -73,6 +76,23 @@ const (
 
 	headerPropagationStyleInjectDeprecated  = "DD_PROPAGATION_STYLE_INJECT"  // deprecated
 	headerPropagationStyleExtractDeprecated = "DD_PROPAGATION_STYLE_EXTRACT" // deprecated
+
+	headerPropagationBehaviorExtract = "DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT"
+)
+
+const (
+	// PropagationBehaviorContinue continues the extracted trace: spans started from
+	// the extracted context are children of the remote span. It is the default.
+	PropagationBehaviorContinue = "continue"
+
+	// PropagationBehaviorRestart starts a new trace whenever a trace context is
+	// extracted. Spans started from the extracted context are root spans holding a
+	// span link to the remote span, and inheriting its baggage.
+	PropagationBehaviorRestart = "restart"
+
+	// PropagationBehaviorIgnore ignores any incoming trace context and baggage, as if
+	// none were found.
+	PropagationBehaviorIgnore = "ignore"
 )
 
 const (
@@ -128,6 +148,12 @@ type PropagatorConfig struct {
 	// B3 specifies if B3 headers should be added for trace propagation.
 	// See https://github.com/openzipkin/b3-propagation
 	B3 bool
+
+	// BehaviorExtract specifies what to do with an extracted trace context. It is one of
+	// PropagationBehaviorContinue, PropagationBehaviorRestart or PropagationBehaviorIgnore,
+	// and defaults to the value of DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT, or
+	// PropagationBehaviorContinue.
+	BehaviorExtract string
 }
 
 // NewPropagator returns a new propagator which uses TextMap to inject
@@ -158,6 +184,7 @@ func NewPropagator(cfg *PropagatorConfig, propagators ...Propagator) Propagator
 	}
 	cp := new(chainedPropagator)
 	cp.onlyExtractFirst = internal.BoolEnv("DD_TRACE_PROPAGATION_EXTRACT_FIRST", false)
+	cp.behaviorExtract = getPropagationBehavior(cfg.BehaviorExtract)
 	if len(propagators) > 0 {
 		cp.injectors = propagators
 		cp.extractors = propagators
@@ -188,7 +215,26 @@ type chainedPropagator struct {
 	extractors       []Propagator
 	injectorNames    string
 	extractorsNames  string
-	onlyExtractFirst bool // value of DD_TRACE_PROPAGATION_EXTRACT_FIRST
+	onlyExtractFirst bool   // value of DD_TRACE_PROPAGATION_EXTRACT_FIRST
+	behaviorExtract  string // value of DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT
+}
+
+// getPropagationBehavior returns the extraction behavior b, falling back to the value
+// of DD_TRACE_PROPAGATION_BEHAVIOR_EXTRACT if b is empty. Invalid values log a warning
+// and result in PropagationBehaviorContinue.
+func getPropagationBehavior(b string) string {
+	if b == "" {
+		if b = os.Getenv(headerPropagationBehaviorExtract); b == "" {
+			return PropagationBehaviorContinue
+		}
+	}
+	switch b = strings.ToLower(strings.TrimSpace(b)); b {
+	case PropagationBehaviorContinue, PropagationBehaviorRestart, PropagationBehaviorIgnore:
+		return b
+	default:
+		log.Warn("unrecognized propagation behavior: %s, using %s\n", b, PropagationBehaviorContinue)
+		return PropagationBehaviorContinue
+	}
 }
 
 // getPropagators returns a list of propagators based on ps, which is a comma seperated
@@ -237,6 +283,15 @@ func getPropagators(cfg *PropagatorConfig, ps string) ([]Propagator, string) {
 		case "b3 single header":
 			list = append(list, &propagatorB3SingleHeader{})
 			listNames = append(listNames, v)
+		case "baggage":
+			list = append(list, &propagatorBaggage{})
+			listNames = append(listNames, v)
+		case "xray":
+			list = append(list, &propagatorXRay{})
+			listNames = append(listNames, v)
+		case "jaeger":
+			list = append(list, &propagatorJaeger{})
+			listNames = append(listNames, v)
 		case "none":
 			log.Warn("Propagator \"none\" has no effect when combined with other propagators. " +
 				"To disable the propagator, set to `none`")
@@ -268,10 +323,25 @@ func (p *chainedPropagator) Inject(spanCtx ddtrace.SpanContext, carrier interfac
 // trace context that could be extracted will be returned, and other extractors will
 // be ignored. However, the W3C tracestate header value will always be extracted and
 // stored in the local trace context even if a previous propagator has already succeeded
-// so long as the trace-ids match.
+// so long as the trace-ids match. Likewise, W3C baggage is always extracted and merged
+// into the returned context; if no trace context is found, a context carrying only
+// the baggage is returned.
+//
+// When the extraction behavior is PropagationBehaviorRestart, the returned context
+// starts a new trace linked to the extracted one. When it is PropagationBehaviorIgnore,
+// ErrSpanContextNotFound is always returned.
 func (p *chainedPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
-	var ctx ddtrace.SpanContext
+	if p.behaviorExtract == PropagationBehaviorIgnore {
+		return nil, ErrSpanContextNotFound
+	}
+	var (
+		ctx   ddtrace.SpanContext
+		style string // the style of the propagator which extracted ctx
+	)
 	for _, v := range p.extractors {
+		if _, isBaggage := v.(*propagatorBaggage); isBaggage {
+			continue // extracted below, regardless of the trace context
+		}
 		if ctx != nil {
 			// A local trace context has already been extracted.
 			p, isW3C := v.(*propagatorW3c)
@@ -284,22 +354,102 @@ func (p *chainedPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, e
 		var err error
 		ctx, err = v.Extract(carrier)
 		if ctx != nil {
+			style = propagatorStyle(v)
 			if p.onlyExtractFirst {
-				// Return early if the customer configured that only the first successful
+				// Stop early if the customer configured that only the first successful
 				// extraction should occur.
-				return ctx, nil
+				break
 			}
 		} else if err != ErrSpanContextNotFound {
 			return nil, err
 		}
 	}
+	return p.completeExtract(ctx, style, carrier)
+}
+
+// completeExtract merges the baggage found in carrier into the span context ctx, which
+// was extracted using the given propagation style, if any, and applies the extraction
+// propagation behavior.
+func (p *chainedPropagator) completeExtract(ctx ddtrace.SpanContext, style string, carrier interface{}) (ddtrace.SpanContext, error) {
+	for _, v := range p.extractors {
+		if _, isBaggage := v.(*propagatorBaggage); !isBaggage {
+			continue
+		}
+		bctx, err := v.Extract(carrier)
+		if err != nil {
+			continue
+		}
+		if ctx == nil {
+			ctx = bctx
+			continue
+		}
+		if sctx, ok := ctx.(*spanContext); ok {
+			bctx.ForeachBaggageItem(func(k, v string) bool {
+				sctx.setBaggageItem(k, v)
+				return true
+			})
+		}
+	}
 	if ctx == nil {
 		return nil, ErrSpanContextNotFound
 	}
+	if sctx, ok := ctx.(*spanContext); ok && p.behaviorExtract == PropagationBehaviorRestart && !sctx.baggageOnly {
+		ctx = restartSpanContext(sctx, style)
+	}
 	log.Debug("Extracted span context: %#v", ctx)
 	return ctx, nil
 }
 
+// restartSpanContext returns a context from which spans start a new trace, linked to
+// the remote span of ctx, which was extracted using the given propagation style. The
+// baggage of ctx is kept.
+func restartSpanContext(ctx *spanContext, style string) *spanContext {
+	link := ddtrace.SpanLink{
+		TraceID:     ctx.traceID.Lower(),
+		TraceIDHigh: ctx.traceID.Upper(),
+		SpanID:      ctx.spanID,
+		Attributes: map[string]string{
+			"reason":          "propagation_behavior_extract",
+			"context_headers": style,
+		},
+	}
+	if p, ok := ctx.SamplingPriority(); ok && p > 0 {
+		link.Flags = 1 // sampled
+	}
+	if ctx.trace != nil {
+		link.Tracestate = ctx.trace.propagatingTag(tracestateHeader)
+	}
+	restart := &spanContext{
+		baggageOnly: true,
+		links:       []ddtrace.SpanLink{link},
+	}
+	ctx.ForeachBaggageItem(func(k, v string) bool {
+		restart.setBaggageItem(k, v)
+		return true
+	})
+	return restart
+}
+
+// propagatorStyle returns the name of the propagation style implemented by p.
+func propagatorStyle(p Propagator) string {
+	switch p.(type) {
+	case *propagator:
+		return "datadog"
+	case *propagatorW3c:
+		return "tracecontext"
+	case *propagatorB3:
+		return "b3multi"
+	case *propagatorB3SingleHeader:
+		return "b3"
+	case *propagatorXRay:
+		return "xray"
+	case *propagatorJaeger:
+		return "jaeger"
+	default:
+		return "custom"
+	}
+}
+
 // propagateTracestate will add the tracestate propagating tag to the given
 // *spanContext. The W3C trace context will be extracted from the provided
 // carrier. The trace id of this W3C trace context must match the trace id
@@ -1082,3 +1232,484 @@ func extractTraceID128(ctx *spanContext, v string) error {
 	}
 	return nil
 }
+
+const (
+	// baggageHeader is the name of the W3C baggage header.
+	baggageHeader = "baggage"
+
+	// baggageMaxItems is the maximum number of list-members propagated in the baggage header.
+	baggageMaxItems = 64
+
+	// baggageMaxBytes is the maximum size in bytes of the propagated baggage header.
+	baggageMaxBytes = 8192
+)
+
+// propagatorBaggage implements Propagator and injects/extracts span context baggage
+// using the W3C baggage header (https://www.w3.org/TR/baggage/). It does not propagate
+// any trace context, so it is meant to be combined with other propagators. Only TextMap
+// carriers are supported.
+type propagatorBaggage struct{}
+
+func (p *propagatorBaggage) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch c := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, c)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+// injectTextMap propagates the baggage items of the span context into the writer, as a
+// comma-separated list of percent-encoded <key>=<value> list-members. Items which would
+// exceed baggageMaxItems or baggageMaxBytes are dropped.
+func (*propagatorBaggage) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	if spanCtx == nil {
+		return ErrInvalidSpanContext
+	}
+	var keys []string
+	items := make(map[string]string)
+	spanCtx.ForeachBaggageItem(func(k, v string) bool {
+		keys = append(keys, k)
+		items[k] = v
+		return true
+	})
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	n := 0
+	for _, k := range keys {
+		if n == baggageMaxItems {
+			log.Debug("Dropping baggage items: more than %d items", baggageMaxItems)
+			break
+		}
+		member := encodeBaggage(k, isBaggageKeyChar) + "=" + encodeBaggage(items[k], isBaggageValueChar)
+		size := len(member)
+		if n > 0 {
+			size++ // separator
+		}
+		if b.Len()+size > baggageMaxBytes {
+			log.Debug("Dropping baggage item %q: header would exceed %d bytes", k, baggageMaxBytes)
+			continue
+		}
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(member)
+		n++
+	}
+	writer.Set(baggageHeader, b.String())
+	return nil
+}
+
+func (p *propagatorBaggage) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch c := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(c)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+// extractTextMap returns a span context holding the baggage items found in the baggage
+// header of the reader, and no trace context. The whole header is ignored if it is malformed.
+func (*propagatorBaggage) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var header string
+	if err := reader.ForeachKey(func(k, v string) error {
+		if strings.ToLower(k) == baggageHeader {
+			header = v
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(header) == "" {
+		return nil, ErrSpanContextNotFound
+	}
+	items, err := parseBaggage(header)
+	if err != nil {
+		log.Debug("Ignoring baggage header %q: %v", header, err)
+		return nil, ErrSpanContextNotFound
+	}
+	ctx := &spanContext{baggageOnly: true}
+	for k, v := range items {
+		ctx.setBaggageItem(k, v)
+	}
+	return ctx, nil
+}
+
+// parseBaggage parses the value of a W3C baggage header. List-member properties are
+// discarded, and list-members beyond baggageMaxItems or baggageMaxBytes are ignored.
+func parseBaggage(header string) (map[string]string, error) {
+	if len(header) > baggageMaxBytes {
+		// only keep the complete list-members within the limit
+		header = header[:baggageMaxBytes]
+		if i := strings.LastIndexByte(header, ','); i >= 0 {
+			header = header[:i]
+		}
+	}
+	items := make(map[string]string)
+	for i, member := range strings.Split(header, ",") {
+		if i == baggageMaxItems {
+			break
+		}
+		if j := strings.IndexByte(member, ';'); j >= 0 {
+			member = member[:j] // discard properties
+		}
+		k, v, ok := strings.Cut(member, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid list-member %q", member)
+		}
+		key, err := url.PathUnescape(strings.TrimSpace(k))
+		if err != nil || key == "" {
+			return nil, fmt.Errorf("invalid key in list-member %q", member)
+		}
+		val, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in list-member %q", member)
+		}
+		items[key] = val
+	}
+	return items, nil
+}
+
+// encodeBaggage percent-encodes all the bytes of s for which isAllowed returns false.
+func encodeBaggage(s string, isAllowed func(c byte) bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; isAllowed(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// isBaggageKeyChar reports whether c may appear unencoded in a baggage key,
+// which must be an RFC 7230 token.
+func isBaggageKeyChar(c byte) bool {
+	if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+		return true
+	}
+	return strings.IndexByte("!#$&'*+-.^_`|~", c) >= 0
+}
+
+// isBaggageValueChar reports whether c may appear unencoded in a baggage value.
+// Percent signs are always encoded, so that values can be decoded unambiguously.
+func isBaggageValueChar(c byte) bool {
+	return c >= 0x21 && c <= 0x7E && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%'
+}
+
+const (
+	// xrayTraceHeader is the name of the AWS X-Ray tracing header.
+	xrayTraceHeader = "x-amzn-trace-id"
+
+	xrayRootKey     = "Root"
+	xrayParentKey   = "Parent"
+	xraySampledKey  = "Sampled"
+	xrayOriginKey   = "_dd.origin"
+	xrayPriorityKey = "_dd.priority"
+)
+
+// propagatorXRay implements Propagator and injects/extracts span contexts using the
+// AWS X-Ray tracing header, e.g.
+//
+//	Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+//
+// See https://docs.aws.amazon.com/xray/latest/devguide/xray-concepts.html#xray-concepts-tracingheader.
+// Only TextMap carriers are supported.
+//
+// The X-Ray root is made of a version, the epoch in seconds of the start of the trace
+// and a 96-bit identifier. Together, the epoch and identifier map to the 128-bit trace
+// ID, whose upper bits also start with the epoch in seconds for traces started by this
+// tracer. When the trace ID has no upper bits, the epoch is taken from the start of the
+// local root span instead, as X-Ray rejects roots without it. Sampling decisions which
+// are not automatic are carried in an additional field, as well as the origin, so that
+// they are preserved across X-Ray propagation.
+type propagatorXRay struct{}
+
+func (p *propagatorXRay) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch c := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, c)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+func (*propagatorXRay) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok || ctx.traceID.Empty() || ctx.spanID == 0 {
+		return ErrInvalidSpanContext
+	}
+	tid := ctx.traceID.HexEncoded()
+	if !ctx.traceID.HasUpper() {
+		tid = fmt.Sprintf("%08x", xrayEpoch(ctx)) + tid[8:]
+	}
+	var b strings.Builder
+	b.Grow(128)
+	fmt.Fprintf(&b, "%s=1-%s-%s;%s=%016x", xrayRootKey, tid[:8], tid[8:], xrayParentKey, ctx.spanID)
+	if p, ok := ctx.SamplingPriority(); ok {
+		sampled := 0
+		if p > 0 {
+			sampled = 1
+		}
+		fmt.Fprintf(&b, ";%s=%d", xraySampledKey, sampled)
+		if p != ext.PriorityAutoReject && p != ext.PriorityAutoKeep {
+			fmt.Fprintf(&b, ";%s=%d", xrayPriorityKey, p)
+		}
+	}
+	if ctx.origin != "" {
+		fmt.Fprintf(&b, ";%s=%s", xrayOriginKey, originRgx.ReplaceAllString(ctx.origin, "_"))
+	}
+	writer.Set(xrayTraceHeader, b.String())
+	return nil
+}
+
+func (p *propagatorXRay) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch c := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(c)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+// extractTextMap extracts the span context from the X-Ray tracing header of the reader.
+// A header without a Parent field, as sent by AWS load balancers when a trace starts,
+// results in a span context with no span ID: spans started from it are root spans
+// continuing the X-Ray trace.
+func (*propagatorXRay) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var header string
+	if err := reader.ForeachKey(func(k, v string) error {
+		if strings.ToLower(k) == xrayTraceHeader {
+			header = v
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if header == "" {
+		return nil, ErrSpanContextNotFound
+	}
+	var ctx spanContext
+	var root bool
+	for _, field := range strings.Split(header, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case xrayRootKey:
+			if err := parseXRayRoot(&ctx, v); err != nil {
+				return nil, err
+			}
+			root = true
+		case xrayParentKey:
+			if len(v) != 16 || !isValidID(strings.ToLower(v)) {
+				return nil, ErrSpanContextCorrupted
+			}
+			id, err := strconv.ParseUint(v, 16, 64)
+			if err != nil {
+				return nil, ErrSpanContextCorrupted
+			}
+			ctx.spanID = id
+		case xraySampledKey:
+			switch v {
+			case "1":
+				if _, ok := ctx.SamplingPriority(); !ok {
+					ctx.setSamplingPriority(ext.PriorityAutoKeep, samplernames.Unknown)
+				}
+			case "0":
+				if _, ok := ctx.SamplingPriority(); !ok {
+					ctx.setSamplingPriority(ext.PriorityAutoReject, samplernames.Unknown)
+				}
+			}
+		case xrayPriorityKey:
+			if p, err := strconv.Atoi(v); err == nil {
+				ctx.setSamplingPriority(p, samplernames.Unknown)
+			}
+		case xrayOriginKey:
+			ctx.origin = v
+		}
+	}
+	if !root {
+		return nil, ErrSpanContextNotFound
+	}
+	return &ctx, nil
+}
+
+// xrayEpoch returns the epoch in seconds of the start of the trace of ctx, which is
+// the start of its local root span when known.
+func xrayEpoch(ctx *spanContext) uint32 {
+	start := now()
+	if ctx.span != nil {
+		start = ctx.span.Start
+	}
+	if ctx.trace != nil {
+		ctx.trace.mu.RLock()
+		if ctx.trace.root != nil {
+			start = ctx.trace.root.Start
+		}
+		ctx.trace.mu.RUnlock()
+	}
+	return uint32(start / 1e9)
+}
+
+// parseXRayRoot parses the X-Ray root v, e.g. `1-5759e988-bd862e3fe1be46a994272793`,
+// into the trace ID of ctx.
+func parseXRayRoot(ctx *spanContext, v string) error {
+	parts := strings.Split(strings.ToLower(v), "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 {
+		return ErrSpanContextCorrupted
+	}
+	tid := parts[1] + parts[2]
+	if !isValidID(tid) {
+		return ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(ctx.traceID[:], []byte(tid)); err != nil {
+		return ErrSpanContextCorrupted
+	}
+	if ctx.traceID.Empty() {
+		return ErrSpanContextCorrupted
+	}
+	return nil
+}
+
+const (
+	// jaegerTraceHeader is the name of the Jaeger tracing header.
+	jaegerTraceHeader = "uber-trace-id"
+
+	// jaegerBaggagePrefix is the prefix of the Jaeger baggage headers.
+	jaegerBaggagePrefix = "uberctx-"
+
+	// jaegerFlagSampled and jaegerFlagDebug are the flags of the Jaeger tracing header
+	// which are mapped to the sampling priority.
+	jaegerFlagSampled = 0x1
+	jaegerFlagDebug   = 0x2
+)
+
+// propagatorJaeger implements Propagator and injects/extracts span contexts using the
+// Jaeger `uber-trace-id` header, in the `{trace-id}:{span-id}:{parent-span-id}:{flags}`
+// format, and baggage using `uberctx-{key}` headers.
+// See https://www.jaegertracing.io/docs/1.21/client-libraries/#propagation-format.
+// Only TextMap carriers are supported.
+//
+// The sampled flag maps to automatic sampling decisions, and the debug flag, which
+// forces sampling, to PriorityUserKeep.
+type propagatorJaeger struct{}
+
+func (p *propagatorJaeger) Inject(spanCtx ddtrace.SpanContext, carrier interface{}) error {
+	switch c := carrier.(type) {
+	case TextMapWriter:
+		return p.injectTextMap(spanCtx, c)
+	default:
+		return ErrInvalidCarrier
+	}
+}
+
+func (*propagatorJaeger) injectTextMap(spanCtx ddtrace.SpanContext, writer TextMapWriter) error {
+	ctx, ok := spanCtx.(*spanContext)
+	if !ok || ctx.traceID.Empty() || ctx.spanID == 0 {
+		return ErrInvalidSpanContext
+	}
+	var traceID string
+	if ctx.traceID.HasUpper() {
+		traceID = fmt.Sprintf("%x%016x", ctx.traceID.Upper(), ctx.traceID.Lower())
+	} else {
+		traceID = fmt.Sprintf("%x", ctx.traceID.Lower())
+	}
+	var flags int
+	if p, ok := ctx.SamplingPriority(); ok && p > 0 {
+		flags = jaegerFlagSampled
+		if p >= ext.PriorityUserKeep {
+			flags |= jaegerFlagDebug
+		}
+	}
+	// the parent span ID is deprecated, and always set to 0
+	writer.Set(jaegerTraceHeader, fmt.Sprintf("%s:%x:0:%x", traceID, ctx.spanID, flags))
+	ctx.ForeachBaggageItem(func(k, v string) bool {
+		writer.Set(jaegerBaggagePrefix+k, url.PathEscape(v))
+		return true
+	})
+	return nil
+}
+
+func (p *propagatorJaeger) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	switch c := carrier.(type) {
+	case TextMapReader:
+		return p.extractTextMap(c)
+	default:
+		return nil, ErrInvalidCarrier
+	}
+}
+
+func (*propagatorJaeger) extractTextMap(reader TextMapReader) (ddtrace.SpanContext, error) {
+	var header string
+	var ctx spanContext
+	if err := reader.ForeachKey(func(k, v string) error {
+		key := strings.ToLower(k)
+		switch {
+		case key == jaegerTraceHeader:
+			header = v
+		case strings.HasPrefix(key, jaegerBaggagePrefix):
+			if unescaped, err := url.PathUnescape(v); err == nil {
+				v = unescaped
+			}
+			ctx.setBaggageItem(strings.TrimPrefix(key, jaegerBaggagePrefix), v)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if header == "" {
+		return nil, ErrSpanContextNotFound
+	}
+	if err := parseJaegerHeader(&ctx, header); err != nil {
+		return nil, err
+	}
+	return &ctx, nil
+}
+
+// parseJaegerHeader parses the Jaeger tracing header v, which may be URL-encoded, into ctx.
+func parseJaegerHeader(ctx *spanContext, v string) error {
+	if unescaped, err := url.PathUnescape(v); err == nil {
+		v = unescaped
+	}
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(v)), ":")
+	if len(parts) != 4 {
+		return ErrSpanContextCorrupted
+	}
+	traceID, spanID, flags := parts[0], parts[1], parts[3]
+	if len(traceID) > 32 || !isValidID(traceID) {
+		return ErrSpanContextCorrupted
+	}
+	if err := extractTraceID128(ctx, traceID); err != nil {
+		return err
+	}
+	if len(spanID) > 16 || !isValidID(spanID) {
+		return ErrSpanContextCorrupted
+	}
+	var err error
+	if ctx.spanID, err = strconv.ParseUint(spanID, 16, 64); err != nil {
+		return ErrSpanContextCorrupted
+	}
+	if ctx.traceID.Empty() || ctx.spanID == 0 {
+		return ErrSpanContextNotFound
+	}
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return ErrSpanContextCorrupted
+	}
+	switch {
+	case f&jaegerFlagDebug != 0:
+		ctx.setSamplingPriority(ext.PriorityUserKeep, samplernames.Unknown)
+	case f&jaegerFlagSampled != 0:
+		ctx.setSamplingPriority(ext.PriorityAutoKeep, samplernames.Unknown)
+	default:
+		ctx.setSamplingPriority(ext.PriorityAutoReject, samplernames.Unknown)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
@@ -1858,6 +1859,401 @@ func TestW3CExtractsBaggage(t *testing.T) {
 	assert.True(t, found)
 }
 
+func TestBaggagePropagator(t *testing.T) {
+	t.Run("inject", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleInject, "datadog,baggage")
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request")
+		root.SetBaggageItem("user.id", "42")
+		root.SetBaggageItem("shopping cart", "a,b;c=%")
+		headers := TextMapCarrier{}
+		assert.NoError(tracer.Inject(root.Context(), headers))
+		assert.Equal("shopping%20cart=a%2Cb%3Bc=%25,user.id=42", headers[baggageHeader])
+		assert.Equal("42", headers[DefaultBaggageHeaderPrefix+"user.id"])
+	})
+
+	t.Run("inject/limits", func(t *testing.T) {
+		assert := assert.New(t)
+		ctx := &spanContext{}
+		for i := 0; i < baggageMaxItems+10; i++ {
+			ctx.setBaggageItem(fmt.Sprintf("k%03d", i), "v")
+		}
+		headers := TextMapCarrier{}
+		assert.NoError((&propagatorBaggage{}).Inject(ctx, headers))
+		assert.Len(strings.Split(headers[baggageHeader], ","), baggageMaxItems)
+
+		ctx = &spanContext{}
+		ctx.setBaggageItem("a", strings.Repeat("x", baggageMaxBytes))
+		ctx.setBaggageItem("b", "small")
+		headers = TextMapCarrier{}
+		assert.NoError((&propagatorBaggage{}).Inject(ctx, headers))
+		assert.Equal("b=small", headers[baggageHeader])
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "tracecontext,baggage")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(HTTPHeadersCarrier{
+			"Traceparent": []string{"00-12345678901234567890123456789012-1234567890123456-01"},
+			"Baggage":     []string{" user.id = 42 ;prop=1, shopping%20cart=a%2Cb ,empty="},
+		})
+		assert.NoError(err)
+		assert.Equal(uint64(0x1234567890123456), ctx.SpanID())
+		sctx := ctx.(*spanContext)
+		assert.False(sctx.baggageOnly)
+		assert.Equal("42", sctx.baggageItem("user.id"))
+		assert.Equal("a,b", sctx.baggageItem("shopping cart"))
+		assert.Equal("", sctx.baggageItem("empty"))
+
+		child := tracer.StartSpan("child", ChildOf(ctx))
+		assert.Equal("42", child.BaggageItem("user.id"))
+		assert.Equal(ctx.TraceID(), child.Context().TraceID())
+	})
+
+	t.Run("extract/baggage-only", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyle, "datadog,baggage")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{baggageHeader: "user.id=42"})
+		assert.NoError(err)
+		assert.True(ctx.(*spanContext).baggageOnly)
+
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.Equal("42", root.BaggageItem("user.id"))
+		assert.NotZero(root.TraceID)
+		assert.Zero(root.ParentID)
+		assert.Equal(root, root.context.trace.root)
+	})
+
+	t.Run("extract/malformed", func(t *testing.T) {
+		for _, header := range []string{
+			"",
+			"novalue",
+			"=value",
+			"k=v,novalue",
+			"k=%zz",
+		} {
+			_, err := (&propagatorBaggage{}).Extract(TextMapCarrier{baggageHeader: header})
+			assert.Equal(t, ErrSpanContextNotFound, err, header)
+		}
+	})
+
+	t.Run("extract/limits", func(t *testing.T) {
+		assert := assert.New(t)
+		var members []string
+		for i := 0; i < baggageMaxItems+10; i++ {
+			members = append(members, fmt.Sprintf("k%d=v", i))
+		}
+		ctx, err := (&propagatorBaggage{}).Extract(TextMapCarrier{baggageHeader: strings.Join(members, ",")})
+		assert.NoError(err)
+		assert.Len(ctx.(*spanContext).baggage, baggageMaxItems)
+
+		header := "a=b," + strings.Repeat("x", baggageMaxBytes) + "=y"
+		ctx, err = (&propagatorBaggage{}).Extract(TextMapCarrier{baggageHeader: header})
+		assert.NoError(err)
+		assert.Equal(map[string]string{"a": "b"}, ctx.(*spanContext).baggage)
+	})
+
+	t.Run("names", func(t *testing.T) {
+		_, names := getPropagators(&PropagatorConfig{}, "tracecontext,baggage")
+		assert.Equal(t, "tracecontext,baggage", names)
+	})
+}
+
+func TestXRayPropagator(t *testing.T) {
+	t.Run("extract", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "xray")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(HTTPHeadersCarrier{
+			"X-Amzn-Trace-Id": []string{"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1;Lineage=a87bd80c:0"},
+		})
+		assert.NoError(err)
+		sctx := ctx.(*spanContext)
+		assert.Equal("5759e988bd862e3fe1be46a994272793", sctx.TraceID128())
+		assert.Equal(uint64(0x53995c3f42cd8ad8), sctx.SpanID())
+		p, ok := sctx.SamplingPriority()
+		assert.True(ok)
+		assert.Equal(ext.PriorityAutoKeep, p)
+
+		child := tracer.StartSpan("child", ChildOf(ctx)).(*span)
+		assert.Equal(uint64(0xe1be46a994272793), child.TraceID)
+		assert.Equal(uint64(0x53995c3f42cd8ad8), child.ParentID)
+		assert.Equal("5759e988bd862e3f", child.context.traceID.UpperHex())
+	})
+
+	t.Run("extract/root-only", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "xray")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{xrayTraceHeader: "Root=1-5759e988-bd862e3fe1be46a994272793"})
+		assert.NoError(err)
+		_, ok := ctx.(*spanContext).SamplingPriority()
+		assert.False(ok)
+
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.Equal(uint64(0xe1be46a994272793), root.TraceID)
+		assert.Zero(root.ParentID)
+		_, ok = root.context.SamplingPriority()
+		assert.True(ok)
+	})
+
+	t.Run("extract/invalid", func(t *testing.T) {
+		for header, want := range map[string]error{
+			"":                        ErrSpanContextNotFound,
+			"Parent=53995c3f42cd8ad8": ErrSpanContextNotFound,
+			"Root=2-5759e988-bd862e3fe1be46a994272793":                         ErrSpanContextCorrupted,
+			"Root=1-5759e988-bd862e3fe1be46a99427279":                          ErrSpanContextCorrupted,
+			"Root=1-5759e988-bd862e3fe1be46a99427279z":                         ErrSpanContextCorrupted,
+			"Root=1-00000000-000000000000000000000000":                         ErrSpanContextCorrupted,
+			"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad":  ErrSpanContextCorrupted,
+			"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8adx": ErrSpanContextCorrupted,
+		} {
+			_, err := (&propagatorXRay{}).Extract(TextMapCarrier{xrayTraceHeader: header})
+			assert.Equal(t, want, err, header)
+		}
+	})
+
+	t.Run("inject", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleInject, "xray")
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		root.context.traceID = traceID{0x57, 0x59, 0xe9, 0x88, 0xbd, 0x86, 0x2e, 0x3f, 0xe1, 0xbe, 0x46, 0xa9, 0x94, 0x27, 0x27, 0x93}
+		root.context.spanID = 0x53995c3f42cd8ad8
+		root.SetTag(ext.ManualKeep, true)
+		root.context.origin = "synthetics;x"
+		headers := TextMapCarrier{}
+		assert.NoError(tracer.Inject(root.Context(), headers))
+		assert.Equal("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1;_dd.priority=2;_dd.origin=synthetics_x", headers[xrayTraceHeader])
+	})
+
+	t.Run("inject-64-bit", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleInject, "xray")
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request", StartTime(time.Unix(0x6543210f, 0))).(*span)
+		child := tracer.StartSpan("db.query", ChildOf(root.Context()), StartTime(time.Unix(0x6543211f, 0))).(*span)
+		child.context.traceID = traceID{}
+		child.context.traceID.SetLower(0x0123456789abcdef)
+		child.context.spanID = 0x53995c3f42cd8ad8
+		headers := TextMapCarrier{}
+		assert.NoError(tracer.Inject(child.Context(), headers))
+		assert.Equal("Root=1-6543210f-000000000123456789abcdef;Parent=53995c3f42cd8ad8;Sampled=1", headers[xrayTraceHeader])
+	})
+
+	t.Run("roundtrip", func(t *testing.T) {
+		for _, priority := range []int{ext.PriorityUserReject, ext.PriorityAutoReject, ext.PriorityAutoKeep, ext.PriorityUserKeep} {
+			assert := assert.New(t)
+			ctx := &spanContext{spanID: 42, origin: "rum"}
+			ctx.traceID.SetUpper(0x6543210f00000000)
+			ctx.traceID.SetLower(1234)
+			ctx.setSamplingPriority(priority, samplernames.Unknown)
+			headers := TextMapCarrier{}
+			assert.NoError((&propagatorXRay{}).Inject(ctx, headers))
+			got, err := (&propagatorXRay{}).Extract(headers)
+			assert.NoError(err)
+			assert.Equal(ctx.traceID, got.(*spanContext).traceID)
+			assert.Equal(ctx.spanID, got.SpanID())
+			assert.Equal("rum", got.(*spanContext).origin)
+			p, _ := got.(*spanContext).SamplingPriority()
+			assert.Equal(priority, p, headers[xrayTraceHeader])
+		}
+	})
+}
+
+func TestJaegerPropagator(t *testing.T) {
+	t.Run("extract", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "jaeger")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(HTTPHeadersCarrier{
+			"Uber-Trace-Id":   []string{"5759e988bd862e3fe1be46a994272793:53995c3f42cd8ad8:0:1"},
+			"Uberctx-User-Id": []string{"a%20b"},
+		})
+		assert.NoError(err)
+		sctx := ctx.(*spanContext)
+		assert.Equal("5759e988bd862e3fe1be46a994272793", sctx.TraceID128())
+		assert.Equal(uint64(0x53995c3f42cd8ad8), sctx.SpanID())
+		assert.Equal("a b", sctx.baggageItem("user-id"))
+		p, _ := sctx.SamplingPriority()
+		assert.Equal(ext.PriorityAutoKeep, p)
+
+		child := tracer.StartSpan("child", ChildOf(ctx)).(*span)
+		assert.Equal(uint64(0xe1be46a994272793), child.TraceID)
+		assert.Equal(uint64(0x53995c3f42cd8ad8), child.ParentID)
+		assert.Equal("a b", child.BaggageItem("user-id"))
+	})
+
+	t.Run("extract/flags", func(t *testing.T) {
+		for header, want := range map[string]int{
+			"abc:def:0:0":        ext.PriorityAutoReject,
+			"abc:def:0:1":        ext.PriorityAutoKeep,
+			"abc:def:0:3":        ext.PriorityUserKeep,
+			"abc:def:0:02":       ext.PriorityUserKeep,
+			"abc%3Adef%3A0%3A01": ext.PriorityAutoKeep,
+		} {
+			ctx, err := (&propagatorJaeger{}).Extract(TextMapCarrier{jaegerTraceHeader: header})
+			assert.NoError(t, err, header)
+			assert.Equal(t, uint64(0xabc), ctx.TraceID(), header)
+			assert.Equal(t, uint64(0xdef), ctx.SpanID(), header)
+			p, _ := ctx.(*spanContext).SamplingPriority()
+			assert.Equal(t, want, p, header)
+		}
+	})
+
+	t.Run("extract/invalid", func(t *testing.T) {
+		for header, want := range map[string]error{
+			"":                                   ErrSpanContextNotFound,
+			"0:def:0:1":                          ErrSpanContextCorrupted,
+			"abc:0:0:1":                          ErrSpanContextNotFound,
+			"abc:def:0":                          ErrSpanContextCorrupted,
+			"abc:def:0:1:2":                      ErrSpanContextCorrupted,
+			"xyz:def:0:1":                        ErrSpanContextCorrupted,
+			"abc:def:0:zz":                       ErrSpanContextCorrupted,
+			"abc:12345678901234567:0:1":          ErrSpanContextCorrupted,
+			strings.Repeat("a", 33) + ":def:0:1": ErrSpanContextCorrupted,
+		} {
+			_, err := (&propagatorJaeger{}).Extract(TextMapCarrier{jaegerTraceHeader: header})
+			assert.Equal(t, want, err, header)
+		}
+	})
+
+	t.Run("inject", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleInject, "jaeger")
+		tracer := newTracer()
+		defer tracer.Stop()
+		root := tracer.StartSpan("web.request").(*span)
+		root.context.traceID = traceID{0x57, 0x59, 0xe9, 0x88, 0xbd, 0x86, 0x2e, 0x3f, 0xe1, 0xbe, 0x46, 0xa9, 0x94, 0x27, 0x27, 0x93}
+		root.context.spanID = 0x53995c3f42cd8ad8
+		root.SetTag(ext.ManualKeep, true)
+		root.SetBaggageItem("user-id", "a b")
+		headers := TextMapCarrier{}
+		assert.NoError(tracer.Inject(root.Context(), headers))
+		assert.Equal("5759e988bd862e3fe1be46a994272793:53995c3f42cd8ad8:0:3", headers[jaegerTraceHeader])
+		assert.Equal("a%20b", headers[jaegerBaggagePrefix+"user-id"])
+	})
+
+	t.Run("roundtrip", func(t *testing.T) {
+		for _, priority := range []int{ext.PriorityAutoReject, ext.PriorityAutoKeep, ext.PriorityUserKeep} {
+			assert := assert.New(t)
+			ctx := &spanContext{spanID: 42}
+			ctx.traceID.SetLower(1234)
+			ctx.setSamplingPriority(priority, samplernames.Unknown)
+			headers := TextMapCarrier{}
+			assert.NoError((&propagatorJaeger{}).Inject(ctx, headers))
+			assert.Equal(fmt.Sprintf("4d2:2a:0:%x", priority+priority/2), headers[jaegerTraceHeader])
+			got, err := (&propagatorJaeger{}).Extract(headers)
+			assert.NoError(err)
+			assert.Equal(ctx.traceID, got.(*spanContext).traceID)
+			assert.Equal(ctx.spanID, got.SpanID())
+			p, _ := got.(*spanContext).SamplingPriority()
+			assert.Equal(priority, p)
+		}
+	})
+}
+
+func TestPropagationBehaviorExtract(t *testing.T) {
+	headers := func() TextMapCarrier {
+		return TextMapCarrier{
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+			DefaultPriorityHeader: "1",
+			baggageHeader:         "user.id=42",
+		}
+	}
+
+	t.Run("continue", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "datadog,baggage")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(headers())
+		assert.NoError(err)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.Equal(uint64(1), root.TraceID)
+		assert.Equal(uint64(2), root.ParentID)
+		assert.Empty(root.SpanLinks)
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "datadog,baggage")
+		t.Setenv(headerPropagationBehaviorExtract, "restart")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(headers())
+		assert.NoError(err)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.NotEqual(uint64(1), root.TraceID)
+		assert.Zero(root.ParentID)
+		assert.Equal("42", root.BaggageItem("user.id"))
+		assert.Equal([]ddtrace.SpanLink{{
+			TraceID: 1,
+			SpanID:  2,
+			Flags:   1,
+			Attributes: map[string]string{
+				"reason":          "propagation_behavior_extract",
+				"context_headers": "datadog",
+			},
+		}}, root.SpanLinks)
+		_, ok := root.context.SamplingPriority()
+		assert.True(ok) // a new sampling decision was made
+
+		child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+		assert.Equal(root.TraceID, child.TraceID)
+		assert.Empty(child.SpanLinks)
+	})
+
+	t.Run("restart/baggage-only", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "datadog,baggage")
+		t.Setenv(headerPropagationBehaviorExtract, "restart")
+		tracer := newTracer()
+		defer tracer.Stop()
+		ctx, err := tracer.Extract(TextMapCarrier{baggageHeader: "user.id=42"})
+		assert.NoError(err)
+		root := tracer.StartSpan("web.request", ChildOf(ctx)).(*span)
+		assert.Equal("42", root.BaggageItem("user.id"))
+		assert.Empty(root.SpanLinks)
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationStyleExtract, "datadog,baggage")
+		t.Setenv(headerPropagationBehaviorExtract, "ignore")
+		tracer := newTracer()
+		defer tracer.Stop()
+		_, err := tracer.Extract(headers())
+		assert.Equal(ErrSpanContextNotFound, err)
+	})
+
+	t.Run("config", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv(headerPropagationBehaviorExtract, "ignore")
+		p := NewPropagator(&PropagatorConfig{BehaviorExtract: PropagationBehaviorContinue})
+		ctx, err := p.Extract(headers())
+		assert.NoError(err)
+		assert.Equal(uint64(2), ctx.SpanID())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv(headerPropagationBehaviorExtract, "drop")
+		assert.Equal(t, PropagationBehaviorContinue, NewPropagator(nil).(*chainedPropagator).behaviorExtract)
+	})
+}
+
 func TestNonePropagator(t *testing.T) {
 	t.Run("inject/none", func(t *testing.T) {
 		t.Setenv(headerPropagationStyleInject, "none")
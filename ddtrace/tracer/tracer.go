@@ -102,6 +102,10 @@ type tracer struct {
 	// dataStreams processes data streams monitoring information
 	dataStreams *datastreams.Processor
 
+	// tailSampler defers the sampling decision of traces until they complete, when
+	// tail sampling is enabled.
+	tailSampler *tailSampler
+
 	// abandonedSpansDebugger specifies where and how potentially abandoned spans are stored
 	// when abandoned spans debugging is enabled.
 	abandonedSpansDebugger *abandonedSpansDebugger
@@ -228,8 +232,11 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 	if err != nil {
 		log.Warn("Runtime and health metrics disabled: %v", err)
 	}
+	c.agentBreaker = newAgentBreaker(statsd)
 	var writer traceWriter
-	if c.logToStdout {
+	if len(c.exporters) > 0 {
+		writer = newExporterTraceWriter(c.exporters, statsd)
+	} else if c.logToStdout {
 		writer = newLogTraceWriter(c, statsd)
 	} else {
 		writer = newAgentTraceWriter(c, sampler, statsd)
@@ -238,6 +245,8 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 	if err != nil {
 		log.Warn("DIAGNOSTICS Error(s) parsing sampling rules: found errors:%s", err)
 	}
+	traceRulesOrigin := samplingRulesOrigin(traces != nil, c.traceRules)
+	spanRulesOrigin := samplingRulesOrigin(spans != nil, c.spanRules)
 	if traces != nil {
 		c.traceRules = traces
 	}
@@ -247,6 +256,10 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 	globalRate := globalSampleRate()
 	rulesSampler := newRulesSampler(c.traceRules, c.spanRules, globalRate)
 	c.traceSampleRate = newDynamicConfig("trace_sample_rate", globalRate, rulesSampler.traces.setGlobalSampleRate, equal[float64])
+	c.traceSamplingRules = newDynamicConfig("trace_sample_rules", c.traceRules, rulesSampler.traces.setRules, equalSamplingRules)
+	c.traceSamplingRules.setStartupOrigin(traceRulesOrigin)
+	c.spanSamplingRules = newDynamicConfig("span_sample_rules", c.spanRules, rulesSampler.spans.setRules, equalSamplingRules)
+	c.spanSamplingRules.setStartupOrigin(spanRulesOrigin)
 	var dataStreamsProcessor *datastreams.Processor
 	if c.dataStreamsMonitoringEnabled {
 		dataStreamsProcessor = datastreams.NewProcessor(statsd, c.env, c.serviceName, c.version, c.agentURL, c.httpClient, func() bool {
@@ -276,6 +289,12 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 		statsd:      statsd,
 		dataStreams: dataStreamsProcessor,
 	}
+	if c.redMetrics {
+		t.stats.red = newREDMetrics(statsd)
+	}
+	if len(c.tailSamplingPredicates) > 0 {
+		t.tailSampler = newTailSampler(c.tailSamplingPredicates, c.tailSamplingMaxTraces, c.tailSamplingTimeout, statsd)
+	}
 	return t
 }
 
@@ -293,12 +312,23 @@ func newTracer(opts ...StartOption) *tracer {
 		t.wg.Add(1)
 		go func() {
 			defer t.wg.Done()
-			t.reportRuntimeMetrics(defaultMetricsReportInterval)
+			if c.runtimeMetricsV2 {
+				t.reportRuntimeMetricsV2(defaultMetricsReportInterval)
+			} else {
+				t.reportRuntimeMetrics(defaultMetricsReportInterval)
+			}
 		}()
 	}
+	if t.tailSampler != nil {
+		log.Debug("Tail sampling enabled.")
+		t.tailSampler.Start(t)
+	}
 	if c.debugAbandonedSpans {
 		log.Info("Abandoned spans logs enabled.")
 		t.abandonedSpansDebugger = newAbandonedSpansDebugger()
+		if c.abandonedSpansMetrics {
+			t.abandonedSpansDebugger.statsd = t.statsd
+		}
 		t.abandonedSpansDebugger.Start(t.config.spanTimeout)
 	}
 	t.wg.Add(1)
@@ -474,6 +504,11 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 			}
 		}
 	}
+	var baggageParent *spanContext
+	if context != nil && context.baggageOnly {
+		// there is no trace to continue: this is a root span inheriting the baggage
+		baggageParent, context = context, nil
+	}
 	if pprofContext == nil {
 		// For root span's without context, there is no pprofContext, but we need
 		// one to avoid a panic() in pprof.WithLabels(). Using context.Background()
@@ -498,6 +533,12 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 		Start:        startTime,
 		noDebugStack: t.config.noDebugStack,
 	}
+	if len(opts.SpanLinks) > 0 {
+		span.SpanLinks = append([]ddtrace.SpanLink(nil), opts.SpanLinks...)
+	}
+	if baggageParent != nil && len(baggageParent.links) > 0 {
+		span.SpanLinks = append(span.SpanLinks, baggageParent.links...)
+	}
 	if t.config.hostname != "" {
 		span.setMeta(keyHostname, t.config.hostname)
 	}
@@ -522,6 +563,12 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 		}
 	}
 	span.context = newSpanContext(span, context)
+	if baggageParent != nil {
+		baggageParent.ForeachBaggageItem(func(k, v string) bool {
+			span.context.setBaggageItem(k, v)
+			return true
+		})
+	}
 	span.setMetric(ext.Pid, float64(t.pid))
 	span.setMeta("language", "go")
 
@@ -556,6 +603,11 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	if t.config.env != "" {
 		span.setMeta(ext.Environment, t.config.env)
 	}
+	if len(t.config.spanProcessors) > 0 {
+		span.Lock()
+		t.processStart(span)
+		span.Unlock()
+	}
 	if _, ok := span.context.SamplingPriority(); !ok {
 		// if not already sampled or a brand new trace, sample it
 		t.sample(span)
@@ -575,8 +627,13 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 			span, span.Name, span.Resource, span.Meta, span.Metrics)
 	}
 	if t.config.debugAbandonedSpans {
+		c := newAbandonedSpanCandidate(span, false)
+		if rate := t.config.abandonedSpansStackRate; rate > 0 && sampledByRate(span.SpanID, rate) {
+			// skip StartSpan itself; the stack is only symbolized when reported
+			c.pcs = callerPCs(creationStackDepth, 1)
+		}
 		select {
-		case t.abandonedSpansDebugger.In <- newAbandonedSpanCandidate(span, false):
+		case t.abandonedSpansDebugger.In <- c:
 			// ok
 		default:
 			log.Error("Abandoned spans channel full, disregarding span.")
@@ -639,6 +696,7 @@ func (t *tracer) Stop() {
 		t.statsd.Incr("datadog.tracer.stopped", nil, 1)
 	})
 	t.abandonedSpansDebugger.Stop()
+	t.tailSampler.Stop()
 	t.stats.Stop()
 	t.wg.Wait()
 	t.traceWriter.stop()
@@ -650,14 +708,50 @@ func (t *tracer) Stop() {
 	remoteconfig.Stop()
 }
 
-// Inject uses the configured or default TextMap Propagator.
+// Inject uses the configured or default TextMap Propagator. BinaryCarriers and,
+// when binary propagation is enabled, HeadersCarriers use the binary encoding instead.
 func (t *tracer) Inject(ctx ddtrace.SpanContext, carrier interface{}) error {
+	if sc, ok := ctx.(*spanContext); ok && sc.trace != nil {
+		// the sampling decision was propagated, it must not change at finish
+		sc.trace.mu.Lock()
+		sc.trace.injected = true
+		sc.trace.mu.Unlock()
+	}
+	if ok, err := t.injectBinary(ctx, carrier); ok {
+		return err
+	}
+	if c, ok := carrier.(HeadersCarrier); ok {
+		if _, ok := carrier.(TextMapWriter); !ok {
+			carrier = headersTextMap{c}
+		}
+	}
 	return t.config.propagator.Inject(ctx, carrier)
 }
 
-// Extract uses the configured or default TextMap Propagator.
+// Extract uses the configured or default TextMap Propagator, unless the carrier holds
+// a binary encoded span context. The baggage and the extraction propagation behavior of
+// the default propagator also apply to binary encoded span contexts.
 func (t *tracer) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
-	return t.config.propagator.Extract(carrier)
+	ctx, isBinary, err := t.extractBinary(carrier)
+	if c, ok := carrier.(HeadersCarrier); ok {
+		if _, ok := carrier.(TextMapReader); !ok {
+			carrier = headersTextMap{c}
+		}
+	}
+	if !isBinary {
+		return t.config.propagator.Extract(carrier)
+	}
+	p, ok := t.config.propagator.(*chainedPropagator)
+	if !ok {
+		return ctx, err
+	}
+	if p.behaviorExtract == PropagationBehaviorIgnore {
+		return nil, ErrSpanContextNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.completeExtract(ctx, binaryPropagationStyle, carrier)
 }
 
 // sampleRateMetricKey is the metric key holding the applied sample rate. Has to be the same as the Agent.
@@ -684,6 +778,27 @@ func (t *tracer) sample(span *span) {
 	t.prioritySampling.apply(span)
 }
 
+// resample runs the trace sampling rules again when the root span s of a local
+// trace finishes, unless its sampling priority was not set by the rules or the
+// priority sampler, can no longer be altered, or was already propagated to other
+// services by injecting the trace context. The span must be locked.
+func (t *tracer) resample(s *span) {
+	trace := s.context.trace
+	if trace.root != s || samplingDecision(atomic.LoadUint32((*uint32)(&trace.samplingDecision))) == decisionDrop {
+		return
+	}
+	trace.mu.RLock()
+	var auto bool
+	switch trace.sampler {
+	case samplernames.AgentRate, samplernames.RuleRate, samplernames.RemoteRate, samplernames.RemoteUserRate:
+		auto = !trace.locked && !trace.injected
+	}
+	trace.mu.RUnlock()
+	if auto {
+		t.rulesSampling.traces.applyFinished(s)
+	}
+}
+
 func startExecutionTracerTask(ctx gocontext.Context, span *span) (gocontext.Context, func()) {
 	if !rt.IsEnabled() {
 		return ctx, func() {}
@@ -74,10 +74,11 @@ type transport interface {
 }
 
 type httpTransport struct {
-	traceURL string            // the delivery URL for traces
-	statsURL string            // the delivery URL for stats
-	client   *http.Client      // the HTTP client used in the POST
-	headers  map[string]string // the Transport headers
+	traceURL    string            // the delivery URL for traces
+	traceURLV05 string            // the delivery URL for traces using the v0.5 encoding
+	statsURL    string            // the delivery URL for stats
+	client      *http.Client      // the HTTP client used in the POST
+	headers     map[string]string // the Transport headers
 }
 
 // newTransport returns a new Transport implementation that sends traces to a
@@ -103,10 +104,11 @@ func newHTTPTransport(url string, client *http.Client) *httpTransport {
 		defaultHeaders["Datadog-Entity-ID"] = eid
 	}
 	return &httpTransport{
-		traceURL: fmt.Sprintf("%s/v0.4/traces", url),
-		statsURL: fmt.Sprintf("%s/v0.6/stats", url),
-		client:   client,
-		headers:  defaultHeaders,
+		traceURL:    fmt.Sprintf("%s/v0.4/traces", url),
+		traceURLV05: fmt.Sprintf("%s/v0.5/traces", url),
+		statsURL:    fmt.Sprintf("%s/v0.6/stats", url),
+		client:      client,
+		headers:     defaultHeaders,
 	}
 }
 
@@ -123,23 +125,18 @@ func (t *httpTransport) sendStats(p *statsPayload) error {
 	if err != nil {
 		return err
 	}
-	if code := resp.StatusCode; code >= 400 {
-		// error, check the body for context information and
-		// return a nice error.
-		msg := make([]byte, 1000)
-		n, _ := resp.Body.Read(msg)
-		resp.Body.Close()
-		txt := http.StatusText(code)
-		if n > 0 {
-			return fmt.Errorf("%s (Status: %s)", msg[:n], txt)
-		}
-		return fmt.Errorf("%s", txt)
+	if resp.StatusCode >= 400 {
+		return newHTTPStatusError(resp)
 	}
 	return nil
 }
 
 func (t *httpTransport) send(p *payload) (body io.ReadCloser, err error) {
-	req, err := http.NewRequest("POST", t.traceURL, p)
+	traceURL := t.traceURL
+	if p.protocol == traceProtocolV05 {
+		traceURL = t.traceURLV05
+	}
+	req, err := http.NewRequest("POST", traceURL, p)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create http request: %v", err)
 	}
@@ -168,17 +165,8 @@ func (t *httpTransport) send(p *payload) (body io.ReadCloser, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if code := response.StatusCode; code >= 400 {
-		// error, check the body for context information and
-		// return a nice error.
-		msg := make([]byte, 1000)
-		n, _ := response.Body.Read(msg)
-		response.Body.Close()
-		txt := http.StatusText(code)
-		if n > 0 {
-			return nil, fmt.Errorf("%s (Status: %s)", msg[:n], txt)
-		}
-		return nil, fmt.Errorf("%s", txt)
+	if response.StatusCode >= 400 {
+		return nil, newHTTPStatusError(response)
 	}
 	return response.Body, nil
 }
@@ -187,6 +175,57 @@ func (t *httpTransport) endpoint() string {
 	return t.traceURL
 }
 
+// httpStatusError is returned by transports when the agent responds with an
+// error status code.
+type httpStatusError struct {
+	code       int           // the HTTP status code
+	msg        string        // the beginning of the response body, if any
+	retryAfter time.Duration // the delay requested by the Retry-After header, if any
+}
+
+// newHTTPStatusError returns an *httpStatusError describing resp, and closes
+// its body.
+func newHTTPStatusError(resp *http.Response) error {
+	// check the body for context information and return a nice error.
+	msg := make([]byte, 1000)
+	n, _ := resp.Body.Read(msg)
+	resp.Body.Close()
+	return &httpStatusError{
+		code:       resp.StatusCode,
+		msg:        string(msg[:n]),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *httpStatusError) Error() string {
+	txt := http.StatusText(e.code)
+	if e.msg != "" {
+		return fmt.Sprintf("%s (Status: %s)", e.msg, txt)
+	}
+	return txt
+}
+
+// parseRetryAfter returns the delay specified by the value of a Retry-After
+// header, which is either a number of seconds or an HTTP date. It returns 0 if
+// v is empty or invalid.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
 // resolveAgentAddr resolves the given agent address and fills in any missing host
 // and port using the defaults. Some environment variable settings will
 // take precedence over configuration.
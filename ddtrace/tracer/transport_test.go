@@ -197,6 +197,23 @@ func TestTraceCountHeader(t *testing.T) {
 	assert.Equal(hits, len(testCases))
 }
 
+func TestTransportProtocolV05(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer srv.Close()
+	transport := newHTTPTransport(srv.URL, defaultClient)
+	p4, p5 := newPayload(), newPayloadV05()
+	p4.push(getTestTrace(1, 1)[0])
+	p5.push(getTestTrace(1, 1)[0])
+	_, err := transport.send(p4)
+	assert.NoError(t, err)
+	_, err = transport.send(p5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/v0.4/traces", "/v0.5/traces"}, paths)
+}
+
 type recordingRoundTripper struct {
 	reqs []*http.Request
 	rt   http.RoundTripper
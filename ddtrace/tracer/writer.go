@@ -14,6 +14,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
@@ -50,19 +51,55 @@ type agentTraceWriter struct {
 
 	// statsd is used to send metrics
 	statsd globalinternal.StatsdClient
+
+	// spool, when non-nil, stores payloads which could not be sent to the agent
+	// so that they can be replayed later.
+	spool *payloadSpool
+
+	// replayWg waits for the replay of spooled payloads to finish
+	replayWg sync.WaitGroup
+
+	// done is closed when the writer is stopped
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 func newAgentTraceWriter(c *config, s *prioritySampler, statsdClient globalinternal.StatsdClient) *agentTraceWriter {
-	return &agentTraceWriter{
+	w := &agentTraceWriter{
 		config:           c,
-		payload:          newPayload(),
+		payload:          newPayloadFor(c),
 		climit:           make(chan struct{}, concurrentConnectionLimit),
 		prioritySampling: s,
 		statsd:           statsdClient,
+		done:             make(chan struct{}),
+	}
+	if c.spoolDir != "" {
+		spool, err := newPayloadSpool(c.spoolDir, c.spoolMaxBytes)
+		if err != nil {
+			log.Error("Unable to set up payload spool in %q, disabling it: %v", c.spoolDir, err)
+		} else {
+			w.spool = spool
+		}
+	}
+	return w
+}
+
+// newPayloadFor returns a new payload using the trace encoding negotiated with the agent.
+func newPayloadFor(c *config) *payload {
+	if c.traceProtocol == traceProtocolV05 {
+		return newPayloadV05()
 	}
+	return newPayload()
 }
 
 func (h *agentTraceWriter) add(trace []*span) {
+	if len(trace) > 0 && h.config.agentBreaker.shedding() {
+		if p, ok := trace[0].context.SamplingPriority(); !ok || p <= 0 {
+			// the agent is overloaded and would not keep this trace anyway
+			h.statsd.Incr("datadog.tracer.traces_dropped", []string{"reason:agent_overloaded"}, 1)
+			return
+		}
+	}
 	if err := h.payload.push(trace); err != nil {
 		h.statsd.Incr("datadog.tracer.traces_dropped", []string{"reason:encoding_error"}, 1)
 		log.Error("Error encoding msgpack: %v", err)
@@ -74,9 +111,33 @@ func (h *agentTraceWriter) add(trace []*span) {
 }
 
 func (h *agentTraceWriter) stop() {
+	// stop any replay of spooled payloads; they are kept for the next run
+	h.stopOnce.Do(func() { close(h.done) })
 	h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:shutdown"}, 1)
 	h.flush()
 	h.wg.Wait()
+	h.replayWg.Wait()
+}
+
+// wait waits for the duration d before retrying an upload. It returns early when the
+// writer is stopped, so that the final flush does not wait between attempts.
+func (h *agentTraceWriter) wait(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-h.done:
+	}
+}
+
+// stopped reports whether the writer was stopped.
+func (h *agentTraceWriter) stopped() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
 }
 
 // flush will push any currently buffered traces to the server.
@@ -87,7 +148,7 @@ func (h *agentTraceWriter) flush() {
 	h.wg.Add(1)
 	h.climit <- struct{}{}
 	oldp := h.payload
-	h.payload = newPayload()
+	h.payload = newPayloadFor(h.config)
 	go func(p *payload) {
 		defer func(start time.Time) {
 			// Once the payload has been used, clear the buffer for garbage
@@ -105,26 +166,108 @@ func (h *agentTraceWriter) flush() {
 		var err error
 		for attempt := 0; attempt <= h.config.sendRetries; attempt++ {
 			size, count = p.size(), p.itemCount()
+			if !h.config.agentBreaker.allow() {
+				err = errAgentOverloaded
+				break
+			}
 			log.Debug("Sending payload: size: %d traces: %d\n", size, count)
-			rc, err := h.config.transport.send(p)
+			var rc io.ReadCloser
+			rc, err = h.config.transport.send(p)
 			if err == nil {
+				h.config.agentBreaker.success()
 				log.Debug("sent traces after %d attempts", attempt+1)
 				h.statsd.Count("datadog.tracer.flush_bytes", int64(size), nil, 1)
 				h.statsd.Count("datadog.tracer.flush_traces", int64(count), nil, 1)
 				if err := h.prioritySampling.readRatesJSON(rc); err != nil {
 					h.statsd.Incr("datadog.tracer.decode_error", nil, 1)
 				}
+				if h.spool != nil {
+					h.startReplay()
+				}
 				return
 			}
+			h.config.agentBreaker.failure(err)
+			if h.config.agentBreaker.suspended() {
+				// no point in retrying until the agent recovers
+				log.Error("failure sending traces (attempt %d): %v", attempt+1, err)
+				break
+			}
 			log.Error("failure sending traces (attempt %d), will retry: %v", attempt+1, err)
 			p.reset()
-			time.Sleep(time.Millisecond)
+			if attempt < h.config.sendRetries {
+				h.wait(retryDelay(attempt, err))
+			}
+		}
+		if h.spool != nil {
+			evicted, err := h.spool.store(p)
+			if evicted > 0 {
+				h.statsd.Count("datadog.tracer.traces_dropped", int64(evicted), []string{"reason:spool_full"}, 1)
+				log.Error("lost %d spooled traces: spool size limit reached", evicted)
+			}
+			if err == nil {
+				h.statsd.Count("datadog.tracer.traces_spooled", int64(count), nil, 1)
+				log.Debug("spooled %d traces after failing to send them", count)
+				return
+			}
+			log.Error("failure spooling traces: %v", err)
 		}
 		h.statsd.Count("datadog.tracer.traces_dropped", int64(count), []string{"reason:send_failed"}, 1)
 		log.Error("lost %d traces: %v", count, err)
 	}(oldp)
 }
 
+// startReplay starts replaying the spooled payloads in the background, unless a
+// replay is already running or the writer is stopped.
+func (h *agentTraceWriter) startReplay() {
+	if h.stopped() || !atomic.CompareAndSwapUint32(&h.spool.replaying, 0, 1) {
+		return
+	}
+	h.replayWg.Add(1)
+	go func() {
+		defer h.replayWg.Done()
+		defer atomic.StoreUint32(&h.spool.replaying, 0)
+		h.replaySpool()
+	}()
+}
+
+// replaySpool sends all spooled payloads to the agent, oldest first. It stops at
+// the first failure or when the writer is stopped, leaving the remaining payloads
+// for the next replay.
+func (h *agentTraceWriter) replaySpool() {
+	for !h.stopped() {
+		e, ok := h.spool.oldest()
+		if !ok {
+			return
+		}
+		p, err := h.spool.load(e)
+		if err != nil {
+			h.spool.remove(e)
+			h.statsd.Count("datadog.tracer.traces_dropped", int64(e.count), []string{"reason:spool_error"}, 1)
+			log.Error("lost %d spooled traces: %v", e.count, err)
+			continue
+		}
+		if !h.config.agentBreaker.allow() {
+			return
+		}
+		size, count := p.size(), p.itemCount()
+		rc, err := h.config.transport.send(p)
+		p.clear()
+		if err != nil {
+			h.config.agentBreaker.failure(err)
+			log.Debug("failure replaying spooled traces, will retry later: %v", err)
+			return
+		}
+		h.config.agentBreaker.success()
+		h.spool.remove(e)
+		log.Debug("replayed %d spooled traces", count)
+		h.statsd.Count("datadog.tracer.flush_bytes", int64(size), nil, 1)
+		h.statsd.Count("datadog.tracer.flush_traces", int64(count), nil, 1)
+		if err := h.prioritySampling.readRatesJSON(rc); err != nil {
+			h.statsd.Incr("datadog.tracer.decode_error", nil, 1)
+		}
+	}
+}
+
 // logWriter specifies the output target of the logTraceWriter; replaced in tests.
 var logWriter io.Writer = os.Stdout
 
@@ -14,6 +14,7 @@ import (
 	"math"
 	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
@@ -416,6 +417,42 @@ func TestTraceWriterFlushRetries(t *testing.T) {
 	}
 }
 
+func TestTraceWriterRetryStop(t *testing.T) {
+	defer func(base, max time.Duration) {
+		retryBackoffBase, retryBackoffMax = base, max
+	}(retryBackoffBase, retryBackoffMax)
+	retryBackoffBase, retryBackoffMax = time.Minute, time.Minute
+	newWriter := func(t *testing.T) (*agentTraceWriter, *failingTransport) {
+		p := &failingTransport{failCount: 10, assert: assert.New(t)}
+		c := newConfig(func(c *config) {
+			c.transport = p
+			c.sendRetries = 3
+		})
+		return newAgentTraceWriter(c, nil, &testStatsdClient{}), p
+	}
+
+	t.Run("pending", func(t *testing.T) {
+		// stopping the writer interrupts the wait between attempts
+		h, p := newWriter(t)
+		h.add([]*span{makeSpan(0)})
+		h.flush()
+		start := time.Now()
+		h.stop()
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.Equal(t, 4, p.sendAttempts)
+	})
+
+	t.Run("final", func(t *testing.T) {
+		// the final flush does not wait between attempts
+		h, p := newWriter(t)
+		h.add([]*span{makeSpan(0)})
+		start := time.Now()
+		h.stop()
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.Equal(t, 4, p.sendAttempts)
+	})
+}
+
 func BenchmarkJsonEncodeSpan(b *testing.B) {
 	s := makeSpan(10)
 	s.Metrics["nan"] = math.NaN()
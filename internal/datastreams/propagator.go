@@ -20,6 +20,9 @@ type contextKey struct{}
 var activePathwayKey = contextKey{}
 
 const (
+	// PropagationKey is the key to use to propagate the pathway between services
+	// through headers holding binary values.
+	PropagationKey = "dd-pathway-ctx"
 	// PropagationKeyBase64 is the key to use to propagate the pathway between services.
 	PropagationKeyBase64 = "dd-pathway-ctx-base64"
 )
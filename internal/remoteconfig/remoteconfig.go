@@ -70,6 +70,34 @@ const (
 	APMTracingHTTPHeaderTags
 	// APMTracingCustomTags enables APM client to set custom tags on all spans
 	APMTracingCustomTags
+	// ASMProcessorOverrides adds support for processor overrides through the ASM RC Product
+	ASMProcessorOverrides
+	// ASMCustomDataScanners adds support for custom data scanners through the ASM RC Product
+	ASMCustomDataScanners
+	// ASMExclusionData adds support configurable exclusion filter data from the ASM_DATA Product
+	ASMExclusionData
+	// APMTracingEnabled enables APM tracing
+	APMTracingEnabled
+	// APMTracingDataStreamsEnabled enables Data Streams Monitoring
+	APMTracingDataStreamsEnabled
+	// ASMRASPSQLI enables ASM support for runtime protection against SQL Injection attacks
+	ASMRASPSQLI
+	// ASMRASPLFI enables ASM support for runtime protection against Local File Inclusion attacks
+	ASMRASPLFI
+	// ASMRASPSSRF enables ASM support for runtime protection against SSRF attacks
+	ASMRASPSSRF
+	// ASMRASPSHI enables ASM support for runtime protection against Shell Injection attacks
+	ASMRASPSHI
+	// ASMRASPXXE enables ASM support for runtime protection against XML External Entity attacks
+	ASMRASPXXE
+	// ASMRASPRCE enables ASM support for runtime protection against Remote Code Execution
+	ASMRASPRCE
+	// ASMRASPNOSQLI enables ASM support for runtime protection against NoSQL Injection attacks
+	ASMRASPNOSQLI
+	// ASMRASPXSS enables ASM support for runtime protection against Cross Site Scripting attacks
+	ASMRASPXSS
+	// APMTracingSampleRules represents the sampling rules to apply to traces and spans from APM client libraries
+	APMTracingSampleRules
 )
 
 // ErrClientNotStarted is returned when the remote config client is not started.
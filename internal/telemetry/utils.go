@@ -8,8 +8,10 @@
 package telemetry
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"reflect"
 	"sort"
 	"strings"
 	"testing"
@@ -85,6 +87,16 @@ func Sanitize(c Configuration) Configuration {
 			sb.WriteString(fmt.Sprint(val[k]))
 		}
 		c.Value = sb.String()
+	default:
+		// The telemetry API only supports primitive types.
+		// Other slices are reported using their JSON representation.
+		if v := reflect.ValueOf(val); v.Kind() == reflect.Slice {
+			if v.Len() == 0 {
+				c.Value = ""
+			} else if b, err := json.Marshal(val); err == nil {
+				c.Value = string(b)
+			}
+		}
 	}
 	return c
 }